@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Mazzael/go-api/configs"
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/Mazzael/go-api/internal/infra/database"
+	"github.com/Mazzael/go-api/internal/infra/database/migrations"
+	"gorm.io/gorm"
+)
+
+const usage = `usage: bootstrap -email <email> [-name <name>] [-password <password>] [-tenant <slug> [-tenant-name <name>]] [-force]
+
+Creates the first admin user of a tenant, the default one unless -tenant
+names another. A tenant that does not exist yet is created together with
+the admin. When a user with the email already exists in the tenant it is
+promoted to admin and enabled instead. Either way its email counts as
+verified, the operator vouching for it, so the admin can write right away.
+Refuses to run once the tenant has an enabled admin unless -force is given.
+The password may also be read from BOOTSTRAP_PASSWORD.
+`
+
+func main() {
+	flags := flag.NewFlagSet("bootstrap", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	name := flags.String("name", "Admin", "name of the admin user")
+	email := flags.String("email", "", "email of the admin user")
+	password := flags.String("password", os.Getenv("BOOTSTRAP_PASSWORD"), "password of the admin user")
+	tenant := flags.String("tenant", entity.DefaultTenantSlug, "slug of the tenant of the admin user")
+	tenantName := flags.String("tenant-name", "", "name of the tenant when it is created, the slug by default")
+	force := flags.Bool("force", false, "create or promote even when an admin already exists")
+	flags.Parse(os.Args[1:])
+
+	if *email == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if *tenantName == "" {
+		*tenantName = *tenant
+	}
+
+	if err := run(*name, *email, *password, *tenant, *tenantName, *force); err != nil {
+		fmt.Fprintln(os.Stderr, "bootstrap:", err)
+		os.Exit(1)
+	}
+}
+
+func run(name, email, password, tenantSlug, tenantName string, force bool) error {
+	configs, err := configs.LoadConfig(".")
+	if err != nil {
+		return err
+	}
+
+	db, err := database.NewConnection(configs.Database(), &gorm.Config{})
+	if err != nil {
+		return err
+	}
+
+	if _, err := migrations.NewMigrator(db).Up(); err != nil {
+		return err
+	}
+
+	tenantDB := database.NewTenant(db)
+	tenant, err := tenantDB.FindBySlug(context.Background(), tenantSlug)
+	if errors.Is(err, entity.ErrUnknownTenant) {
+		return createTenant(tenantDB, tenantSlug, tenantName, name, email, password)
+	}
+	if err != nil {
+		return err
+	}
+
+	ctx := database.WithTenant(context.Background(), tenant.ID)
+	userDB := database.NewUser(db)
+
+	admins, err := userDB.CountByRole(ctx, entity.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if admins > 0 && !force {
+		return fmt.Errorf("an admin already exists, use -force to add another")
+	}
+
+	user, err := userDB.FindByEmail(ctx, email)
+	if err == nil {
+		user.Role = entity.RoleAdmin
+		user.Disabled = false
+		user.VerifyEmail(time.Now())
+		if err := userDB.Update(ctx, user); err != nil {
+			return err
+		}
+		fmt.Printf("promoted %s to admin\n", email)
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	user, err = newAdmin(name, email, password)
+	if err != nil {
+		return err
+	}
+	if err := userDB.Create(ctx, user); err != nil {
+		return err
+	}
+	fmt.Printf("created admin %s\n", email)
+	return nil
+}
+
+// createTenant creates the tenant together with its first admin.
+func createTenant(tenantDB *database.GormTenantRepository, slug, tenantName, name, email, password string) error {
+	tenant, err := entity.NewTenant(slug, tenantName)
+	if err != nil {
+		return err
+	}
+	user, err := newAdmin(name, email, password)
+	if err != nil {
+		return err
+	}
+	if err := tenantDB.Create(context.Background(), tenant, user); err != nil {
+		return err
+	}
+	fmt.Printf("created tenant %s with admin %s\n", tenant.Slug, email)
+	return nil
+}
+
+func newAdmin(name, email, password string) (*entity.User, error) {
+	if password == "" {
+		return nil, fmt.Errorf("a password is required to create a new user")
+	}
+
+	user, err := entity.NewUser(name, email, password)
+	if err != nil {
+		return nil, err
+	}
+	user.Role = entity.RoleAdmin
+	user.VerifyEmail(time.Now())
+	return user, nil
+}
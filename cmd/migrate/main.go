@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Mazzael/go-api/configs"
+	"github.com/Mazzael/go-api/internal/infra/database"
+	"github.com/Mazzael/go-api/internal/infra/database/migrations"
+	"gorm.io/gorm"
+)
+
+const usage = `usage: migrate <command> [arguments]
+
+commands:
+  up              apply all pending migrations
+  down [steps]    roll back the last applied migrations (default 1)
+  status          list migrations and whether they are applied
+  create <name>   write a new empty migration file
+`
+
+const migrationsDir = "internal/infra/database/migrations"
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err := run(os.Args[1], os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "migrate:", err)
+		os.Exit(1)
+	}
+}
+
+func run(command string, args []string) error {
+	if command == "create" {
+		if len(args) != 1 {
+			return fmt.Errorf("create expects a migration name")
+		}
+		path, err := migrations.Create(migrationsDir, args[0], time.Now())
+		if err != nil {
+			return err
+		}
+		fmt.Println("created", path)
+		return nil
+	}
+
+	configs, err := configs.LoadConfig(".")
+	if err != nil {
+		return err
+	}
+
+	db, err := database.NewConnection(configs.Database(), &gorm.Config{})
+	if err != nil {
+		return err
+	}
+
+	migrator := migrations.NewMigrator(db)
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up()
+		for _, m := range applied {
+			fmt.Printf("applied  %s_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 0 {
+			steps, err = strconv.Atoi(args[0])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[0])
+			}
+		}
+		rolledBack, err := migrator.Down(steps)
+		for _, m := range rolledBack {
+			fmt.Printf("reverted %s_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%s_%-30s %s\n", s.Version, s.Name, appliedAt)
+		}
+		return nil
+	}
+
+	fmt.Fprint(os.Stderr, usage)
+	return fmt.Errorf("unknown command %q", command)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Mazzael/go-api/configs"
+	"github.com/Mazzael/go-api/internal/infra/database"
+	"github.com/Mazzael/go-api/internal/infra/database/migrations"
+	"gorm.io/gorm"
+)
+
+// reindex rebuilds the product full-text search index from the products
+// table, e.g. after a bulk import or after switching a sqlite deployment to
+// a binary built with -tags sqlite_fts5.
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "reindex:", err)
+		if errors.Is(err, database.ErrNoSearchIndex) {
+			fmt.Fprintln(os.Stderr, "reindex: on sqlite, build with -tags sqlite_fts5 to enable full-text search")
+		}
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	configs, err := configs.LoadConfig(".")
+	if err != nil {
+		return err
+	}
+
+	db, err := database.NewConnection(configs.Database(), &gorm.Config{})
+	if err != nil {
+		return err
+	}
+
+	if _, err := migrations.NewMigrator(db).Up(); err != nil {
+		return err
+	}
+
+	count, err := database.NewProduct(db).RebuildSearchIndex(context.Background())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("indexed %d products\n", count)
+	return nil
+}
@@ -1,24 +1,41 @@
 package main
 
 import (
-	"net/http"
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/Mazzael/go-api/configs"
 	_ "github.com/Mazzael/go-api/docs"
 	"github.com/Mazzael/go-api/internal/entity"
 	"github.com/Mazzael/go-api/internal/infra/database"
+	"github.com/Mazzael/go-api/internal/infra/database/migrations"
+	"github.com/Mazzael/go-api/internal/infra/mail"
+	"github.com/Mazzael/go-api/internal/infra/metrics"
+	"github.com/Mazzael/go-api/internal/infra/tracing"
+	"github.com/Mazzael/go-api/internal/infra/webserver"
 	"github.com/Mazzael/go-api/internal/infra/webserver/handlers"
+	"github.com/Mazzael/go-api/internal/infra/webserver/middlewares"
+	"github.com/Mazzael/go-api/pkg/health"
+	"github.com/Mazzael/go-api/pkg/jwtkeys"
+	"github.com/Mazzael/go-api/pkg/pagination"
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/jwtauth"
 	httpSwagger "github.com/swaggo/http-swagger"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 // @title           Go API Example
 // @version         1.0
 // @description     Product API with auhtentication
+// @description     Errors are RFC 7807 problem details (application/problem+json). Besides about:blank, the
+// @description     type is one of /problems/validation (422, errors lists the offending fields),
+// @description     /problems/invalid-parameter, /problems/malformed-body, /problems/insufficient-stock,
+// @description     /problems/invalid-token, /problems/token-reused and /problems/email-unverified (403, the
+// @description     user has to verify their email first). A database that does not answer in time gives a 504.
 
 // @contact.name   Vinicius Trujillo Mazza
 // @contact.email  vtrujillomazza@gmail.com
@@ -28,6 +45,9 @@ import (
 // @securityDefinitions.apikey ApiKeyAuth
 // @in header
 // @name Authorization
+// @securityDefinitions.apikey PersonalAPIKey
+// @in header
+// @name X-API-Key
 
 func main() {
 	configs, err := configs.LoadConfig(".")
@@ -35,39 +55,248 @@ func main() {
 		panic("Failed to load configuration: " + err.Error())
 	}
 
-	db, err := gorm.Open(sqlite.Open("test.db"), &gorm.Config{})
+	tracerProvider, err := configs.NewTracerProvider(context.Background())
+	if err != nil {
+		panic("Failed to set up tracing: " + err.Error())
+	}
+	appTracing := tracing.New(tracerProvider)
+
+	db, err := database.NewConnection(configs.Database(), &gorm.Config{})
 	if err != nil {
 		panic("Failed to connect to the database: " + err.Error())
 	}
+	if err := db.Use(appTracing.GormPlugin()); err != nil {
+		panic("Failed to trace database statements: " + err.Error())
+	}
+
+	if _, err := migrations.NewMigrator(db).Up(); err != nil {
+		panic("Failed to run database migrations: " + err.Error())
+	}
+	// migrations may take their time, requests may not.
+	if err := db.Use(configs.DatabaseTimeouts()); err != nil {
+		panic("Failed to set database timeouts: " + err.Error())
+	}
+
+	appMetrics := metrics.New()
+	if err := appMetrics.RegisterDB(db, configs.DBName); err != nil {
+		panic("Failed to collect database metrics: " + err.Error())
+	}
 
-	db.AutoMigrate(&entity.Product{}, &entity.User{})
+	gormTenantRepository := database.NewTenant(db)
+	productRepository := database.NewProduct(db)
+	if err := productRepository.CheckSearchIndex(); err != nil {
+		panic("Failed to set up product search: " + err.Error() + "; on sqlite, build with -tags sqlite_fts5 as make build does")
+	}
+	gormProductRepository := appMetrics.Products(appTracing.Products(productRepository))
+	gormCategoryRepository := database.NewCategory(db)
+	productHandler := handlers.NewProductHandler(gormProductRepository, gormCategoryRepository, pagination.NewSigner([]byte(configs.CursorSecret)), configs.ProductsOwnerOnly)
+	productHandler.Events = appMetrics
+	categoryHandler := handlers.NewCategoryHandler(gormCategoryRepository)
+
+	gormStockRepository := database.NewStock(db)
+	stockHandler := handlers.NewStockHandler(gormProductRepository, gormStockRepository)
+
+	gormUserRepository := appMetrics.Users(appTracing.Users(database.NewUser(db)))
+	gormRefreshTokenRepository := database.NewRefreshToken(db)
+	gormRevokedTokenRepository := database.NewRevokedToken(db)
+	loginAttemptRepository := newLoginAttemptRepository(db, configs.LoginLimiter)
+	loginThrottle := handlers.NewLoginThrottle(loginAttemptRepository, configs.AccountLoginPolicy(), configs.IPLoginPolicy())
+	mailer, err := configs.NewMailer()
+	if err != nil {
+		panic("Failed to set up the mailer: " + err.Error())
+	}
+	mailTemplates, err := mail.NewTemplates()
+	if err != nil {
+		panic("Failed to load the email templates: " + err.Error())
+	}
+	gormUserTokenRepository := database.NewUserToken(db)
+	accountMailer := handlers.NewAccountMailer(gormUserTokenRepository, mailer, mailTemplates, configs.AppURL, configs.VerifyEmailTTL(), configs.PasswordResetTTL())
+	mfa := handlers.NewMFA(gormUserTokenRepository, database.NewRecoveryCode(db), configs.MFAIssuer, configs.MFAChallengeTTL())
+	userHandler := handlers.NewUserHandler(gormUserRepository, gormRefreshTokenRepository, gormRevokedTokenRepository, loginThrottle, accountMailer, mfa)
+	userHandler.Events = appMetrics
 
-	gormProductRepository := database.NewProduct(db)
-	productHandler := handlers.NewProductHandler(gormProductRepository)
+	gormAPIKeyRepository := database.NewAPIKey(db)
+	apiKeyHandler := handlers.NewAPIKeyHandler(gormAPIKeyRepository)
 
-	gormUserRepository := database.NewUser(db)
-	userHandler := handlers.NewUserHandler(gormUserRepository)
+	jwksHandler := handlers.NewJWKSHandler(configs.TokenAuth)
 
 	r := chi.NewRouter()
+	server := webserver.NewServer(configs.WebServer(), r)
+	accountMailer.Go = server.Go
+
+	readiness := configs.Health()
+	readiness.Register("server", server)
+	readiness.Register("database", database.HealthCheck(db))
+	if checker, ok := mailer.(health.Checker); ok {
+		readiness.Register("mailer", checker)
+	}
+	healthHandler := handlers.NewHealthHandler(readiness)
+	server.Go(func(ctx context.Context) {
+		rotateSigningKeyOnHangup(ctx, configs.TokenAuth, configs.SigningKey, configs.KeyOverlap())
+	})
+	server.Go(func(ctx context.Context) {
+		purgeExpiredTokens(ctx, gormRefreshTokenRepository, gormRevokedTokenRepository, gormUserTokenRepository, loginAttemptRepository, time.Hour)
+	})
+
+	r.NotFound(handlers.NotFound)
+	r.MethodNotAllowed(handlers.MethodNotAllowed)
+	if configs.TrustProxyHeaders {
+		r.Use(middleware.RealIP)
+	}
+	r.Use(appTracing.Middleware)
+	r.Use(appMetrics.Middleware)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.WithValue("token", configs.TokenAuth))
 	r.Use(middleware.WithValue("JwtExpiresIn", configs.JWTExpiresIn))
+	r.Use(middleware.WithValue("JwtRefreshExpiresIn", configs.JWTRefreshExpiresIn))
 
 	r.Route("/products", func(r chi.Router) {
-		r.Use(jwtauth.Verifier(configs.TokenAuth))
-		r.Use(jwtauth.Authenticator)
-		r.Post("/", productHandler.CreateProduct)
-		r.Get("/{id}", productHandler.GetProduct)
-		r.Get("/", productHandler.GetProducts)
-		r.Put("/{id}", productHandler.UpdateProduct)
-		r.Delete("/{id}", productHandler.DeleteProduct)
+		r.Use(configs.TokenAuth.Verifier())
+		r.Use(middlewares.VerifyAPIKey(gormAPIKeyRepository, gormUserRepository))
+		r.Use(middlewares.Authenticator)
+		r.Use(middlewares.RejectRevokedTokens(gormRevokedTokenRepository))
+		r.Use(middlewares.ResolveTenant(gormTenantRepository))
+		r.With(middlewares.RequirePermission(entity.PermissionProductsWrite), middlewares.RequireVerifiedEmail).Post("/", productHandler.CreateProduct)
+		r.With(middlewares.RequirePermission(entity.PermissionProductsRead)).Get("/{id}", productHandler.GetProduct)
+		r.With(middlewares.RequirePermission(entity.PermissionProductsRead)).Get("/", productHandler.GetProducts)
+		r.With(middlewares.RequirePermission(entity.PermissionProductsRead)).Get("/search", productHandler.SearchProducts)
+		r.With(middlewares.RequirePermission(entity.PermissionProductsWrite), middlewares.RequireVerifiedEmail).Put("/{id}", productHandler.UpdateProduct)
+		r.With(middlewares.RequirePermission(entity.PermissionProductsDelete), middlewares.RequireVerifiedEmail).Delete("/{id}", productHandler.DeleteProduct)
+		r.With(middlewares.RequirePermission(entity.PermissionProductsRead)).Get("/{id}/stock", stockHandler.GetStock)
+		r.With(middlewares.RequirePermission(entity.PermissionProductsRead)).Get("/{id}/stock/movements", stockHandler.GetStockMovements)
+		r.With(middlewares.RequirePermission(entity.PermissionStockWrite), middlewares.RequireVerifiedEmail).Post("/{id}/stock/movements", stockHandler.CreateStockMovement)
+	})
+
+	r.Route("/categories", func(r chi.Router) {
+		r.Use(configs.TokenAuth.Verifier())
+		r.Use(middlewares.VerifyAPIKey(gormAPIKeyRepository, gormUserRepository))
+		r.Use(middlewares.Authenticator)
+		r.Use(middlewares.RejectRevokedTokens(gormRevokedTokenRepository))
+		r.Use(middlewares.ResolveTenant(gormTenantRepository))
+		r.With(middlewares.RequirePermission(entity.PermissionCategoriesWrite), middlewares.RequireVerifiedEmail).Post("/", categoryHandler.CreateCategory)
+		r.With(middlewares.RequirePermission(entity.PermissionProductsRead)).Get("/{id}", categoryHandler.GetCategory)
+		r.With(middlewares.RequirePermission(entity.PermissionProductsRead)).Get("/", categoryHandler.GetCategories)
+		r.With(middlewares.RequirePermission(entity.PermissionCategoriesWrite), middlewares.RequireVerifiedEmail).Put("/{id}", categoryHandler.UpdateCategory)
+		r.With(middlewares.RequirePermission(entity.PermissionCategoriesWrite), middlewares.RequireVerifiedEmail).Delete("/{id}", categoryHandler.DeleteCategory)
 	})
 
-	r.Post("/users", userHandler.CreateUser)
-	r.Post("/users/auth", userHandler.Login)
+	r.Route("/users", func(r chi.Router) {
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.ResolveTenant(gormTenantRepository))
+			r.Post("/", userHandler.CreateUser)
+			r.Post("/auth", userHandler.Login)
+			r.Post("/auth/mfa", userHandler.LoginMFA)
+			r.Post("/auth/refresh", userHandler.Refresh)
+			r.Post("/password/forgot", userHandler.ForgotPassword)
+			r.Post("/password/reset", userHandler.ResetPassword)
+			r.Post("/verify", userHandler.VerifyEmail)
+		})
 
-	r.Get("/docs/*", httpSwagger.Handler(httpSwagger.URL("http://localhost:8080/docs/doc.json")))
+		r.Group(func(r chi.Router) {
+			r.Use(configs.TokenAuth.Verifier())
+			r.Use(middlewares.Authenticator)
+			r.Use(middlewares.RejectRevokedTokens(gormRevokedTokenRepository))
+			r.Use(middlewares.ResolveTenant(gormTenantRepository))
+			r.Post("/logout", userHandler.Logout)
+			r.Get("/me", userHandler.GetMe)
+			r.Patch("/me", userHandler.UpdateMe)
+			r.Delete("/me", userHandler.DeleteMe)
+			r.Put("/me/password", userHandler.ChangePassword)
+			r.Post("/verify/resend", userHandler.ResendVerification)
+			r.Post("/me/api-keys", apiKeyHandler.CreateAPIKey)
+			r.Get("/me/api-keys", apiKeyHandler.ListAPIKeys)
+			r.Delete("/me/api-keys/{id}", apiKeyHandler.RevokeAPIKey)
+			r.Post("/me/mfa/totp", userHandler.EnrollTOTP)
+			r.Post("/me/mfa/totp/confirm", userHandler.ConfirmTOTP)
+			r.Post("/me/mfa/totp/disable", userHandler.DisableTOTP)
+			r.Post("/me/mfa/recovery-codes", userHandler.RegenerateRecoveryCodes)
+			r.With(middlewares.RequirePermission(entity.PermissionUsersManage)).Get("/", userHandler.ListUsers)
+			r.With(middlewares.RequirePermission(entity.PermissionUsersManage)).Get("/{id}", userHandler.GetUser)
+			r.With(middlewares.RequirePermission(entity.PermissionUsersManage)).Post("/{id}/disable", userHandler.DisableUser)
+			r.With(middlewares.RequirePermission(entity.PermissionUsersManage)).Post("/{id}/enable", userHandler.EnableUser)
+			r.With(middlewares.RequirePermission(entity.PermissionUsersManage)).Post("/{id}/unlock", userHandler.UnlockUser)
+			r.With(middlewares.RequirePermission(entity.PermissionUsersManage)).Post("/{id}/mfa/reset", userHandler.ResetMFA)
+			r.With(middlewares.RequirePermission(entity.PermissionUsersManage)).Delete("/{id}", userHandler.DeleteUser)
+		})
+	})
+
+	r.Get("/.well-known/jwks.json", jwksHandler.GetJWKS)
+	r.Get("/docs/*", httpSwagger.Handler(httpSwagger.URL(configs.AppURL+"/docs/doc.json")))
+	r.Get("/healthz", healthHandler.Live)
+	r.Get("/readyz", healthHandler.Ready)
+	r.Handle("/metrics", appMetrics.Handler())
 
-	http.ListenAndServe(":8080", r)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := server.Run(ctx); err != nil {
+		log.Println("server stopped:", err)
+	}
+	if err := tracerProvider.Shutdown(context.Background()); err != nil {
+		log.Println("failed to flush the traces:", err)
+	}
+	if err := database.Close(db); err != nil {
+		log.Println("failed to close the database:", err)
+	}
+}
+
+// newLoginAttemptRepository picks where failed logins are counted.
+func newLoginAttemptRepository(db *gorm.DB, limiter string) database.LoginAttemptRepository {
+	if limiter == configs.LoginLimiterMemory {
+		return database.NewMemoryLoginAttempt()
+	}
+	return database.NewLoginAttempt(db)
+}
+
+// rotateSigningKeyOnHangup reloads the signing key on SIGHUP, so that a key
+// file replaced on disk is rotated in without dropping the tokens signed
+// with the old key. It returns once ctx is done.
+func rotateSigningKeyOnHangup(ctx context.Context, keyring *jwtkeys.Keyring, signingKey func() (*jwtkeys.Key, error), overlap time.Duration) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+		}
+		key, err := signingKey()
+		if err == nil {
+			err = keyring.Rotate(key, overlap)
+		}
+		if err != nil {
+			log.Println("failed to rotate the signing key:", err)
+			continue
+		}
+		log.Println("signing access tokens with key", key.ID)
+	}
+}
+
+// purgeExpiredTokens periodically deletes refresh tokens, blacklisted access
+// tokens and mailed tokens that have expired, and failed logins old enough
+// to be forgotten, until ctx is done.
+func purgeExpiredTokens(ctx context.Context, refreshTokens database.RefreshTokenRepository, revokedTokens database.RevokedTokenRepository, userTokens database.UserTokenRepository, loginAttempts database.LoginAttemptRepository, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+		if _, err := refreshTokens.DeleteExpired(ctx, now); err != nil {
+			log.Println("failed to purge expired refresh tokens:", err)
+		}
+		if _, err := revokedTokens.DeleteExpired(ctx, now); err != nil {
+			log.Println("failed to purge revoked access tokens:", err)
+		}
+		if _, err := userTokens.DeleteExpired(ctx, now); err != nil {
+			log.Println("failed to purge expired user tokens:", err)
+		}
+		if _, err := loginAttempts.DeleteExpired(ctx, now, now.Add(-24*time.Hour)); err != nil {
+			log.Println("failed to purge failed login attempts:", err)
+		}
+	}
 }
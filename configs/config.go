@@ -1,21 +1,101 @@
 package configs
 
 import (
-	"github.com/go-chi/jwtauth"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/Mazzael/go-api/internal/infra/database"
+	"github.com/Mazzael/go-api/internal/infra/mail"
+	"github.com/Mazzael/go-api/internal/infra/tracing"
+	"github.com/Mazzael/go-api/internal/infra/webserver"
+	"github.com/Mazzael/go-api/pkg/health"
+	"github.com/Mazzael/go-api/pkg/jwtkeys"
 	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	// LoginLimiterDatabase shares failed login counts between instances
+	// through the database.
+	LoginLimiterDatabase = "database"
+	// LoginLimiterMemory keeps failed login counts in each instance.
+	LoginLimiterMemory = "memory"
+
+	// MailerStdout prints emails instead of sending them.
+	MailerStdout = "stdout"
+	// MailerFile appends emails to MAIL_FILE instead of sending them.
+	MailerFile = "file"
+	// MailerSMTP sends emails through SMTP_HOST.
+	MailerSMTP = "smtp"
+
+	// TracingNone records no spans.
+	TracingNone = "none"
+	// TracingStdout prints spans as JSON.
+	TracingStdout = "stdout"
+	// TracingFile appends spans as JSON lines to TRACING_FILE.
+	TracingFile = "file"
+	// TracingOTLP sends spans over OTLP/HTTP to OTEL_EXPORTER_OTLP_ENDPOINT.
+	TracingOTLP = "otlp"
 )
 
 type conf struct {
-	DBDriver      string `mapstructure:"DB_DRIVER"`
-	DBHost        string `mapstructure:"DB_HOST"`
-	DBPort        string `mapstructure:"DB_PORT"`
-	DBUser        string `mapstructure:"DB_USER"`
-	DBPassword    string `mapstructure:"DB_PASSWORD"`
-	DBName        string `mapstructure:"DB_NAME"`
-	WebServerPort string `mapstructure:"WEB_SERVER_PORT"`
-	JWTSecret     string `mapstructure:"JWT_SECRET"`
-	JWTExpiresIn  int    `mapstructure:"JWT_EXPIRESIN"`
-	TokenAuth     *jwtauth.JWTAuth
+	DBDriver               string `mapstructure:"DB_DRIVER"`
+	DBHost                 string `mapstructure:"DB_HOST"`
+	DBPort                 string `mapstructure:"DB_PORT"`
+	DBUser                 string `mapstructure:"DB_USER"`
+	DBPassword             string `mapstructure:"DB_PASSWORD"`
+	DBName                 string `mapstructure:"DB_NAME"`
+	DBSSLMode              string `mapstructure:"DB_SSLMODE"`
+	DBMaxOpenConns         int    `mapstructure:"DB_MAX_OPEN_CONNS"`
+	DBMaxIdleConns         int    `mapstructure:"DB_MAX_IDLE_CONNS"`
+	DBConnMaxLifetime      int    `mapstructure:"DB_CONN_MAX_LIFETIME"`
+	DBReadTimeout          int    `mapstructure:"DB_READ_TIMEOUT"`
+	DBWriteTimeout         int    `mapstructure:"DB_WRITE_TIMEOUT"`
+	WebServerPort          string `mapstructure:"WEB_SERVER_PORT"`
+	WebServerReadTimeout   int    `mapstructure:"WEB_SERVER_READ_TIMEOUT"`
+	WebServerWriteTimeout  int    `mapstructure:"WEB_SERVER_WRITE_TIMEOUT"`
+	WebServerIdleTimeout   int    `mapstructure:"WEB_SERVER_IDLE_TIMEOUT"`
+	ShutdownDelay          int    `mapstructure:"SHUTDOWN_DELAY"`
+	ShutdownTimeout        int    `mapstructure:"SHUTDOWN_TIMEOUT"`
+	HealthCacheTTL         int    `mapstructure:"HEALTH_CACHE_TTL"`
+	HealthCheckTimeout     int    `mapstructure:"HEALTH_CHECK_TIMEOUT"`
+	JWTSecret              string `mapstructure:"JWT_SECRET"`
+	JWTExpiresIn           int    `mapstructure:"JWT_EXPIRESIN"`
+	JWTRefreshExpiresIn    int    `mapstructure:"JWT_REFRESH_EXPIRESIN"`
+	CursorSecret           string `mapstructure:"CURSOR_SECRET"`
+	LoginLimiter           string `mapstructure:"LOGIN_LIMITER"`
+	LoginFreeAttempts      int    `mapstructure:"LOGIN_FREE_ATTEMPTS"`
+	LoginMaxFailures       int    `mapstructure:"LOGIN_MAX_FAILURES"`
+	LoginIPMaxFailures     int    `mapstructure:"LOGIN_IP_MAX_FAILURES"`
+	LoginLockout           int    `mapstructure:"LOGIN_LOCKOUT"`
+	TrustProxyHeaders      bool   `mapstructure:"TRUST_PROXY_HEADERS"`
+	Mailer                 string `mapstructure:"MAILER"`
+	MailFile               string `mapstructure:"MAIL_FILE"`
+	MailFrom               string `mapstructure:"MAIL_FROM"`
+	SMTPHost               string `mapstructure:"SMTP_HOST"`
+	SMTPPort               string `mapstructure:"SMTP_PORT"`
+	SMTPUsername           string `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword           string `mapstructure:"SMTP_PASSWORD"`
+	AppURL                 string `mapstructure:"APP_URL"`
+	VerifyEmailExpiresIn   int    `mapstructure:"VERIFY_EMAIL_EXPIRESIN"`
+	PasswordResetExpiresIn int    `mapstructure:"PASSWORD_RESET_EXPIRESIN"`
+	JWTPrivateKeyFile      string `mapstructure:"JWT_PRIVATE_KEY_FILE"`
+	JWTKeyID               string `mapstructure:"JWT_KEY_ID"`
+	JWTVerifyKeyFiles      string `mapstructure:"JWT_VERIFY_KEY_FILES"`
+	JWTKeyOverlap          int    `mapstructure:"JWT_KEY_OVERLAP"`
+	MFAIssuer              string `mapstructure:"MFA_ISSUER"`
+	MFAChallengeExpiresIn  int    `mapstructure:"MFA_CHALLENGE_EXPIRESIN"`
+	ProductsOwnerOnly      bool   `mapstructure:"PRODUCTS_OWNER_ONLY"`
+	TracingExporter        string `mapstructure:"TRACING_EXPORTER"`
+	TracingFile            string `mapstructure:"TRACING_FILE"`
+	TracingServiceName     string `mapstructure:"TRACING_SERVICE_NAME"`
+	TokenAuth              *jwtkeys.Keyring
 }
 
 func LoadConfig(path string) (*conf, error) {
@@ -26,6 +106,34 @@ func LoadConfig(path string) (*conf, error) {
 	viper.AddConfigPath(path)
 	viper.SetConfigFile(".env")
 	viper.AutomaticEnv()
+	viper.SetDefault("DB_READ_TIMEOUT", 5)
+	viper.SetDefault("DB_WRITE_TIMEOUT", 10)
+	viper.SetDefault("WEB_SERVER_PORT", "8080")
+	viper.SetDefault("WEB_SERVER_READ_TIMEOUT", 15)
+	viper.SetDefault("WEB_SERVER_WRITE_TIMEOUT", 30)
+	viper.SetDefault("WEB_SERVER_IDLE_TIMEOUT", 60)
+	viper.SetDefault("SHUTDOWN_DELAY", 5)
+	viper.SetDefault("SHUTDOWN_TIMEOUT", 30)
+	viper.SetDefault("HEALTH_CACHE_TTL", 1)
+	viper.SetDefault("HEALTH_CHECK_TIMEOUT", 2)
+	viper.SetDefault("JWT_REFRESH_EXPIRESIN", 7*24*60*60)
+	viper.SetDefault("LOGIN_LIMITER", LoginLimiterDatabase)
+	viper.SetDefault("LOGIN_FREE_ATTEMPTS", 3)
+	viper.SetDefault("LOGIN_MAX_FAILURES", 10)
+	viper.SetDefault("LOGIN_IP_MAX_FAILURES", 100)
+	viper.SetDefault("LOGIN_LOCKOUT", 15*60)
+	viper.SetDefault("MAILER", MailerStdout)
+	viper.SetDefault("MAIL_FILE", "mail.log")
+	viper.SetDefault("MAIL_FROM", "Go API <no-reply@localhost>")
+	viper.SetDefault("SMTP_PORT", "587")
+	viper.SetDefault("APP_URL", "http://localhost:8080")
+	viper.SetDefault("VERIFY_EMAIL_EXPIRESIN", 48*60*60)
+	viper.SetDefault("PASSWORD_RESET_EXPIRESIN", 60*60)
+	viper.SetDefault("MFA_ISSUER", "Go API")
+	viper.SetDefault("MFA_CHALLENGE_EXPIRESIN", 5*60)
+	viper.SetDefault("TRACING_EXPORTER", TracingNone)
+	viper.SetDefault("TRACING_FILE", "traces.json")
+	viper.SetDefault("TRACING_SERVICE_NAME", "go-api")
 	err := viper.ReadInConfig()
 	if err != nil {
 		panic(err)
@@ -36,7 +144,183 @@ func LoadConfig(path string) (*conf, error) {
 		panic(err)
 	}
 
-	cfg.TokenAuth = jwtauth.New("HS256", []byte(cfg.JWTSecret), nil)
+	cfg.TokenAuth, err = cfg.keyring()
+	if err != nil {
+		return nil, err
+	}
+	// cursors are signed with CURSOR_SECRET, JWT_SECRET by default; keys
+	// loaded from files leave nothing to fall back to.
+	if cfg.CursorSecret == "" {
+		cfg.CursorSecret = cfg.JWTSecret
+	}
+	if cfg.CursorSecret == "" {
+		return nil, fmt.Errorf("CURSOR_SECRET is required when JWT_SECRET is not set")
+	}
 
 	return cfg, nil
 }
+
+func (c *conf) Database() database.Config {
+	return database.Config{
+		Driver:          c.DBDriver,
+		Host:            c.DBHost,
+		Port:            c.DBPort,
+		User:            c.DBUser,
+		Password:        c.DBPassword,
+		Name:            c.DBName,
+		SSLMode:         c.DBSSLMode,
+		MaxOpenConns:    c.DBMaxOpenConns,
+		MaxIdleConns:    c.DBMaxIdleConns,
+		ConnMaxLifetime: time.Second * time.Duration(c.DBConnMaxLifetime),
+	}
+}
+
+// DatabaseTimeouts bound the statements run for requests to DB_READ_TIMEOUT
+// and DB_WRITE_TIMEOUT seconds, none when zero.
+func (c *conf) DatabaseTimeouts() database.Timeouts {
+	return database.Timeouts{
+		Read:  time.Second * time.Duration(c.DBReadTimeout),
+		Write: time.Second * time.Duration(c.DBWriteTimeout),
+	}
+}
+
+// WebServer sets up the HTTP server; the timeouts are in seconds. Reading
+// the request headers gets the read timeout too.
+func (c *conf) WebServer() webserver.Config {
+	return webserver.Config{
+		Port:              c.WebServerPort,
+		ReadTimeout:       time.Second * time.Duration(c.WebServerReadTimeout),
+		ReadHeaderTimeout: time.Second * time.Duration(c.WebServerReadTimeout),
+		WriteTimeout:      time.Second * time.Duration(c.WebServerWriteTimeout),
+		IdleTimeout:       time.Second * time.Duration(c.WebServerIdleTimeout),
+		ShutdownDelay:     time.Second * time.Duration(c.ShutdownDelay),
+		ShutdownTimeout:   time.Second * time.Duration(c.ShutdownTimeout),
+	}
+}
+
+// Health checks the dependencies of the instance for readiness probes,
+// caching reports for HEALTH_CACHE_TTL seconds.
+func (c *conf) Health() *health.Health {
+	return health.New(time.Second*time.Duration(c.HealthCacheTTL), time.Second*time.Duration(c.HealthCheckTimeout))
+}
+
+// SigningKey is the key access tokens are signed with: the private key in
+// JWT_PRIVATE_KEY_FILE, whose type picks RS256, ES256 or EdDSA, or else
+// JWT_SECRET with HS256. Reading it again picks up a replaced key file.
+func (c *conf) SigningKey() (*jwtkeys.Key, error) {
+	if c.JWTPrivateKeyFile == "" {
+		return jwtkeys.NewKey([]byte(c.JWTSecret), c.JWTKeyID)
+	}
+	return jwtkeys.LoadPEM(c.JWTPrivateKeyFile, c.JWTKeyID)
+}
+
+// KeyOverlap is how long tokens signed with a replaced key stay valid. It
+// defaults to the lifetime of access tokens, so none is cut short.
+func (c *conf) KeyOverlap() time.Duration {
+	if c.JWTKeyOverlap > 0 {
+		return time.Second * time.Duration(c.JWTKeyOverlap)
+	}
+	return time.Second * time.Duration(c.JWTExpiresIn)
+}
+
+// keyring signs with SigningKey and also trusts the keys of the comma
+// separated JWT_VERIFY_KEY_FILES: keys retired by the previous deployment,
+// or those other instances are about to sign with.
+func (c *conf) keyring() (*jwtkeys.Keyring, error) {
+	signing, err := c.SigningKey()
+	if err != nil {
+		return nil, err
+	}
+	keyring, err := jwtkeys.NewKeyring(signing)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range strings.Split(c.JWTVerifyKeyFiles, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		key, err := jwtkeys.LoadPEM(path, "")
+		if err != nil {
+			return nil, err
+		}
+		keyring.Trust(key, time.Time{})
+	}
+	return keyring, nil
+}
+
+// AccountLoginPolicy throttles failed logins to a single account.
+func (c *conf) AccountLoginPolicy() entity.LoginPolicy {
+	return c.loginPolicy(c.LoginMaxFailures)
+}
+
+// IPLoginPolicy throttles failed logins from a single client IP, which may
+// be shared by many users behind a NAT and so tolerates more failures.
+func (c *conf) IPLoginPolicy() entity.LoginPolicy {
+	return c.loginPolicy(c.LoginIPMaxFailures)
+}
+
+func (c *conf) loginPolicy(maxFailures int) entity.LoginPolicy {
+	lockout := time.Second * time.Duration(c.LoginLockout)
+	return entity.LoginPolicy{
+		FreeAttempts: c.LoginFreeAttempts,
+		BaseDelay:    time.Second,
+		MaxDelay:     30 * time.Second,
+		MaxFailures:  maxFailures,
+		Lockout:      lockout,
+		Window:       lockout,
+	}
+}
+
+// NewMailer builds the mailer MAILER selects.
+func (c *conf) NewMailer() (mail.Mailer, error) {
+	switch c.Mailer {
+	case MailerStdout:
+		return mail.NewWriter(os.Stdout, c.MailFrom), nil
+	case MailerFile:
+		return mail.NewFile(c.MailFile, c.MailFrom)
+	case MailerSMTP:
+		return mail.NewSMTP(c.SMTPHost, c.SMTPPort, c.SMTPUsername, c.SMTPPassword, c.MailFrom), nil
+	default:
+		return nil, fmt.Errorf("unknown MAILER %q", c.Mailer)
+	}
+}
+
+// NewTracerProvider builds the provider of the spans TRACING_EXPORTER
+// selects. Shutting it down flushes the spans not exported yet.
+func (c *conf) NewTracerProvider(ctx context.Context) (*sdktrace.TracerProvider, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch c.TracingExporter {
+	case TracingNone:
+		// nothing is recorded, but the trace a request names is still
+		// passed on to the handlers.
+		return sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.NeverSample())), nil
+	case TracingStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case TracingFile:
+		exporter, err = tracing.NewFileExporter(c.TracingFile)
+	case TracingOTLP:
+		exporter, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("unknown TRACING_EXPORTER %q", c.TracingExporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tracing.NewProvider(ctx, exporter, c.TracingServiceName)
+}
+
+func (c *conf) VerifyEmailTTL() time.Duration {
+	return time.Second * time.Duration(c.VerifyEmailExpiresIn)
+}
+
+func (c *conf) PasswordResetTTL() time.Duration {
+	return time.Second * time.Duration(c.PasswordResetExpiresIn)
+}
+
+// MFAChallengeTTL is how long a login that checked the password waits for
+// the second factor.
+func (c *conf) MFAChallengeTTL() time.Duration {
+	return time.Second * time.Duration(c.MFAChallengeExpiresIn)
+}
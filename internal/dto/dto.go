@@ -1,21 +1,139 @@
 package dto
 
+import (
+	"time"
+
+	"github.com/Mazzael/go-api/pkg/entity"
+)
+
 type CreateProductInput struct {
-	Name  string  `json:"name"`
-	Price float64 `json:"price"`
+	Name        string       `json:"name" validate:"required,max=255"`
+	Price       entity.Money `json:"price" validate:"required"`
+	CategoryIDs []string     `json:"category_ids" validate:"dive,uuid"`
+}
+
+type CreateCategoryInput struct {
+	Name     string  `json:"name" validate:"required,max=255"`
+	ParentID *string `json:"parent_id" validate:"omitempty,uuid" format:"uuid"`
+}
+
+type CategoryBreadcrumb struct {
+	ID   entity.ID `json:"id"`
+	Name string    `json:"name"`
+}
+
+type CategoryOutput struct {
+	ID        entity.ID            `json:"id"`
+	Name      string               `json:"name"`
+	ParentID  *entity.ID           `json:"parent_id,omitempty"`
+	CreatedAt time.Time            `json:"created_at"`
+	Ancestors []CategoryBreadcrumb `json:"ancestors"`
+}
+
+type CreateStockMovementInput struct {
+	Type     string `json:"type" enums:"receipt,sale,adjustment,return,reservation,release" validate:"required,oneof=receipt sale adjustment return reservation release"`
+	Quantity int64  `json:"quantity" validate:"required"`
+	Reason   string `json:"reason" validate:"max=255"`
 }
 
 type CreateUserInput struct {
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Name     string `json:"name" validate:"required,max=100"`
+	Email    string `json:"email" validate:"required,email,max=254" format:"email"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+type UpdateUserInput struct {
+	Name  *string `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
+	Email *string `json:"email,omitempty" validate:"omitempty,email,max=254" format:"email"`
+}
+
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=8,max=72"`
+}
+
+type ForgotPasswordInput struct {
+	Email string `json:"email" validate:"required,email,max=254" format:"email"`
+}
+
+type ResetPasswordInput struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=8,max=72"`
+}
+
+type VerifyEmailInput struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type CreateAPIKeyInput struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" validate:"required,max=10,dive,oneof=products:read products:write products:delete products:manage_any categories:write stock:write"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// CreateAPIKeyOutput carries the only copy of the key the API ever returns.
+type CreateAPIKeyOutput struct {
+	ID        entity.ID  `json:"id"`
+	Name      string     `json:"name"`
+	Key       string     `json:"key"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
 }
 
 type UserLoginInput struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required" format:"email"`
+	Password string `json:"password" validate:"required"`
 }
 
 type UserLoginOutput struct {
-	AccessToken string `json:"access_token"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// MFAChallengeOutput answers a login with the right password for a user
+// with two-factor authentication, who still has to send a code to
+// /users/auth/mfa along with the challenge token.
+type MFAChallengeOutput struct {
+	MFARequired    bool   `json:"mfa_required" example:"true"`
+	ChallengeToken string `json:"challenge_token"`
+	ExpiresIn      int    `json:"expires_in"`
+}
+
+type MFALoginInput struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	// Code is a code of the authenticator app or an unused recovery code.
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+type MFACodeInput struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+// TOTPEnrollmentOutput is the secret to add to an authenticator app, by
+// hand or by scanning the otpauth URI as a QR code.
+type TOTPEnrollmentOutput struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// RecoveryCodesOutput carries the only copy of the recovery codes the API
+// ever returns.
+type RecoveryCodesOutput struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type RefreshTokenInput struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type LogoutInput struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// JSONWebKeySet documents the RFC 7517 key set served at
+// /.well-known/jwks.json.
+type JSONWebKeySet struct {
+	Keys []map[string]interface{} `json:"keys"`
 }
@@ -0,0 +1,37 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAPIKey(t *testing.T) {
+	now := time.Now()
+	expiresAt := now.Add(time.Hour)
+	key, plain, err := NewAPIKey(entityPkg.NewID(), " ci ", []Permission{PermissionProductsRead}, &expiresAt, now)
+
+	assert.Nil(t, err)
+	assert.True(t, IsAPIKey(plain))
+	assert.Equal(t, "ci", key.Name)
+	assert.Equal(t, plain[:12], key.Prefix)
+	assert.Equal(t, entityPkg.HashToken(plain), key.KeyHash)
+	assert.True(t, key.Allows(PermissionProductsRead))
+	assert.False(t, key.Allows(PermissionProductsWrite))
+	assert.True(t, key.IsUsable(now))
+	assert.False(t, key.IsUsable(expiresAt))
+
+	key.RevokedAt = &now
+	assert.False(t, key.IsUsable(now))
+}
+
+func TestNewAPIKeyValidatesScopesAndExpiry(t *testing.T) {
+	now := time.Now()
+	_, _, err := NewAPIKey(entityPkg.NewID(), "ci", []Permission{"products:everything"}, nil, now)
+	assert.Equal(t, ErrInvalidScope, err)
+
+	_, _, err = NewAPIKey(entityPkg.NewID(), "ci", []Permission{PermissionProductsRead}, &now, now)
+	assert.Equal(t, ErrExpiryInThePast, err)
+}
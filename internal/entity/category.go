@@ -0,0 +1,60 @@
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Mazzael/go-api/pkg/entity"
+)
+
+var (
+	ErrInvalidParent       = errors.New("invalid parent category")
+	ErrCategoryCycle       = errors.New("category cannot be moved under itself or one of its descendants")
+	ErrCategoryHasChildren = errors.New("category has child categories")
+	ErrCategoryNotFound    = errors.New("category not found")
+)
+
+type Category struct {
+	ID entity.ID `json:"id"`
+	// TenantID is the tenant whose catalog the category is in, set by the
+	// repository from the tenant it is created in.
+	TenantID  entity.ID  `json:"-" gorm:"type:varchar(36);index"`
+	Name      string     `json:"name"`
+	ParentID  *entity.ID `json:"parent_id,omitempty" gorm:"index"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func NewCategory(name string, parentID *entity.ID) (*Category, error) {
+	category := &Category{
+		ID:        entity.NewID(),
+		Name:      name,
+		ParentID:  parentID,
+		CreatedAt: time.Now(),
+	}
+
+	err := category.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+func (c *Category) Validate() error {
+	if c.ID.String() == "" {
+		return ErrIDIsRequired
+	}
+
+	if _, err := entity.ParseID(c.ID.String()); err != nil {
+		return ErrInvalidID
+	}
+
+	if c.Name == "" {
+		return ErrNameIsRequired
+	}
+
+	if c.ParentID != nil && *c.ParentID == c.ID {
+		return ErrInvalidParent
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package entity
+
+import (
+	"testing"
+
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCategory(t *testing.T) {
+	parentID := entityPkg.NewID()
+	category, err := NewCategory("Phones", &parentID)
+
+	assert.Nil(t, err)
+	assert.NotNil(t, category)
+	assert.NotEmpty(t, category.ID)
+	assert.Equal(t, "Phones", category.Name)
+	assert.Equal(t, parentID, *category.ParentID)
+	assert.NotEmpty(t, category.CreatedAt)
+}
+
+func TestCategoryWhenNameIsRequired(t *testing.T) {
+	category, err := NewCategory("", nil)
+
+	assert.Nil(t, category)
+	assert.Equal(t, ErrNameIsRequired, err)
+}
+
+func TestCategoryWhenParentIsItself(t *testing.T) {
+	category, _ := NewCategory("Phones", nil)
+	category.ParentID = &category.ID
+
+	assert.Equal(t, ErrInvalidParent, category.Validate())
+}
@@ -0,0 +1,81 @@
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Mazzael/go-api/pkg/entity"
+)
+
+var ErrLoginThrottled = errors.New("too many failed login attempts")
+
+// LoginPolicy decides how failed logins slow down further attempts. The
+// first FreeAttempts failures cost nothing, each one after that doubles the
+// wait from BaseDelay up to MaxDelay, and MaxFailures of them lock the key
+// out for Lockout. Failures older than Window are forgotten.
+type LoginPolicy struct {
+	FreeAttempts int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	MaxFailures  int
+	Lockout      time.Duration
+	Window       time.Duration
+}
+
+// LoginAttempts tracks the recent failed logins of a key, either an account
+// or a client IP.
+type LoginAttempts struct {
+	Key          string    `json:"key" gorm:"column:login_key;primaryKey;type:varchar(320)"`
+	Failures     int       `json:"failures"`
+	LastFailure  time.Time `json:"last_failure"`
+	BlockedUntil time.Time `json:"blocked_until" gorm:"index"`
+}
+
+// AccountLoginKey is the key failed logins for email in the tenant are
+// tracked under. It does not matter whether an account with the email
+// exists.
+func AccountLoginKey(tenantID entity.ID, email string) string {
+	return "account:" + tenantID.String() + ":" + NormalizeEmail(email)
+}
+
+// IPLoginKey is the key failed logins from a client IP are tracked under.
+func IPLoginKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Fail records a failed login at now and blocks the key as policy demands.
+func (a *LoginAttempts) Fail(now time.Time, policy LoginPolicy) {
+	if now.Sub(a.LastFailure) > policy.Window && !now.Before(a.BlockedUntil) {
+		a.Failures = 0
+	}
+	a.Failures++
+	a.LastFailure = now
+
+	switch {
+	case policy.MaxFailures > 0 && a.Failures >= policy.MaxFailures:
+		a.BlockedUntil = now.Add(policy.Lockout)
+	case a.Failures > policy.FreeAttempts:
+		delay := policy.BaseDelay
+		for i := policy.FreeAttempts + 1; i < a.Failures && delay < policy.MaxDelay; i++ {
+			delay *= 2
+		}
+		if delay > policy.MaxDelay {
+			delay = policy.MaxDelay
+		}
+		a.BlockedUntil = now.Add(delay)
+	}
+}
+
+// RetryAfter is how long the key has to wait before it may try again.
+func (a *LoginAttempts) RetryAfter(now time.Time) time.Duration {
+	if now.Before(a.BlockedUntil) {
+		return a.BlockedUntil.Sub(now)
+	}
+	return 0
+}
+
+// IsLockedOut reports whether the key reached MaxFailures and is still
+// serving the lockout.
+func (a *LoginAttempts) IsLockedOut(now time.Time, policy LoginPolicy) bool {
+	return policy.MaxFailures > 0 && a.Failures >= policy.MaxFailures && now.Before(a.BlockedUntil)
+}
@@ -0,0 +1,59 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+var testLoginPolicy = LoginPolicy{
+	FreeAttempts: 2,
+	BaseDelay:    time.Second,
+	MaxDelay:     4 * time.Second,
+	MaxFailures:  6,
+	Lockout:      15 * time.Minute,
+	Window:       time.Hour,
+}
+
+func TestLoginAttemptsBackOffThenLockOut(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	tenantID := entityPkg.NewID()
+	attempts := &LoginAttempts{Key: AccountLoginKey(tenantID, "Ana@Example.com")}
+	assert.Equal(t, "account:"+tenantID.String()+":ana@example.com", attempts.Key)
+
+	waits := []time.Duration{0, 0, time.Second, 2 * time.Second, 4 * time.Second, 15 * time.Minute}
+	for i, want := range waits {
+		attempts.Fail(now, testLoginPolicy)
+		assert.Equal(t, want, attempts.RetryAfter(now), "failure %d", i+1)
+	}
+	assert.True(t, attempts.IsLockedOut(now, testLoginPolicy))
+	assert.False(t, attempts.IsLockedOut(now.Add(15*time.Minute), testLoginPolicy))
+}
+
+func TestLoginAttemptsCapTheBackoff(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	policy := testLoginPolicy
+	policy.MaxFailures = 0
+
+	attempts := &LoginAttempts{}
+	for i := 0; i < 40; i++ {
+		attempts.Fail(now, policy)
+	}
+	assert.Equal(t, policy.MaxDelay, attempts.RetryAfter(now))
+	assert.False(t, attempts.IsLockedOut(now, policy))
+}
+
+func TestLoginAttemptsForgetOldFailures(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	attempts := &LoginAttempts{}
+	for i := 0; i < 4; i++ {
+		attempts.Fail(now, testLoginPolicy)
+	}
+
+	later := now.Add(2 * time.Hour)
+	attempts.Fail(later, testLoginPolicy)
+	assert.Equal(t, 1, attempts.Failures)
+	assert.Equal(t, time.Duration(0), attempts.RetryAfter(later))
+}
@@ -0,0 +1,153 @@
+package entity
+
+import (
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Mazzael/go-api/pkg/entity"
+	"github.com/Mazzael/go-api/pkg/totp"
+)
+
+// RecoveryCodeCount is how many recovery codes a user gets at a time.
+const RecoveryCodeCount = 10
+
+// totpSkew is how many periods the clock of an authenticator app may be off.
+const totpSkew = 1
+
+var (
+	ErrMFAEnabled          = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnrolled      = errors.New("two-factor authentication is not enrolled")
+	ErrInvalidMFACode      = errors.New("code is invalid or was already used")
+	ErrInvalidMFAChallenge = errors.New("challenge token is invalid or has expired")
+)
+
+// HasMFA reports whether logging in takes a second factor.
+func (u *User) HasMFA() bool {
+	return u.TOTPEnabledAt != nil
+}
+
+// EnrollTOTP gives the user a new TOTP secret to add to an authenticator
+// app. It takes effect once confirmed with a code; enrolling again before
+// that replaces the secret.
+func (u *User) EnrollTOTP() (totp.Key, error) {
+	if u.HasMFA() {
+		return totp.Key{}, ErrMFAEnabled
+	}
+
+	key, err := totp.Generate()
+	if err != nil {
+		return totp.Key{}, err
+	}
+	u.TOTPSecret = key.Encoded()
+	u.TOTPLastStep = 0
+	return key, nil
+}
+
+// ConfirmTOTP turns TOTP on with a code from the app just enrolled, which
+// proves it holds the secret.
+func (u *User) ConfirmTOTP(code string, now time.Time) error {
+	if u.HasMFA() {
+		return ErrMFAEnabled
+	}
+	if u.TOTPSecret == "" {
+		return ErrMFANotEnrolled
+	}
+	if err := u.checkTOTP(code, now); err != nil {
+		return err
+	}
+	u.TOTPEnabledAt = &now
+	return nil
+}
+
+// CheckTOTP accepts a code of the authenticator app of the user, each at
+// most once.
+func (u *User) CheckTOTP(code string, now time.Time) error {
+	if !u.HasMFA() {
+		return ErrMFANotEnrolled
+	}
+	return u.checkTOTP(code, now)
+}
+
+func (u *User) checkTOTP(code string, now time.Time) error {
+	key, err := totp.Parse(u.TOTPSecret)
+	if err != nil {
+		return err
+	}
+	step, ok := key.Validate(strings.TrimSpace(code), now, totpSkew)
+	if !ok || step <= u.TOTPLastStep {
+		return ErrInvalidMFACode
+	}
+	u.TOTPLastStep = step
+	return nil
+}
+
+// DisableTOTP turns TOTP off and forgets the secret.
+func (u *User) DisableTOTP() {
+	u.TOTPSecret = ""
+	u.TOTPEnabledAt = nil
+	u.TOTPLastStep = 0
+}
+
+// IsTOTPCode reports whether code looks like a code of an authenticator app
+// rather than a recovery code.
+func IsTOTPCode(code string) bool {
+	code = strings.TrimSpace(code)
+	if len(code) != totp.DefaultDigits {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// RecoveryCode stands in for a TOTP code once, for users who lost their
+// authenticator app. Only its hash is stored.
+type RecoveryCode struct {
+	ID        entity.ID  `json:"id"`
+	UserID    entity.ID  `json:"user_id" gorm:"index"`
+	CodeHash  string     `json:"-" gorm:"uniqueIndex"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// recoveryCodeAlphabet is Crockford's base32, which leaves out letters
+// easily mistaken for digits. Its 32 symbols take 5 bits each.
+const recoveryCodeAlphabet = "0123456789abcdefghjkmnpqrstvwxyz"
+
+// NewRecoveryCodes returns RecoveryCodeCount codes to store and their plain
+// text values, like "k7m2p-x9qrt", to show the user once.
+func NewRecoveryCodes(userID entity.ID, now time.Time) ([]*RecoveryCode, []string, error) {
+	codes := make([]*RecoveryCode, RecoveryCodeCount)
+	plain := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		for j := range b {
+			b[j] = recoveryCodeAlphabet[b[j]&31]
+		}
+
+		plain[i] = string(b[:5]) + "-" + string(b[5:])
+		codes[i] = &RecoveryCode{
+			ID:        entity.NewID(),
+			UserID:    userID,
+			CodeHash:  HashRecoveryCode(plain[i]),
+			CreatedAt: now,
+		}
+	}
+	return codes, plain, nil
+}
+
+// HashRecoveryCode hashes code as typed by the user, in any case and with
+// or without the dash and spaces.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	return entity.HashToken(code)
+}
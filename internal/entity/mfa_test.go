@@ -0,0 +1,80 @@
+package entity
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mazzael/go-api/pkg/totp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTOTPEnrollment(t *testing.T) {
+	user, _ := NewUser("John Doe", "johndoe@example.com", "123456")
+	now := time.Now()
+
+	assert.ErrorIs(t, user.ConfirmTOTP("123456", now), ErrMFANotEnrolled)
+	key, err := user.EnrollTOTP()
+	assert.NoError(t, err)
+	assert.Equal(t, key.Encoded(), user.TOTPSecret)
+	assert.False(t, user.HasMFA())
+	assert.ErrorIs(t, user.CheckTOTP(key.Code(now), now), ErrMFANotEnrolled)
+
+	assert.ErrorIs(t, user.ConfirmTOTP(wrongCode(key, now), now), ErrInvalidMFACode)
+	assert.NoError(t, user.ConfirmTOTP(key.Code(now), now))
+	assert.True(t, user.HasMFA())
+
+	_, err = user.EnrollTOTP()
+	assert.ErrorIs(t, err, ErrMFAEnabled)
+
+	user.DisableTOTP()
+	assert.False(t, user.HasMFA())
+	assert.Empty(t, user.TOTPSecret)
+}
+
+func TestCheckTOTPRefusesReplayedCodes(t *testing.T) {
+	user, _ := NewUser("John Doe", "johndoe@example.com", "123456")
+	now := time.Now()
+	key, _ := user.EnrollTOTP()
+	assert.NoError(t, user.ConfirmTOTP(key.Code(now.Add(-30*time.Second)), now))
+
+	assert.NoError(t, user.CheckTOTP(key.Code(now), now))
+	assert.ErrorIs(t, user.CheckTOTP(key.Code(now), now), ErrInvalidMFACode)
+	// nor an older code still within the skew.
+	assert.ErrorIs(t, user.CheckTOTP(key.Code(now.Add(-30*time.Second)), now), ErrInvalidMFACode)
+
+	later := now.Add(30 * time.Second)
+	assert.NoError(t, user.CheckTOTP(" "+key.Code(later)+" ", later))
+}
+
+func TestNewRecoveryCodes(t *testing.T) {
+	user, _ := NewUser("John Doe", "johndoe@example.com", "123456")
+	codes, plain, err := NewRecoveryCodes(user.ID, time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, codes, RecoveryCodeCount)
+	assert.Len(t, plain, RecoveryCodeCount)
+
+	seen := map[string]bool{}
+	for i, code := range plain {
+		assert.Regexp(t, regexp.MustCompile(`^[0-9a-hjkmnp-tv-z]{5}-[0-9a-hjkmnp-tv-z]{5}$`), code)
+		assert.False(t, IsTOTPCode(code))
+		assert.Equal(t, user.ID, codes[i].UserID)
+		assert.Equal(t, codes[i].CodeHash, HashRecoveryCode(strings.ToUpper(strings.ReplaceAll(code, "-", " "))))
+		assert.NotContains(t, codes[i].CodeHash, code)
+		seen[code] = true
+	}
+	assert.Len(t, seen, RecoveryCodeCount)
+}
+
+func TestIsTOTPCode(t *testing.T) {
+	assert.True(t, IsTOTPCode("012345"))
+	assert.True(t, IsTOTPCode(" 012345 "))
+	assert.False(t, IsTOTPCode("01234"))
+	assert.False(t, IsTOTPCode("01234a"))
+}
+
+// wrongCode is a code of key that is not valid at now.
+func wrongCode(key totp.Key, now time.Time) string {
+	return key.Code(now.Add(time.Hour))
+}
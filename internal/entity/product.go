@@ -13,16 +13,26 @@ var (
 	ErrNameIsRequired  = errors.New("name is required")
 	ErrPriceIsRequired = errors.New("price is required")
 	ErrInvalidPrice    = errors.New("invalid price")
+	ErrInvalidCurrency = errors.New("invalid currency")
+	ErrNotProductOwner = errors.New("only the user who created the product may change it")
 )
 
 type Product struct {
-	ID        entity.ID `json:"id"`
-	Name      string    `json:"name"`
-	Price     float64   `json:"price"`
-	CreatedAt time.Time `json:"created_at"`
+	ID entity.ID `json:"id"`
+	// TenantID is the tenant whose catalog the product is in, set by the
+	// repository from the tenant it is created in.
+	TenantID   entity.ID    `json:"-" gorm:"type:varchar(36);index"`
+	Name       string       `json:"name"`
+	Price      entity.Money `json:"price" gorm:"embedded;embeddedPrefix:price_"`
+	Categories []*Category  `json:"categories,omitempty" gorm:"many2many:product_categories"`
+	CreatedAt  time.Time    `json:"created_at"`
+	// CreatedBy and UpdatedBy are the users who created the product and
+	// last changed it; nil for products from before they were recorded.
+	CreatedBy *entity.ID `json:"created_by" gorm:"type:varchar(36);index"`
+	UpdatedBy *entity.ID `json:"updated_by" gorm:"type:varchar(36)"`
 }
 
-func NewProduct(name string, price float64) (*Product, error) {
+func NewProduct(name string, price entity.Money) (*Product, error) {
 	product := &Product{
 		ID:        entity.NewID(),
 		Name:      name,
@@ -37,6 +47,11 @@ func NewProduct(name string, price float64) (*Product, error) {
 	return product, nil
 }
 
+// IsOwnedBy reports whether userID created the product.
+func (p *Product) IsOwnedBy(userID entity.ID) bool {
+	return p.CreatedBy != nil && *p.CreatedBy == userID
+}
+
 func (p *Product) Validate() error {
 	if p.ID.String() == "" {
 		return ErrIDIsRequired
@@ -50,13 +65,17 @@ func (p *Product) Validate() error {
 		return ErrNameIsRequired
 	}
 
-	if p.Price == 0 {
+	if p.Price.IsZero() {
 		return ErrPriceIsRequired
 	}
 
-	if p.Price < 0 {
+	if p.Price.IsNegative() {
 		return ErrInvalidPrice
 	}
 
+	if !p.Price.IsValid() {
+		return ErrInvalidCurrency
+	}
+
 	return nil
 }
@@ -3,22 +3,23 @@ package entity
 import (
 	"testing"
 
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestNewProduct(t *testing.T) {
-	product, err := NewProduct("Test Product", 100.0)
+	product, err := NewProduct("Test Product", entityPkg.MustParseMoney("100.00", "USD"))
 
 	assert.Nil(t, err)
 	assert.NotNil(t, product)
 	assert.NotEmpty(t, product.ID)
 	assert.Equal(t, "Test Product", product.Name)
-	assert.Equal(t, 100.0, product.Price)
+	assert.Equal(t, entityPkg.Money{Amount: 10000, Currency: "USD"}, product.Price)
 	assert.NotEmpty(t, product.CreatedAt)
 }
 
 func TestProductWhenNameIsRequired(t *testing.T) {
-	product, err := NewProduct("", 100)
+	product, err := NewProduct("", entityPkg.MustParseMoney("100", "USD"))
 
 	assert.NotNil(t, err)
 	assert.Nil(t, product)
@@ -26,7 +27,7 @@ func TestProductWhenNameIsRequired(t *testing.T) {
 }
 
 func TestProductWhenPriceIsRequired(t *testing.T) {
-	product, err := NewProduct("Test Product", 0)
+	product, err := NewProduct("Test Product", entityPkg.Money{Currency: "USD"})
 
 	assert.NotNil(t, err)
 	assert.Nil(t, product)
@@ -34,17 +35,35 @@ func TestProductWhenPriceIsRequired(t *testing.T) {
 }
 
 func TestProductWhenPriceIsInvalid(t *testing.T) {
-	product, err := NewProduct("Test Product", -100)
+	product, err := NewProduct("Test Product", entityPkg.MustParseMoney("-100", "USD"))
 
 	assert.NotNil(t, err)
 	assert.Nil(t, product)
 	assert.Equal(t, ErrInvalidPrice, err)
 }
 
+func TestProductWhenCurrencyIsInvalid(t *testing.T) {
+	product, err := NewProduct("Test Product", entityPkg.Money{Amount: 100, Currency: "XXX"})
+
+	assert.NotNil(t, err)
+	assert.Nil(t, product)
+	assert.Equal(t, ErrInvalidCurrency, err)
+}
+
 func TestProduct_Validate(t *testing.T) {
-	product, _ := NewProduct("Test Product", 100)
+	product, _ := NewProduct("Test Product", entityPkg.MustParseMoney("100", "USD"))
 
 	err := product.Validate()
 
 	assert.Nil(t, err)
 }
+
+func TestProduct_IsOwnedBy(t *testing.T) {
+	product, _ := NewProduct("Test Product", entityPkg.MustParseMoney("100", "USD"))
+	owner := entityPkg.NewID()
+
+	assert.False(t, product.IsOwnedBy(owner))
+	product.CreatedBy = &owner
+	assert.True(t, product.IsOwnedBy(owner))
+	assert.False(t, product.IsOwnedBy(entityPkg.NewID()))
+}
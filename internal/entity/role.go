@@ -0,0 +1,67 @@
+package entity
+
+import "errors"
+
+var ErrInvalidRole = errors.New("invalid role")
+
+type Role string
+
+const (
+	RoleAdmin  Role = "admin"
+	RoleEditor Role = "editor"
+	RoleViewer Role = "viewer"
+)
+
+type Permission string
+
+const (
+	PermissionProductsRead    Permission = "products:read"
+	PermissionProductsWrite   Permission = "products:write"
+	PermissionProductsDelete  Permission = "products:delete"
+	PermissionCategoriesWrite Permission = "categories:write"
+	PermissionStockWrite      Permission = "stock:write"
+	PermissionUsersManage     Permission = "users:manage"
+
+	// PermissionProductsManageAny changes and deletes products created by
+	// other users when products are owner-only.
+	PermissionProductsManageAny Permission = "products:manage_any"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleAdmin: {
+		PermissionProductsRead,
+		PermissionProductsWrite,
+		PermissionProductsDelete,
+		PermissionProductsManageAny,
+		PermissionCategoriesWrite,
+		PermissionStockWrite,
+		PermissionUsersManage,
+	},
+	RoleEditor: {
+		PermissionProductsRead,
+		PermissionProductsWrite,
+		PermissionCategoriesWrite,
+		PermissionStockWrite,
+	},
+	RoleViewer: {
+		PermissionProductsRead,
+	},
+}
+
+func ParseRole(s string) (Role, error) {
+	role := Role(s)
+	if _, ok := rolePermissions[role]; !ok {
+		return "", ErrInvalidRole
+	}
+	return role, nil
+}
+
+// Can reports whether the role grants the permission.
+func (r Role) Can(permission Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,29 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRole(t *testing.T) {
+	role, err := ParseRole("editor")
+	assert.Nil(t, err)
+	assert.Equal(t, RoleEditor, role)
+
+	_, err = ParseRole("root")
+	assert.Equal(t, ErrInvalidRole, err)
+}
+
+func TestRole_Can(t *testing.T) {
+	assert.True(t, RoleAdmin.Can(PermissionProductsDelete))
+	assert.True(t, RoleAdmin.Can(PermissionUsersManage))
+
+	assert.True(t, RoleEditor.Can(PermissionProductsWrite))
+	assert.False(t, RoleEditor.Can(PermissionProductsDelete))
+
+	assert.True(t, RoleViewer.Can(PermissionProductsRead))
+	assert.False(t, RoleViewer.Can(PermissionProductsWrite))
+
+	assert.False(t, Role("").Can(PermissionProductsRead))
+}
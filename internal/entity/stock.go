@@ -0,0 +1,106 @@
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Mazzael/go-api/pkg/entity"
+)
+
+var (
+	ErrInvalidMovementType = errors.New("invalid stock movement type")
+	ErrInvalidQuantity     = errors.New("invalid quantity")
+	ErrInsufficientStock   = errors.New("insufficient stock")
+)
+
+type MovementType string
+
+const (
+	MovementReceipt     MovementType = "receipt"
+	MovementSale        MovementType = "sale"
+	MovementAdjustment  MovementType = "adjustment"
+	MovementReturn      MovementType = "return"
+	MovementReservation MovementType = "reservation"
+	MovementRelease     MovementType = "release"
+)
+
+// StockLevel is the current stock of a product. It is only ever changed by
+// applying a StockMovement.
+type StockLevel struct {
+	ProductID entity.ID `json:"product_id" gorm:"primaryKey"`
+	OnHand    int64     `json:"on_hand"`
+	Reserved  int64     `json:"reserved"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Available is the quantity that can still be sold or reserved.
+func (s *StockLevel) Available() int64 {
+	return s.OnHand - s.Reserved
+}
+
+// StockMovement is an entry of the append-only stock ledger.
+type StockMovement struct {
+	ID            entity.ID    `json:"id"`
+	ProductID     entity.ID    `json:"product_id" gorm:"index"`
+	Type          MovementType `json:"type"`
+	Quantity      int64        `json:"quantity"`
+	OnHandDelta   int64        `json:"on_hand_delta"`
+	ReservedDelta int64        `json:"reserved_delta"`
+	OnHandAfter   int64        `json:"on_hand_after"`
+	ReservedAfter int64        `json:"reserved_after"`
+	Reason        string       `json:"reason,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
+
+// NewStockMovement builds a ledger entry. Quantity must be positive for
+// every type except adjustment, which takes a signed correction.
+func NewStockMovement(productID entity.ID, movementType MovementType, quantity int64, reason string) (*StockMovement, error) {
+	movement := &StockMovement{
+		ID:        entity.NewID(),
+		ProductID: productID,
+		Type:      movementType,
+		Quantity:  quantity,
+		Reason:    reason,
+		CreatedAt: time.Now(),
+	}
+
+	switch movementType {
+	case MovementReceipt, MovementReturn:
+		movement.OnHandDelta = quantity
+	case MovementSale:
+		movement.OnHandDelta = -quantity
+	case MovementAdjustment:
+		movement.OnHandDelta = quantity
+	case MovementReservation:
+		movement.ReservedDelta = quantity
+	case MovementRelease:
+		movement.ReservedDelta = -quantity
+	}
+
+	err := movement.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return movement, nil
+}
+
+func (m *StockMovement) Validate() error {
+	if _, err := entity.ParseID(m.ProductID.String()); err != nil {
+		return ErrInvalidID
+	}
+
+	switch m.Type {
+	case MovementAdjustment:
+		if m.Quantity == 0 {
+			return ErrInvalidQuantity
+		}
+	case MovementReceipt, MovementReturn, MovementSale, MovementReservation, MovementRelease:
+		if m.Quantity <= 0 {
+			return ErrInvalidQuantity
+		}
+	default:
+		return ErrInvalidMovementType
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package entity
+
+import (
+	"testing"
+
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewStockMovement(t *testing.T) {
+	productID := entityPkg.NewID()
+
+	tests := []struct {
+		movementType  MovementType
+		quantity      int64
+		onHandDelta   int64
+		reservedDelta int64
+	}{
+		{MovementReceipt, 10, 10, 0},
+		{MovementReturn, 2, 2, 0},
+		{MovementSale, 3, -3, 0},
+		{MovementAdjustment, -4, -4, 0},
+		{MovementReservation, 5, 0, 5},
+		{MovementRelease, 5, 0, -5},
+	}
+
+	for _, tt := range tests {
+		movement, err := NewStockMovement(productID, tt.movementType, tt.quantity, "")
+		assert.Nil(t, err)
+		assert.NotEmpty(t, movement.ID)
+		assert.Equal(t, productID, movement.ProductID)
+		assert.Equal(t, tt.onHandDelta, movement.OnHandDelta, tt.movementType)
+		assert.Equal(t, tt.reservedDelta, movement.ReservedDelta, tt.movementType)
+	}
+}
+
+func TestStockMovementWhenQuantityIsInvalid(t *testing.T) {
+	productID := entityPkg.NewID()
+
+	_, err := NewStockMovement(productID, MovementSale, 0, "")
+	assert.Equal(t, ErrInvalidQuantity, err)
+
+	_, err = NewStockMovement(productID, MovementReceipt, -1, "")
+	assert.Equal(t, ErrInvalidQuantity, err)
+
+	_, err = NewStockMovement(productID, MovementAdjustment, 0, "")
+	assert.Equal(t, ErrInvalidQuantity, err)
+}
+
+func TestStockMovementWhenTypeIsInvalid(t *testing.T) {
+	movement, err := NewStockMovement(entityPkg.NewID(), "theft", 1, "")
+
+	assert.Nil(t, movement)
+	assert.Equal(t, ErrInvalidMovementType, err)
+}
+
+func TestStockLevel_Available(t *testing.T) {
+	level := StockLevel{OnHand: 10, Reserved: 3}
+
+	assert.Equal(t, int64(7), level.Available())
+}
@@ -0,0 +1,57 @@
+package entity
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Mazzael/go-api/pkg/entity"
+)
+
+// DefaultTenantSlug names the tenant that requests naming no other tenant act
+// in, which holds every user and product from before tenants existed.
+const DefaultTenantSlug = "default"
+
+var (
+	ErrInvalidTenantSlug = errors.New("slug must be 1 to 63 lower case letters, digits and inner dashes")
+	ErrTenantSlugTaken   = errors.New("tenant slug is already taken")
+	ErrUnknownTenant     = errors.New("unknown tenant")
+)
+
+var tenantSlug = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// Tenant is a team sharing the deployment with others. Users and products
+// belong to exactly one tenant and are invisible to every other; emails only
+// have to be unique within a tenant.
+type Tenant struct {
+	ID        entity.ID `json:"id"`
+	Slug      string    `json:"slug" gorm:"type:varchar(63);uniqueIndex"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewTenant(slug, name string) (*Tenant, error) {
+	tenant := &Tenant{
+		ID:        entity.NewID(),
+		Slug:      strings.ToLower(strings.TrimSpace(slug)),
+		Name:      name,
+		CreatedAt: time.Now(),
+	}
+
+	err := tenant.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return tenant, nil
+}
+
+func (t *Tenant) Validate() error {
+	if !tenantSlug.MatchString(t.Slug) {
+		return ErrInvalidTenantSlug
+	}
+	if strings.TrimSpace(t.Name) == "" {
+		return ErrNameIsRequired
+	}
+	return nil
+}
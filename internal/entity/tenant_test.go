@@ -0,0 +1,36 @@
+package entity
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTenant(t *testing.T) {
+	tenant, err := NewTenant(" Acme-Labs ", "Acme Labs")
+
+	assert.Nil(t, err)
+	assert.NotEmpty(t, tenant.ID)
+	assert.Equal(t, "acme-labs", tenant.Slug)
+	assert.Equal(t, "Acme Labs", tenant.Name)
+	assert.NotEmpty(t, tenant.CreatedAt)
+}
+
+func TestTenantSlugs(t *testing.T) {
+	for _, slug := range []string{"a", "team-1", strings.Repeat("a", 63)} {
+		_, err := NewTenant(slug, "Team")
+		assert.Nil(t, err, slug)
+	}
+	for _, slug := range []string{"", "-team", "team-", "team_1", "t e", "tëam", strings.Repeat("a", 64)} {
+		_, err := NewTenant(slug, "Team")
+		assert.Equal(t, ErrInvalidTenantSlug, err, slug)
+	}
+}
+
+func TestTenantWhenNameIsRequired(t *testing.T) {
+	tenant, err := NewTenant("acme", " ")
+
+	assert.Nil(t, tenant)
+	assert.Equal(t, ErrNameIsRequired, err)
+}
@@ -0,0 +1,73 @@
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Mazzael/go-api/pkg/entity"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenExpired = errors.New("refresh token expired")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+	ErrTokenRevoked        = errors.New("token has been revoked")
+)
+
+// RefreshToken is a single use token exchanged for a new access token. Every
+// rotation issues a new token in the same family, so the family tracks one
+// login session.
+type RefreshToken struct {
+	ID           entity.ID  `json:"id"`
+	UserID       entity.ID  `json:"user_id" gorm:"index"`
+	FamilyID     entity.ID  `json:"family_id" gorm:"index"`
+	TokenHash    string     `json:"-" gorm:"uniqueIndex"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	ReplacedByID *entity.ID `json:"replaced_by_id"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// NewRefreshToken returns the token to store and the plain text value to
+// hand to the client. Pass a zero familyID to start a new family.
+func NewRefreshToken(userID, familyID entity.ID, ttl time.Duration) (*RefreshToken, string, error) {
+	plain, err := entity.NewToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if familyID == (entity.ID{}) {
+		familyID = entity.NewID()
+	}
+
+	now := time.Now()
+	return &RefreshToken{
+		ID:        entity.NewID(),
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: entity.HashToken(plain),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}, plain, nil
+}
+
+func (t *RefreshToken) IsExpired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
+
+func (t *RefreshToken) IsRevoked() bool {
+	return t.RevokedAt != nil
+}
+
+// IsRotated reports whether the token was already exchanged for a newer one.
+// Presenting a rotated token again means it leaked.
+func (t *RefreshToken) IsRotated() bool {
+	return t.ReplacedByID != nil
+}
+
+// RevokedAccessToken blacklists the jti of an access token until the token
+// would have expired anyway.
+type RevokedAccessToken struct {
+	JTI       string    `json:"jti" gorm:"primaryKey"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+}
@@ -0,0 +1,51 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRefreshToken(t *testing.T) {
+	userID := entityPkg.NewID()
+	token, plain, err := NewRefreshToken(userID, entityPkg.ID{}, time.Hour)
+
+	assert.Nil(t, err)
+	assert.NotEmpty(t, plain)
+	assert.Equal(t, userID, token.UserID)
+	assert.NotEqual(t, entityPkg.ID{}, token.FamilyID)
+	assert.Equal(t, entityPkg.HashToken(plain), token.TokenHash)
+	assert.NotContains(t, token.TokenHash, plain)
+	assert.False(t, token.IsExpired(time.Now()))
+	assert.True(t, token.IsExpired(time.Now().Add(2*time.Hour)))
+	assert.False(t, token.IsRevoked())
+	assert.False(t, token.IsRotated())
+}
+
+func TestNewRefreshTokenKeepsFamily(t *testing.T) {
+	first, _, _ := NewRefreshToken(entityPkg.NewID(), entityPkg.ID{}, time.Hour)
+	next, _, err := NewRefreshToken(first.UserID, first.FamilyID, time.Hour)
+
+	assert.Nil(t, err)
+	assert.Equal(t, first.FamilyID, next.FamilyID)
+	assert.NotEqual(t, first.ID, next.ID)
+	assert.NotEqual(t, first.TokenHash, next.TokenHash)
+}
+
+func TestNewUserToken(t *testing.T) {
+	userID := entityPkg.NewID()
+	token, plain, err := NewUserToken(userID, TokenPurposePasswordReset, time.Hour)
+
+	assert.Nil(t, err)
+	assert.Equal(t, userID, token.UserID)
+	assert.Equal(t, TokenPurposePasswordReset, token.Purpose)
+	assert.Equal(t, entityPkg.HashToken(plain), token.TokenHash)
+	assert.True(t, token.IsUsable(time.Now()))
+	assert.False(t, token.IsUsable(time.Now().Add(2*time.Hour)))
+
+	now := time.Now()
+	token.UsedAt = &now
+	assert.False(t, token.IsUsable(now))
+}
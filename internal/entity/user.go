@@ -1,15 +1,44 @@
 package entity
 
 import (
+	"errors"
+	"strings"
+	"time"
+
 	"github.com/Mazzael/go-api/pkg/entity"
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrEmailTaken        = errors.New("email is already registered")
+	ErrIncorrectPassword = errors.New("current password is incorrect")
+	ErrUserDisabled      = errors.New("user account is disabled")
+	ErrLastAdmin         = errors.New("the last admin cannot be removed, disabled or demoted")
+	ErrEmailUnverified   = errors.New("email address is not verified")
+)
+
 type User struct {
-	ID       entity.ID `json:"id"`
+	ID entity.ID `json:"id"`
+	// TenantID is the tenant the user belongs to, set by the repository
+	// from the tenant it is created in. Emails are unique per tenant.
+	TenantID entity.ID `json:"tenant_id" gorm:"type:varchar(36);uniqueIndex:idx_users_tenant_email,priority:1"`
 	Name     string    `json:"name"`
-	Email    string    `json:"email"`
+	Email    string    `json:"email" gorm:"type:varchar(254);uniqueIndex:idx_users_tenant_email,priority:2"`
 	Password string    `json:"-"`
+	Role     Role      `json:"role"`
+	Disabled bool      `json:"disabled"`
+	// EmailVerifiedAt is when the user proved they own Email, nil until
+	// they do.
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	// TOTPSecret is the base32 secret of the authenticator app of the
+	// user, set once they start enrolling one.
+	TOTPSecret string `json:"-" gorm:"type:varchar(64)"`
+	// TOTPEnabledAt is when the user confirmed enrolling, from which on
+	// logging in takes a code too; nil while TOTP is off.
+	TOTPEnabledAt *time.Time `json:"totp_enabled_at"`
+	// TOTPLastStep is the time step of the last code accepted, which is
+	// not accepted again.
+	TOTPLastStep int64 `json:"-"`
 }
 
 func NewUser(name, email, password string) (*User, error) {
@@ -21,12 +50,59 @@ func NewUser(name, email, password string) (*User, error) {
 	return &User{
 		ID:       entity.NewID(),
 		Name:     name,
-		Email:    email,
+		Email:    NormalizeEmail(email),
 		Password: string(hash),
+		Role:     RoleViewer,
 	}, nil
 }
 
+// NormalizeEmail trims and lower cases email, so that addresses differing
+// only in case belong to the same user.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
 func (u *User) ValidatePassword(password string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
 	return err == nil
 }
+
+// ChangePassword replaces the password with next, provided current is the
+// password in use.
+func (u *User) ChangePassword(current, next string) error {
+	if !u.ValidatePassword(current) {
+		return ErrIncorrectPassword
+	}
+	return u.SetPassword(next)
+}
+
+// SetPassword replaces the password without asking for the current one,
+// for users who proved their identity otherwise.
+func (u *User) SetPassword(next string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(next), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	u.Password = string(hash)
+	return nil
+}
+
+func (u *User) IsVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+func (u *User) VerifyEmail(now time.Time) {
+	if u.EmailVerifiedAt == nil {
+		u.EmailVerifiedAt = &now
+	}
+}
+
+// ChangeEmail sets a new email, which has to be verified again unless it
+// only differs in case or surrounding spaces.
+func (u *User) ChangeEmail(email string) {
+	email = NormalizeEmail(email)
+	if email != u.Email {
+		u.Email = email
+		u.EmailVerifiedAt = nil
+	}
+}
@@ -2,6 +2,7 @@ package entity
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -15,6 +16,7 @@ func TestNewUser(t *testing.T) {
 	assert.NotEmpty(t, user.Password)
 	assert.Equal(t, "John Doe", user.Name)
 	assert.Equal(t, "johndoe@example.com", user.Email)
+	assert.Equal(t, RoleViewer, user.Role)
 }
 
 func TestUser_ValidatePassword(t *testing.T) {
@@ -25,3 +27,38 @@ func TestUser_ValidatePassword(t *testing.T) {
 	assert.False(t, user.ValidatePassword("654321"))
 	assert.NotEqual(t, "123456", user.Password)
 }
+
+func TestNewUserNormalizesEmail(t *testing.T) {
+	user, err := NewUser("John Doe", "  JohnDoe@Example.COM ", "123456")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "johndoe@example.com", user.Email)
+}
+
+func TestUser_ChangePassword(t *testing.T) {
+	user, err := NewUser("John Doe", "johndoe@example.com", "123456")
+	assert.Nil(t, err)
+
+	assert.Equal(t, ErrIncorrectPassword, user.ChangePassword("wrong", "new-password"))
+	assert.True(t, user.ValidatePassword("123456"))
+
+	assert.Nil(t, user.ChangePassword("123456", "new-password"))
+	assert.True(t, user.ValidatePassword("new-password"))
+	assert.False(t, user.ValidatePassword("123456"))
+}
+
+func TestUser_ChangeEmailRequiresVerifyingAgain(t *testing.T) {
+	user, err := NewUser("John Doe", "johndoe@example.com", "123456")
+	assert.Nil(t, err)
+	assert.False(t, user.IsVerified())
+
+	user.VerifyEmail(time.Now())
+	assert.True(t, user.IsVerified())
+
+	user.ChangeEmail(" JohnDoe@example.com")
+	assert.True(t, user.IsVerified())
+
+	user.ChangeEmail("john@example.com")
+	assert.Equal(t, "john@example.com", user.Email)
+	assert.False(t, user.IsVerified())
+}
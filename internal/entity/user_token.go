@@ -0,0 +1,59 @@
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Mazzael/go-api/pkg/entity"
+)
+
+var ErrInvalidUserToken = errors.New("token is invalid or has expired")
+
+// TokenPurpose is what a UserToken may be used for. A token of one purpose
+// is never accepted for another.
+type TokenPurpose string
+
+const (
+	TokenPurposeEmailVerification TokenPurpose = "email_verification"
+	TokenPurposePasswordReset     TokenPurpose = "password_reset"
+	// TokenPurposeMFAChallenge tokens are handed out by a login that checked
+	// the password and still waits for a second factor.
+	TokenPurposeMFAChallenge TokenPurpose = "mfa_challenge"
+)
+
+// UserToken is a single use token mailed to a user to prove they own the
+// address, either to verify it or to reset their password, or the challenge
+// of a login waiting for a second factor. Only its hash is stored.
+type UserToken struct {
+	ID        entity.ID    `json:"id"`
+	UserID    entity.ID    `json:"user_id" gorm:"index"`
+	Purpose   TokenPurpose `json:"purpose"`
+	TokenHash string       `json:"-" gorm:"uniqueIndex"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	UsedAt    *time.Time   `json:"used_at"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// NewUserToken returns the token to store and the plain text value to mail
+// to the user.
+func NewUserToken(userID entity.ID, purpose TokenPurpose, ttl time.Duration) (*UserToken, string, error) {
+	plain, err := entity.NewToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	return &UserToken{
+		ID:        entity.NewID(),
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: entity.HashToken(plain),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}, plain, nil
+}
+
+// IsUsable reports whether the token can still be redeemed at now.
+func (t *UserToken) IsUsable(now time.Time) bool {
+	return t.UsedAt == nil && now.Before(t.ExpiresAt)
+}
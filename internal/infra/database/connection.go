@@ -0,0 +1,181 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/Mazzael/go-api/pkg/health"
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+	DriverMySQL    = "mysql"
+
+	// SQLiteInMemory can be used as the database name to keep a sqlite
+	// database in memory instead of on disk.
+	SQLiteInMemory = ":memory:"
+)
+
+var (
+	ErrUnknownDriver      = errors.New("unknown database driver")
+	ErrDatabaseIsRequired = errors.New("database name is required")
+)
+
+type Config struct {
+	Driver          string
+	Host            string
+	Port            string
+	User            string
+	Password        string
+	Name            string
+	SSLMode         string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// NewConnection opens a *gorm.DB for the driver named in cfg and applies the
+// connection pool settings.
+func NewConnection(cfg Config, gormConfig *gorm.Config) (*gorm.DB, error) {
+	dialector, err := NewDialector(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if gormConfig == nil {
+		gormConfig = &gorm.Config{}
+	}
+
+	db, err := gorm.Open(dialector, gormConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Driver == DriverSQLite && cfg.Name == SQLiteInMemory {
+		// every new connection to ":memory:" gets its own empty database, so
+		// the pool must keep exactly one connection alive.
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		return db, nil
+	}
+
+	if cfg.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+
+	return db, nil
+}
+
+// Close closes the connection pool of db, waiting for the queries running
+// on it to finish.
+func Close(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// HealthCheck pings the database of db, which fails once it is unreachable
+// or the pool has no connection left to ping with.
+func HealthCheck(db *gorm.DB) health.Checker {
+	return health.CheckerFunc(func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})
+}
+
+func NewDialector(cfg Config) (gorm.Dialector, error) {
+	dsn, err := DSN(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Driver {
+	case DriverSQLite:
+		return sqlite.Open(dsn), nil
+	case DriverPostgres:
+		return postgres.Open(dsn), nil
+	case DriverMySQL:
+		return mysql.Open(dsn), nil
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrUnknownDriver, cfg.Driver)
+}
+
+// DSN builds the driver specific data source name for cfg.
+func DSN(cfg Config) (string, error) {
+	if cfg.Name == "" {
+		return "", ErrDatabaseIsRequired
+	}
+
+	switch cfg.Driver {
+	case DriverSQLite:
+		return cfg.Name, nil
+	case DriverPostgres:
+		sslMode := cfg.SSLMode
+		if sslMode == "" {
+			sslMode = "disable"
+		}
+		return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+			quotePostgres(valueOr(cfg.Host, "localhost")),
+			quotePostgres(valueOr(cfg.Port, "5432")),
+			quotePostgres(cfg.User),
+			quotePostgres(cfg.Password),
+			quotePostgres(cfg.Name),
+			quotePostgres(sslMode),
+		), nil
+	case DriverMySQL:
+		mysqlConfig := mysqlDriver.NewConfig()
+		mysqlConfig.User = cfg.User
+		mysqlConfig.Passwd = cfg.Password
+		mysqlConfig.Net = "tcp"
+		mysqlConfig.Addr = net.JoinHostPort(valueOr(cfg.Host, "localhost"), valueOr(cfg.Port, "3306"))
+		mysqlConfig.DBName = cfg.Name
+		mysqlConfig.ParseTime = true
+		mysqlConfig.Loc = time.UTC
+		mysqlConfig.Params = map[string]string{"charset": "utf8mb4"}
+		return mysqlConfig.FormatDSN(), nil
+	}
+
+	return "", fmt.Errorf("%w: %q", ErrUnknownDriver, cfg.Driver)
+}
+
+// quotePostgres quotes value for a key/value connection string, in which
+// an empty or spaced value would otherwise run into the next key.
+func quotePostgres(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,230 @@
+package database
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/Mazzael/go-api/internal/infra/database/migrations"
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestDSN(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{
+			name: "sqlite file",
+			cfg:  Config{Driver: DriverSQLite, Name: "test.db"},
+			want: "test.db",
+		},
+		{
+			name: "sqlite in memory",
+			cfg:  Config{Driver: DriverSQLite, Name: SQLiteInMemory},
+			want: ":memory:",
+		},
+		{
+			name: "postgres",
+			cfg:  Config{Driver: DriverPostgres, Host: "db", Port: "5433", User: "root", Password: "secret", Name: "products"},
+			want: "host='db' port='5433' user='root' password='secret' dbname='products' sslmode='disable'",
+		},
+		{
+			name: "postgres defaults",
+			cfg:  Config{Driver: DriverPostgres, User: "root", Name: "products", SSLMode: "require"},
+			want: "host='localhost' port='5432' user='root' password='' dbname='products' sslmode='require'",
+		},
+		{
+			name: "postgres quoting",
+			cfg:  Config{Driver: DriverPostgres, User: "root", Password: `it's a \ secret`, Name: "products"},
+			want: `host='localhost' port='5432' user='root' password='it\'s a \\ secret' dbname='products' sslmode='disable'`,
+		},
+		{
+			name: "mysql",
+			cfg:  Config{Driver: DriverMySQL, Host: "db", Port: "3307", User: "root", Password: "secret", Name: "products"},
+			want: "root:secret@tcp(db:3307)/products?parseTime=true&charset=utf8mb4",
+		},
+		{
+			name: "mysql password with separators",
+			cfg:  Config{Driver: DriverMySQL, User: "root", Password: "p@ss:w/rd", Name: "products"},
+			want: "root:p@ss:w/rd@tcp(localhost:3306)/products?parseTime=true&charset=utf8mb4",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsn, err := DSN(tt.cfg)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, dsn)
+		})
+	}
+}
+
+// The drivers read back exactly what was configured, however awkward.
+func TestDSNRoundTripsThroughTheDrivers(t *testing.T) {
+	for _, password := range []string{"", "two words", `it's \ p@ss:w/rd`} {
+		dsn, err := DSN(Config{Driver: DriverPostgres, User: "root", Password: password, Name: "products"})
+		assert.NoError(t, err)
+		pg, err := pgconn.ParseConfig(dsn)
+		if assert.NoError(t, err, password) {
+			assert.Equal(t, password, pg.Password)
+			assert.Equal(t, "products", pg.Database)
+		}
+
+		dsn, err = DSN(Config{Driver: DriverMySQL, User: "root", Password: password, Name: "products"})
+		assert.NoError(t, err)
+		my, err := mysqlDriver.ParseDSN(dsn)
+		if assert.NoError(t, err, password) {
+			assert.Equal(t, password, my.Passwd)
+			assert.Equal(t, "products", my.DBName)
+			assert.True(t, my.ParseTime)
+		}
+	}
+}
+
+func TestDSNWhenDatabaseIsRequired(t *testing.T) {
+	_, err := DSN(Config{Driver: DriverPostgres})
+	assert.ErrorIs(t, err, ErrDatabaseIsRequired)
+}
+
+func TestNewConnectionWithUnknownDriver(t *testing.T) {
+	db, err := NewConnection(Config{Driver: "oracle", Name: "products"}, nil)
+	assert.Nil(t, db)
+	assert.ErrorIs(t, err, ErrUnknownDriver)
+	assert.Contains(t, err.Error(), `"oracle"`)
+}
+
+func TestNewConnectionSQLiteInMemory(t *testing.T) {
+	db, err := NewConnection(Config{Driver: DriverSQLite, Name: SQLiteInMemory, MaxOpenConns: 10}, nil)
+	assert.NoError(t, err)
+
+	sqlDB, err := db.DB()
+	assert.NoError(t, err)
+	defer sqlDB.Close()
+	assert.Equal(t, 1, sqlDB.Stats().MaxOpenConnections)
+}
+
+func TestNewConnectionAppliesPoolSettings(t *testing.T) {
+	db, err := NewConnection(Config{
+		Driver:          DriverSQLite,
+		Name:            t.TempDir() + "/pool.db",
+		MaxOpenConns:    7,
+		MaxIdleConns:    3,
+		ConnMaxLifetime: time.Minute,
+	}, nil)
+	assert.NoError(t, err)
+
+	sqlDB, err := db.DB()
+	assert.NoError(t, err)
+	defer sqlDB.Close()
+	assert.Equal(t, 7, sqlDB.Stats().MaxOpenConnections)
+}
+
+func TestHealthCheckFailsOnceClosed(t *testing.T) {
+	db, err := NewConnection(Config{Driver: DriverSQLite, Name: t.TempDir() + "/close.db"}, nil)
+	assert.NoError(t, err)
+
+	assert.NoError(t, HealthCheck(db).Check(context.Background()))
+	assert.NoError(t, Close(db))
+	assert.Error(t, db.Exec("SELECT 1").Error)
+	assert.Error(t, HealthCheck(db).Check(context.Background()))
+}
+
+// testBackends returns every database the repositories should be tested
+// against: sqlite always, and postgres only when TEST_POSTGRES_HOST names
+// one. Its tables are migrated up and down, so it must be a throwaway
+// database; one that merely answers on localhost is never used.
+func testBackends(t *testing.T) map[string]Config {
+	backends := map[string]Config{
+		DriverSQLite: {Driver: DriverSQLite, Name: SQLiteInMemory},
+	}
+
+	host := os.Getenv("TEST_POSTGRES_HOST")
+	if host == "" {
+		t.Log("TEST_POSTGRES_HOST not set, skipping the postgres backend")
+		return backends
+	}
+	backends[DriverPostgres] = Config{
+		Driver:   DriverPostgres,
+		Host:     host,
+		Port:     envOr("TEST_POSTGRES_PORT", "5432"),
+		User:     envOr("TEST_POSTGRES_USER", "postgres"),
+		Password: envOr("TEST_POSTGRES_PASSWORD", "postgres"),
+		Name:     envOr("TEST_POSTGRES_DB", "postgres"),
+	}
+	return backends
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func openTestBackend(t *testing.T, cfg Config) *gorm.DB {
+	db, err := NewConnection(cfg, &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrator := migrations.NewMigrator(db)
+	_, err = migrator.Up()
+	assert.NoError(t, err)
+
+	t.Cleanup(func() {
+		migrator.Down(len(migrator.Migrations))
+		db.Migrator().DropTable(&migrations.SchemaMigration{})
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	return db
+}
+
+func TestRepositoriesOnEachBackend(t *testing.T) {
+	for name, cfg := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			db := openTestBackend(t, cfg)
+
+			productRepository := NewProduct(db)
+			product, err := entity.NewProduct("Product 1", entityPkg.MustParseMoney("10.00", "USD"))
+			assert.NoError(t, err)
+			assert.NoError(t, productRepository.Create(testCtx, product))
+
+			productFound, err := productRepository.FindByID(testCtx, product.ID.String())
+			assert.NoError(t, err)
+			assert.Equal(t, product.Name, productFound.Name)
+
+			product.Name = "Updated Product"
+			assert.NoError(t, productRepository.Update(testCtx, product))
+
+			products, err := productRepository.FindAll(testCtx, 0, 10, "asc")
+			assert.NoError(t, err)
+			assert.Len(t, products, 1)
+			assert.Equal(t, "Updated Product", products[0].Name)
+
+			assert.NoError(t, productRepository.Delete(testCtx, product.ID.String()))
+			_, err = productRepository.FindByID(testCtx, product.ID.String())
+			assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+			userRepository := NewUser(db)
+			user, err := entity.NewUser("John Doe", "johndoe@example.com", "123456")
+			assert.NoError(t, err)
+			assert.NoError(t, userRepository.Create(testCtx, user))
+
+			userFound, err := userRepository.FindByEmail(testCtx, "johndoe@example.com")
+			assert.NoError(t, err)
+			assert.Equal(t, user.ID, userFound.ID)
+			assert.True(t, userFound.ValidatePassword("123456"))
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	"gorm.io/gorm"
+)
+
+// apiKeyTouchInterval is how stale last_used_at may get before a request
+// updates it, so that busy keys do not write on every request.
+const apiKeyTouchInterval = time.Minute
+
+type GormAPIKeyRepository struct {
+	DB *gorm.DB
+}
+
+func NewAPIKey(db *gorm.DB) *GormAPIKeyRepository {
+	return &GormAPIKeyRepository{DB: db}
+}
+
+func (k *GormAPIKeyRepository) Create(ctx context.Context, key *entity.APIKey) error {
+	return k.DB.WithContext(ctx).Create(key).Error
+}
+
+func (k *GormAPIKeyRepository) FindByHash(ctx context.Context, hash string) (*entity.APIKey, error) {
+	var key entity.APIKey
+	if err := k.DB.WithContext(ctx).First(&key, "key_hash = ?", hash).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// FindByUser lists the keys of the user, newest first, revoked ones
+// included.
+func (k *GormAPIKeyRepository) FindByUser(ctx context.Context, userID string) ([]*entity.APIKey, error) {
+	var keys []*entity.APIKey
+	err := k.DB.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Order("id").Find(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Revoke revokes the key id of the user. Keys of other users are not
+// found.
+func (k *GormAPIKeyRepository) Revoke(ctx context.Context, id, userID string, now time.Time) error {
+	var key entity.APIKey
+	if err := k.DB.WithContext(ctx).First(&key, "id = ? AND user_id = ?", id, userID).Error; err != nil {
+		return err
+	}
+	if key.RevokedAt != nil {
+		return nil
+	}
+	return k.DB.WithContext(ctx).Model(&key).Update("revoked_at", now).Error
+}
+
+// Touch records that the key was used at now.
+func (k *GormAPIKeyRepository) Touch(ctx context.Context, key *entity.APIKey, now time.Time) error {
+	if key.LastUsedAt != nil && now.Sub(*key.LastUsedAt) < apiKeyTouchInterval {
+		return nil
+	}
+	err := k.DB.WithContext(ctx).Model(&entity.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", key.ID, now.Add(-apiKeyTouchInterval)).
+		Update("last_used_at", now).Error
+	if err != nil {
+		return err
+	}
+	key.LastUsedAt = &now
+	return nil
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func openAPIKeyTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.AutoMigrate(&entity.APIKey{})
+	return db
+}
+
+func TestAPIKeyRepository(t *testing.T) {
+	repo := NewAPIKey(openAPIKeyTestDB(t))
+	userID := entityPkg.NewID()
+	now := time.Now()
+
+	key, plain, _ := entity.NewAPIKey(userID, "ci", []entity.Permission{entity.PermissionProductsRead, entity.PermissionStockWrite}, nil, now)
+	assert.NoError(t, repo.Create(testCtx, key))
+
+	found, err := repo.FindByHash(testCtx, entityPkg.HashToken(plain))
+	assert.NoError(t, err)
+	assert.Equal(t, key.ID, found.ID)
+	assert.Equal(t, key.Scopes, found.Scopes)
+
+	assert.NoError(t, repo.Touch(testCtx, found, now))
+	assert.NoError(t, repo.Touch(testCtx, found, now.Add(time.Second)))
+	found, _ = repo.FindByHash(testCtx, entityPkg.HashToken(plain))
+	assert.True(t, now.Equal(*found.LastUsedAt))
+
+	assert.ErrorIs(t, repo.Revoke(testCtx, key.ID.String(), entityPkg.NewID().String(), now), gorm.ErrRecordNotFound)
+	assert.NoError(t, repo.Revoke(testCtx, key.ID.String(), userID.String(), now))
+	found, _ = repo.FindByHash(testCtx, entityPkg.HashToken(plain))
+	assert.False(t, found.IsUsable(now))
+
+	other, _, _ := entity.NewAPIKey(entityPkg.NewID(), "other", []entity.Permission{entity.PermissionProductsRead}, nil, now)
+	assert.NoError(t, repo.Create(testCtx, other))
+	keys, err := repo.FindByUser(testCtx, userID.String())
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+}
@@ -0,0 +1,178 @@
+package database
+
+import (
+	"context"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	"gorm.io/gorm"
+)
+
+// The raw queries bypass the tenant callbacks, so they name the tenant
+// themselves.
+
+const descendantsQuery = `WITH RECURSIVE tree AS (
+	SELECT id FROM categories WHERE id = @id AND tenant_id = @tenant
+	UNION ALL
+	SELECT c.id FROM categories c JOIN tree t ON c.parent_id = t.id WHERE c.tenant_id = @tenant
+) SELECT id FROM tree`
+
+const ancestorsQuery = `WITH RECURSIVE ancestors AS (
+	SELECT id, parent_id, 0 AS depth FROM categories WHERE id = @id AND tenant_id = @tenant
+	UNION ALL
+	SELECT c.id, c.parent_id, a.depth + 1 FROM categories c JOIN ancestors a ON c.id = a.parent_id
+	WHERE c.tenant_id = @tenant
+) SELECT categories.* FROM categories JOIN ancestors ON categories.id = ancestors.id
+WHERE ancestors.depth > 0 ORDER BY ancestors.depth DESC`
+
+const deleteLinksQuery = `DELETE FROM product_categories WHERE category_id = @id
+AND product_id IN (SELECT id FROM products WHERE tenant_id = @tenant)`
+
+type GormCategoryRepository struct {
+	DB *gorm.DB
+}
+
+// NewCategory returns the categories of the tenant in the context of each
+// call, see WithTenant.
+func NewCategory(db *gorm.DB) *GormCategoryRepository {
+	scopeByTenant(db)
+	return &GormCategoryRepository{DB: db}
+}
+
+func (c *GormCategoryRepository) Create(ctx context.Context, category *entity.Category) error {
+	if category.ParentID != nil {
+		if _, err := c.FindByID(ctx, category.ParentID.String()); err != nil {
+			return entity.ErrInvalidParent
+		}
+	}
+	return c.DB.WithContext(ctx).Create(category).Error
+}
+
+func (c *GormCategoryRepository) FindAll(ctx context.Context) ([]*entity.Category, error) {
+	var categories []*entity.Category
+	if err := c.DB.WithContext(ctx).Order("name asc").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+func (c *GormCategoryRepository) FindByID(ctx context.Context, id string) (*entity.Category, error) {
+	var category entity.Category
+	if err := c.DB.WithContext(ctx).First(&category, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+func (c *GormCategoryRepository) FindByIDs(ctx context.Context, ids []string) ([]*entity.Category, error) {
+	var categories []*entity.Category
+	if len(ids) == 0 {
+		return categories, nil
+	}
+	if err := c.DB.WithContext(ctx).Where("id IN ?", ids).Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	if len(categories) != len(uniqueStrings(ids)) {
+		return nil, entity.ErrCategoryNotFound
+	}
+	return categories, nil
+}
+
+// FindAncestors returns the parents of the category, from the root down to
+// its direct parent.
+func (c *GormCategoryRepository) FindAncestors(ctx context.Context, id string) ([]*entity.Category, error) {
+	args, err := tenantQueryArgs(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var ancestors []*entity.Category
+	if err := c.DB.WithContext(ctx).Raw(ancestorsQuery, args).Scan(&ancestors).Error; err != nil {
+		return nil, err
+	}
+	return ancestors, nil
+}
+
+// FindDescendantIDs returns the ID of the category and of every category
+// nested below it.
+func (c *GormCategoryRepository) FindDescendantIDs(ctx context.Context, id string) ([]string, error) {
+	args, err := tenantQueryArgs(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	if err := c.DB.WithContext(ctx).Raw(descendantsQuery, args).Scan(&ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (c *GormCategoryRepository) Update(ctx context.Context, category *entity.Category) error {
+	_, err := c.FindByID(ctx, category.ID.String())
+	if err != nil {
+		return err
+	}
+
+	if category.ParentID != nil {
+		if _, err := c.FindByID(ctx, category.ParentID.String()); err != nil {
+			return entity.ErrInvalidParent
+		}
+
+		descendants, err := c.FindDescendantIDs(ctx, category.ID.String())
+		if err != nil {
+			return err
+		}
+		for _, id := range descendants {
+			if id == category.ParentID.String() {
+				return entity.ErrCategoryCycle
+			}
+		}
+	}
+
+	return c.DB.WithContext(ctx).Save(category).Error
+}
+
+func (c *GormCategoryRepository) Delete(ctx context.Context, id string) error {
+	args, err := tenantQueryArgs(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	var children int64
+	if err := c.DB.WithContext(ctx).Model(&entity.Category{}).Where("parent_id = ?", id).Count(&children).Error; err != nil {
+		return err
+	}
+	if children > 0 {
+		return entity.ErrCategoryHasChildren
+	}
+
+	return c.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("id").First(&entity.Category{}, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(deleteLinksQuery, args).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&entity.Category{}, "id = ?", id).Error
+	})
+}
+
+// tenantQueryArgs are the arguments of the raw queries on the category id:
+// it and the tenant of ctx, which they cannot run without.
+func tenantQueryArgs(ctx context.Context, id string) (map[string]interface{}, error) {
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return nil, ErrNoTenant
+	}
+	return map[string]interface{}{"id": id, "tenant": tenantID}, nil
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func openCategoryTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.AutoMigrate(&entity.Category{}, &entity.Product{})
+	return db
+}
+
+// createCategoryTree creates Electronics > Phones > Smartphones and returns
+// them in that order.
+func createCategoryTree(t *testing.T, repo *GormCategoryRepository) []*entity.Category {
+	electronics, _ := entity.NewCategory("Electronics", nil)
+	phones, _ := entity.NewCategory("Phones", &electronics.ID)
+	smartphones, _ := entity.NewCategory("Smartphones", &phones.ID)
+
+	for _, category := range []*entity.Category{electronics, phones, smartphones} {
+		assert.NoError(t, repo.Create(testCtx, category))
+	}
+	return []*entity.Category{electronics, phones, smartphones}
+}
+
+func TestCreateCategory(t *testing.T) {
+	repo := NewCategory(openCategoryTestDB(t))
+	tree := createCategoryTree(t, repo)
+
+	found, err := repo.FindByID(testCtx, tree[1].ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, "Phones", found.Name)
+	assert.Equal(t, tree[0].ID, *found.ParentID)
+
+	missing := entityPkg.NewID()
+	orphan, _ := entity.NewCategory("Orphan", &missing)
+	assert.ErrorIs(t, repo.Create(testCtx, orphan), entity.ErrInvalidParent)
+}
+
+func TestFindCategoryAncestors(t *testing.T) {
+	repo := NewCategory(openCategoryTestDB(t))
+	tree := createCategoryTree(t, repo)
+
+	ancestors, err := repo.FindAncestors(testCtx, tree[2].ID.String())
+	assert.NoError(t, err)
+	assert.Len(t, ancestors, 2)
+	assert.Equal(t, "Electronics", ancestors[0].Name)
+	assert.Equal(t, "Phones", ancestors[1].Name)
+
+	ancestors, err = repo.FindAncestors(testCtx, tree[0].ID.String())
+	assert.NoError(t, err)
+	assert.Empty(t, ancestors)
+}
+
+func TestFindCategoryDescendantIDs(t *testing.T) {
+	repo := NewCategory(openCategoryTestDB(t))
+	tree := createCategoryTree(t, repo)
+
+	ids, err := repo.FindDescendantIDs(testCtx, tree[0].ID.String())
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{tree[0].ID.String(), tree[1].ID.String(), tree[2].ID.String()}, ids)
+
+	ids, err = repo.FindDescendantIDs(testCtx, tree[2].ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{tree[2].ID.String()}, ids)
+}
+
+func TestUpdateCategoryRejectsCycles(t *testing.T) {
+	repo := NewCategory(openCategoryTestDB(t))
+	tree := createCategoryTree(t, repo)
+
+	tree[0].ParentID = &tree[2].ID
+	assert.ErrorIs(t, repo.Update(testCtx, tree[0]), entity.ErrCategoryCycle)
+
+	tree[2].ParentID = &tree[0].ID
+	assert.NoError(t, repo.Update(testCtx, tree[2]))
+
+	ancestors, err := repo.FindAncestors(testCtx, tree[2].ID.String())
+	assert.NoError(t, err)
+	assert.Len(t, ancestors, 1)
+}
+
+func TestDeleteCategory(t *testing.T) {
+	repo := NewCategory(openCategoryTestDB(t))
+	tree := createCategoryTree(t, repo)
+
+	assert.ErrorIs(t, repo.Delete(testCtx, tree[1].ID.String()), entity.ErrCategoryHasChildren)
+
+	assert.NoError(t, repo.Delete(testCtx, tree[2].ID.String()))
+	_, err := repo.FindByID(testCtx, tree[2].ID.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestFindCategoriesByIDs(t *testing.T) {
+	repo := NewCategory(openCategoryTestDB(t))
+	tree := createCategoryTree(t, repo)
+
+	categories, err := repo.FindByIDs(testCtx, []string{tree[0].ID.String(), tree[2].ID.String()})
+	assert.NoError(t, err)
+	assert.Len(t, categories, 2)
+
+	_, err = repo.FindByIDs(testCtx, []string{tree[0].ID.String(), entityPkg.NewID().String()})
+	assert.ErrorIs(t, err, entity.ErrCategoryNotFound)
+}
+
+func TestFindAllProductsByCategories(t *testing.T) {
+	db := openCategoryTestDB(t)
+	categoryRepo := NewCategory(db)
+	productRepo := NewProduct(db)
+	tree := createCategoryTree(t, categoryRepo)
+
+	phone, _ := entity.NewProduct("Phone", entityPkg.MustParseMoney("100", "USD"))
+	phone.Categories = []*entity.Category{tree[2]}
+	assert.NoError(t, productRepo.Create(testCtx, phone))
+
+	tv, _ := entity.NewProduct("TV", entityPkg.MustParseMoney("500", "USD"))
+	tv.Categories = []*entity.Category{tree[0]}
+	assert.NoError(t, productRepo.Create(testCtx, tv))
+
+	products, err := productRepo.FindAllByCategories(testCtx, []string{tree[1].ID.String()}, 0, 10, "asc")
+	assert.NoError(t, err)
+	assert.Empty(t, products)
+
+	ids, _ := categoryRepo.FindDescendantIDs(testCtx, tree[1].ID.String())
+	products, err = productRepo.FindAllByCategories(testCtx, ids, 0, 10, "asc")
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+	assert.Equal(t, "Phone", products[0].Name)
+	assert.Len(t, products[0].Categories, 1)
+
+	ids, _ = categoryRepo.FindDescendantIDs(testCtx, tree[0].ID.String())
+	products, err = productRepo.FindAllByCategories(testCtx, ids, 0, 10, "asc")
+	assert.NoError(t, err)
+	assert.Len(t, products, 2)
+
+	phone.Categories = []*entity.Category{tree[1]}
+	assert.NoError(t, productRepo.Update(testCtx, phone))
+	found, err := productRepo.FindByID(testCtx, phone.ID.String())
+	assert.NoError(t, err)
+	assert.Len(t, found.Categories, 1)
+	assert.Equal(t, "Phones", found.Categories[0].Name)
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type GormLoginAttemptRepository struct {
+	DB *gorm.DB
+}
+
+func NewLoginAttempt(db *gorm.DB) *GormLoginAttemptRepository {
+	return &GormLoginAttemptRepository{DB: db}
+}
+
+// Find returns the failed logins of key, which are none when it never
+// failed.
+func (l *GormLoginAttemptRepository) Find(ctx context.Context, key string) (*entity.LoginAttempts, error) {
+	var attempts entity.LoginAttempts
+	err := l.DB.WithContext(ctx).First(&attempts, "login_key = ?", key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &entity.LoginAttempts{Key: key}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &attempts, nil
+}
+
+// RecordFailure adds a failed login to key, locking its row so concurrent
+// failures are all counted where the database supports it.
+func (l *GormLoginAttemptRepository) RecordFailure(ctx context.Context, key string, now time.Time, policy entity.LoginPolicy) (*entity.LoginAttempts, error) {
+	var attempts entity.LoginAttempts
+	err := l.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&attempts, "login_key = ?", key).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			attempts = entity.LoginAttempts{Key: key}
+		} else if err != nil {
+			return err
+		}
+
+		attempts.Fail(now, policy)
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&attempts).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &attempts, nil
+}
+
+func (l *GormLoginAttemptRepository) Reset(ctx context.Context, key string) error {
+	return l.DB.WithContext(ctx).Delete(&entity.LoginAttempts{}, "login_key = ?", key).Error
+}
+
+// DeleteExpired removes keys that are no longer blocked and whose last
+// failure is older than before.
+func (l *GormLoginAttemptRepository) DeleteExpired(ctx context.Context, now, before time.Time) (int64, error) {
+	result := l.DB.WithContext(ctx).Where("blocked_until < ? AND last_failure < ?", now, before).Delete(&entity.LoginAttempts{})
+	return result.RowsAffected, result.Error
+}
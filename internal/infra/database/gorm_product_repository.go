@@ -1,57 +1,146 @@
 package database
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/Mazzael/go-api/internal/entity"
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"github.com/Mazzael/go-api/pkg/pagination"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type GormProductRepository struct {
 	DB *gorm.DB
+	// search is picked once by NewProduct and never changes, so that
+	// concurrent calls can share it.
+	search productSearchIndex
+}
+
+var ErrInvalidSortField = errors.New("invalid sort field")
+
+// ProductFilter narrows down a product listing. Zero fields do not filter;
+// nil IDs and CategoryIDs match every product while empty ones match none.
+// Price bounds are only compared against products in the same currency.
+type ProductFilter struct {
+	NameContains  string
+	NamePrefix    string
+	PriceCurrency string
+	PriceGTE      *entityPkg.Money
+	PriceLTE      *entityPkg.Money
+	CreatedAtGTE  *time.Time
+	CreatedAtLTE  *time.Time
+	IDs           []string
+	CategoryIDs   []string
+	CreatedBy     string
+	Sort          []SortField
+}
+
+// SortField orders a listing by one API field.
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ProductPage is one page of a keyset listing. HasNext and HasPrev tell
+// whether more rows exist after the last and before the first product.
+type ProductPage struct {
+	Products []*entity.Product
+	HasNext  bool
+	HasPrev  bool
 }
 
 func NewProduct(db *gorm.DB) *GormProductRepository {
-	return &GormProductRepository{DB: db}
+	scopeByTenant(db)
+	return &GormProductRepository{DB: db, search: newProductSearchIndex(db)}
 }
 
-func (p *GormProductRepository) Create(product *entity.Product) error {
-	return p.DB.Create(product).Error
+func (p *GormProductRepository) Create(ctx context.Context, product *entity.Product) error {
+	return p.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(product).Error; err != nil {
+			return err
+		}
+		return p.search.put(tx, product)
+	})
 }
 
-func (p *GormProductRepository) FindByID(id string) (*entity.Product, error) {
+func (p *GormProductRepository) FindByID(ctx context.Context, id string) (*entity.Product, error) {
 	var product entity.Product
-	if err := p.DB.First(&product, "id = ?", id).Error; err != nil {
+	if err := p.DB.WithContext(ctx).Preload("Categories").First(&product, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
 	return &product, nil
 }
 
-func (p *GormProductRepository) Update(product *entity.Product) error {
-	_, err := p.FindByID(product.ID.String())
+func (p *GormProductRepository) Update(ctx context.Context, product *entity.Product) error {
+	_, err := p.FindByID(ctx, product.ID.String())
 	if err != nil {
 		return err
 	}
 
-	return p.DB.Save(product).Error
+	return p.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Categories").Save(product).Error; err != nil {
+			return err
+		}
+		if err := p.search.put(tx, product); err != nil {
+			return err
+		}
+		if product.Categories == nil {
+			return nil
+		}
+		return tx.Model(product).Association("Categories").Replace(product.Categories)
+	})
 }
 
-func (p *GormProductRepository) Delete(id string) error {
-	if err := p.DB.Delete(&entity.Product{}, "id = ?", id).Error; err != nil {
-		return err
-	}
-	return nil
+// Delete removes the product with its category links and search entry. The
+// product is looked up first, since the raw statements cleaning up after it
+// are not scoped to the tenant.
+func (p *GormProductRepository) Delete(ctx context.Context, id string) error {
+	return p.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("id").First(&entity.Product{}, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM product_categories WHERE product_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := p.search.remove(tx, id); err != nil {
+			return err
+		}
+		return tx.Delete(&entity.Product{}, "id = ?", id).Error
+	})
 }
 
-func (p *GormProductRepository) FindAll(page, limit int, sort string) ([]*entity.Product, error) {
-	if sort != "" && sort != "asc" && sort != "desc" {
-		sort = "asc"
+func (p *GormProductRepository) FindAll(ctx context.Context, page, limit int, sort string) ([]*entity.Product, error) {
+	return p.FindAllMatching(ctx, ProductFilter{Sort: createdAtSort(sort)}, page, limit)
+}
+
+// FindAllByCategories lists the products linked to any of the given
+// categories.
+func (p *GormProductRepository) FindAllByCategories(ctx context.Context, categoryIDs []string, page, limit int, sort string) ([]*entity.Product, error) {
+	return p.FindAllMatching(ctx, ProductFilter{CategoryIDs: categoryIDs, Sort: createdAtSort(sort)}, page, limit)
+}
+
+// FindAllMatching lists the products that match filter, ordered by
+// filter.Sort and paged by offset.
+func (p *GormProductRepository) FindAllMatching(ctx context.Context, filter ProductFilter, page, limit int) ([]*entity.Product, error) {
+	query, err := p.filter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := productOrder(filter.Sort)
+	if err != nil {
+		return nil, err
 	}
 
 	var products []*entity.Product
 	offset := page * limit
 
-	query := p.DB.Offset(offset).Limit(limit)
-
-	query = query.Order("created_at " + sort)
+	query = query.Preload("Categories").Offset(offset).Limit(limit).Clauses(order)
 
 	if err := query.Find(&products).Error; err != nil {
 		return nil, err
@@ -59,3 +148,173 @@ func (p *GormProductRepository) FindAll(page, limit int, sort string) ([]*entity
 
 	return products, nil
 }
+
+// FindPage lists the products that match filter by keyset on
+// (created_at, id); filter.Sort is ignored. A nil cursor starts at the
+// beginning of the listing; otherwise the cursor sort order wins over sort so
+// a client cannot switch order halfway through.
+func (p *GormProductRepository) FindPage(ctx context.Context, filter ProductFilter, cursor *pagination.Cursor, limit int, sort string) (*ProductPage, error) {
+	query, err := p.filter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if cursor != nil {
+		sort = cursor.Sort
+	}
+	if sort != "desc" {
+		sort = "asc"
+	}
+
+	// walking backwards is done by reading in the opposite order from the
+	// cursor and flipping the rows afterwards.
+	backward := cursor != nil && cursor.Direction == pagination.Prev
+	order := sort
+	if backward {
+		order = reverseSort(order)
+	}
+
+	query = query.Preload("Categories")
+	if cursor != nil {
+		operator := ">"
+		if order == "desc" {
+			operator = "<"
+		}
+		query = query.Where("(created_at, id) "+operator+" (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+
+	var products []*entity.Product
+	err = query.Order("created_at " + order).Order("id " + order).Limit(limit + 1).Find(&products).Error
+	if err != nil {
+		return nil, err
+	}
+
+	more := len(products) > limit
+	if more {
+		products = products[:limit]
+	}
+
+	page := &ProductPage{Products: products}
+	if backward {
+		for i, j := 0, len(products)-1; i < j; i, j = i+1, j-1 {
+			products[i], products[j] = products[j], products[i]
+		}
+		page.HasPrev = more
+		page.HasNext = true
+	} else {
+		page.HasNext = more
+		page.HasPrev = cursor != nil
+	}
+
+	return page, nil
+}
+
+// filter narrows the products table down to the rows matching f.
+func (p *GormProductRepository) filter(ctx context.Context, f ProductFilter) (*gorm.DB, error) {
+	query := p.DB.WithContext(ctx).Model(&entity.Product{})
+
+	if f.NameContains != "" {
+		query = query.Where("LOWER(name) LIKE ? ESCAPE '!'", "%"+escapeLike(strings.ToLower(f.NameContains))+"%")
+	}
+	if f.NamePrefix != "" {
+		query = query.Where("LOWER(name) LIKE ? ESCAPE '!'", escapeLike(strings.ToLower(f.NamePrefix))+"%")
+	}
+
+	currency := f.PriceCurrency
+	for _, bound := range []*entityPkg.Money{f.PriceGTE, f.PriceLTE} {
+		if bound == nil {
+			continue
+		}
+		if currency != "" && currency != bound.Currency {
+			return nil, entityPkg.ErrCurrencyMismatch
+		}
+		currency = bound.Currency
+	}
+	if currency != "" {
+		query = query.Where("price_currency = ?", currency)
+	}
+	if f.PriceGTE != nil {
+		query = query.Where("price_amount >= ?", f.PriceGTE.Amount)
+	}
+	if f.PriceLTE != nil {
+		query = query.Where("price_amount <= ?", f.PriceLTE.Amount)
+	}
+
+	if f.CreatedAtGTE != nil {
+		query = query.Where("created_at >= ?", *f.CreatedAtGTE)
+	}
+	if f.CreatedAtLTE != nil {
+		query = query.Where("created_at <= ?", *f.CreatedAtLTE)
+	}
+
+	if f.IDs != nil {
+		query = query.Where("id IN ?", f.IDs)
+	}
+	if f.CreatedBy != "" {
+		query = query.Where("created_by = ?", f.CreatedBy)
+	}
+	if f.CategoryIDs != nil {
+		linked := p.DB.Table("product_categories").Select("product_id").Where("category_id IN ?", f.CategoryIDs)
+		query = query.Where("id IN (?)", linked)
+	}
+
+	return query, nil
+}
+
+// productSortColumns is the whitelist of fields products can be sorted by,
+// mapped to their columns. Nothing else ever reaches ORDER BY.
+var productSortColumns = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"price":      "price_amount",
+	"created_at": "created_at",
+}
+
+// IsProductSortField reports whether products can be sorted by field.
+func IsProductSortField(field string) bool {
+	_, ok := productSortColumns[field]
+	return ok
+}
+
+// productOrder builds the ORDER BY for sort, oldest first when sort is
+// empty, ending with id so that rows with equal sort keys still come back in
+// a stable order.
+func productOrder(sort []SortField) (clause.OrderBy, error) {
+	if len(sort) == 0 {
+		sort = createdAtSort("asc")
+	}
+
+	var order clause.OrderBy
+	hasID := false
+	for _, field := range sort {
+		column, ok := productSortColumns[field.Field]
+		if !ok {
+			return order, fmt.Errorf("%w: %q", ErrInvalidSortField, field.Field)
+		}
+		hasID = hasID || column == "id"
+		order.Columns = append(order.Columns, clause.OrderByColumn{Column: clause.Column{Name: column}, Desc: field.Desc})
+	}
+	if !hasID {
+		order.Columns = append(order.Columns, clause.OrderByColumn{Column: clause.Column{Name: "id"}})
+	}
+	return order, nil
+}
+
+// createdAtSort translates the legacy asc/desc sort parameter.
+func createdAtSort(sort string) []SortField {
+	return []SortField{{Field: "created_at", Desc: sort == "desc"}}
+}
+
+func reverseSort(sort string) string {
+	if sort == "desc" {
+		return "asc"
+	}
+	return "desc"
+}
+
+// escapeLike makes s match itself literally in a LIKE pattern. '!' is used
+// as the escape character because backslashes in string literals mean
+// different things to different databases.
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
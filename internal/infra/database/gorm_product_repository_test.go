@@ -4,14 +4,19 @@ import (
 	"fmt"
 	"math/rand"
 	"testing"
+	"time"
 
 	"os"
 	"path/filepath"
 
 	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/Mazzael/go-api/internal/infra/database/migrations"
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"github.com/Mazzael/go-api/pkg/pagination"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 func TestCreateNewProduct(t *testing.T) {
@@ -23,11 +28,11 @@ func TestCreateNewProduct(t *testing.T) {
 	}
 	db.AutoMigrate(&entity.Product{})
 
-	product, err := entity.NewProduct("Product 1", 100)
+	product, err := entity.NewProduct("Product 1", entityPkg.MustParseMoney("100.00", "USD"))
 	assert.Nil(t, err)
 
 	gormProductRepository := NewProduct(db)
-	err = gormProductRepository.Create(product)
+	err = gormProductRepository.Create(testCtx, product)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, product.ID)
 
@@ -42,29 +47,28 @@ func TestFindAllProducts(t *testing.T) {
 		t.Error(err)
 	}
 	db.AutoMigrate(&entity.Product{})
+	gormProductRepository := NewProduct(db)
 
 	for i := 1; i < 24; i++ {
-		product, err := entity.NewProduct(fmt.Sprintf("Product %d", i), rand.Float64()*100)
+		product, err := entity.NewProduct(fmt.Sprintf("Product %d", i), entityPkg.Money{Amount: rand.Int63n(10000) + 1, Currency: "USD"})
 		assert.NoError(t, err)
 
-		db.Create(product)
+		db.WithContext(testCtx).Create(product)
 	}
 
-	gormProductRepository := NewProduct(db)
-
-	products, err := gormProductRepository.FindAll(0, 10, "asc")
+	products, err := gormProductRepository.FindAll(testCtx, 0, 10, "asc")
 	assert.NoError(t, err)
 	assert.Len(t, products, 10)
 	assert.Equal(t, "Product 1", products[0].Name)
 	assert.Equal(t, "Product 10", products[9].Name)
 
-	products, err = gormProductRepository.FindAll(1, 10, "asc")
+	products, err = gormProductRepository.FindAll(testCtx, 1, 10, "asc")
 	assert.NoError(t, err)
 	assert.Len(t, products, 10)
 	assert.Equal(t, "Product 11", products[0].Name)
 	assert.Equal(t, "Product 20", products[9].Name)
 
-	products, err = gormProductRepository.FindAll(2, 10, "asc")
+	products, err = gormProductRepository.FindAll(testCtx, 2, 10, "asc")
 	assert.NoError(t, err)
 	assert.Len(t, products, 3)
 	assert.Equal(t, "Product 21", products[0].Name)
@@ -81,15 +85,14 @@ func TestFindProductByID(t *testing.T) {
 		t.Error(err)
 	}
 	db.AutoMigrate(&entity.Product{})
+	gormProductRepository := NewProduct(db)
 
-	product, err := entity.NewProduct("Product 1", 10.00)
+	product, err := entity.NewProduct("Product 1", entityPkg.MustParseMoney("10.00", "USD"))
 	assert.NoError(t, err)
 
-	db.Create(product)
-
-	gormProductRepository := NewProduct(db)
+	db.WithContext(testCtx).Create(product)
 
-	productFound, err := gormProductRepository.FindByID(product.ID.String())
+	productFound, err := gormProductRepository.FindByID(testCtx, product.ID.String())
 	assert.NoError(t, err)
 	assert.NotNil(t, productFound)
 	assert.Equal(t, product.Name, productFound.Name)
@@ -105,19 +108,18 @@ func TestUpdateProduct(t *testing.T) {
 		t.Error(err)
 	}
 	db.AutoMigrate(&entity.Product{})
+	gormProductRepository := NewProduct(db)
 
-	product, err := entity.NewProduct("Product 1", 10.00)
+	product, err := entity.NewProduct("Product 1", entityPkg.MustParseMoney("10.00", "USD"))
 	assert.NoError(t, err)
 
-	db.Create(product)
-
-	gormProductRepository := NewProduct(db)
+	db.WithContext(testCtx).Create(product)
 
 	product.Name = "Updated Product"
-	err = gormProductRepository.Update(product)
+	err = gormProductRepository.Update(testCtx, product)
 	assert.NoError(t, err)
 
-	productFound, err := gormProductRepository.FindByID(product.ID.String())
+	productFound, err := gormProductRepository.FindByID(testCtx, product.ID.String())
 	assert.NoError(t, err)
 	assert.Equal(t, "Updated Product", productFound.Name)
 
@@ -132,23 +134,311 @@ func TestDeleteProduct(t *testing.T) {
 		t.Error(err)
 	}
 	db.AutoMigrate(&entity.Product{})
+	gormProductRepository := NewProduct(db)
 
-	product1, err1 := entity.NewProduct("Product 1", 10.00)
-	product2, err2 := entity.NewProduct("Product 2", 20.00)
+	product1, err1 := entity.NewProduct("Product 1", entityPkg.MustParseMoney("10.00", "USD"))
+	product2, err2 := entity.NewProduct("Product 2", entityPkg.MustParseMoney("20.00", "USD"))
 	assert.NoError(t, err1)
 	assert.NoError(t, err2)
 
-	db.Create(product1)
-	db.Create(product2)
+	db.WithContext(testCtx).Create(product1)
+	db.WithContext(testCtx).Create(product2)
 
-	gormProductRepository := NewProduct(db)
-
-	err = gormProductRepository.Delete(product1.ID.String())
+	err = gormProductRepository.Delete(testCtx, product1.ID.String())
 	assert.NoError(t, err)
 
-	productFound, err := gormProductRepository.FindByID(product1.ID.String())
+	productFound, err := gormProductRepository.FindByID(testCtx, product1.ID.String())
 	assert.Error(t, err)
 	assert.Nil(t, productFound)
 
 	os.Remove(tmpFile)
 }
+
+// createProductsAt creates one product per offset, created that many seconds
+// after base. Equal offsets exercise the id tie-breaker.
+func createProductsAt(t *testing.T, db *gorm.DB, base time.Time, offsets ...int) []*entity.Product {
+	var products []*entity.Product
+	for i, offset := range offsets {
+		product, err := entity.NewProduct(fmt.Sprintf("Product %d", i+1), entityPkg.MustParseMoney("10.00", "USD"))
+		assert.NoError(t, err)
+		product.CreatedAt = base.Add(time.Duration(offset) * time.Second)
+		assert.NoError(t, db.WithContext(testCtx).Create(product).Error)
+		products = append(products, product)
+	}
+	return products
+}
+
+func productIDs(products []*entity.Product) []string {
+	ids := make([]string, len(products))
+	for i, product := range products {
+		ids[i] = product.ID.String()
+	}
+	return ids
+}
+
+func TestFindProductPages(t *testing.T) {
+	db := openCategoryTestDB(t)
+	repo := NewProduct(db)
+
+	base := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	createProductsAt(t, db, base, 0, 1, 1, 1, 2, 3, 4)
+
+	var all []*entity.Product
+	assert.NoError(t, db.WithContext(testCtx).Order("created_at, id").Find(&all).Error)
+
+	first, err := repo.FindPage(testCtx, ProductFilter{}, nil, 3, "asc")
+	assert.NoError(t, err)
+	assert.Equal(t, productIDs(all[:3]), productIDs(first.Products))
+	assert.True(t, first.HasNext)
+	assert.False(t, first.HasPrev)
+
+	last := first.Products[2]
+	second, err := repo.FindPage(testCtx, ProductFilter{}, &pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.ID.String(), Direction: pagination.Next, Sort: "asc"}, 3, "")
+	assert.NoError(t, err)
+	assert.Equal(t, productIDs(all[3:6]), productIDs(second.Products))
+	assert.True(t, second.HasNext)
+	assert.True(t, second.HasPrev)
+
+	last = second.Products[2]
+	third, err := repo.FindPage(testCtx, ProductFilter{}, &pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.ID.String(), Direction: pagination.Next, Sort: "asc"}, 3, "")
+	assert.NoError(t, err)
+	assert.Equal(t, productIDs(all[6:]), productIDs(third.Products))
+	assert.False(t, third.HasNext)
+
+	firstOfSecond := second.Products[0]
+	back, err := repo.FindPage(testCtx, ProductFilter{}, &pagination.Cursor{CreatedAt: firstOfSecond.CreatedAt, ID: firstOfSecond.ID.String(), Direction: pagination.Prev, Sort: "asc"}, 3, "")
+	assert.NoError(t, err)
+	assert.Equal(t, productIDs(all[:3]), productIDs(back.Products))
+	assert.False(t, back.HasPrev)
+	assert.True(t, back.HasNext)
+}
+
+func TestFindProductPagesDescending(t *testing.T) {
+	db := openCategoryTestDB(t)
+	repo := NewProduct(db)
+
+	base := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	createProductsAt(t, db, base, 0, 1, 2, 2, 3)
+
+	var all []*entity.Product
+	assert.NoError(t, db.WithContext(testCtx).Order("created_at desc, id desc").Find(&all).Error)
+
+	first, err := repo.FindPage(testCtx, ProductFilter{}, nil, 2, "desc")
+	assert.NoError(t, err)
+	assert.Equal(t, productIDs(all[:2]), productIDs(first.Products))
+
+	last := first.Products[1]
+	second, err := repo.FindPage(testCtx, ProductFilter{}, &pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.ID.String(), Direction: pagination.Next, Sort: "desc"}, 2, "asc")
+	assert.NoError(t, err)
+	assert.Equal(t, productIDs(all[2:4]), productIDs(second.Products))
+}
+
+func TestFindProductPagesIgnoresInsertsBehindTheCursor(t *testing.T) {
+	db := openCategoryTestDB(t)
+	repo := NewProduct(db)
+
+	base := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	createProductsAt(t, db, base, 10, 20, 30, 40)
+
+	first, err := repo.FindPage(testCtx, ProductFilter{}, nil, 2, "asc")
+	assert.NoError(t, err)
+
+	// a product created before the cursor would shift every later offset
+	// page by one and repeat a row; the keyset page must not notice it.
+	createProductsAt(t, db, base, 0)
+
+	last := first.Products[1]
+	second, err := repo.FindPage(testCtx, ProductFilter{}, &pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.ID.String(), Direction: pagination.Next, Sort: "asc"}, 2, "asc")
+	assert.NoError(t, err)
+	assert.Len(t, second.Products, 2)
+	assert.NotContains(t, productIDs(second.Products), last.ID.String())
+	assert.Equal(t, base.Add(30*time.Second).Unix(), second.Products[0].CreatedAt.Unix())
+}
+
+func TestFindProductPagesByCategories(t *testing.T) {
+	db := openCategoryTestDB(t)
+	repo := NewProduct(db)
+
+	category, _ := entity.NewCategory("Books", nil)
+	assert.NoError(t, NewCategory(db).Create(testCtx, category))
+
+	base := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	products := createProductsAt(t, db, base, 0, 1, 2)
+	for _, product := range products[1:] {
+		product.Categories = []*entity.Category{category}
+		assert.NoError(t, repo.Update(testCtx, product))
+	}
+
+	page, err := repo.FindPage(testCtx, ProductFilter{CategoryIDs: []string{category.ID.String()}}, nil, 10, "asc")
+	assert.NoError(t, err)
+	assert.Equal(t, productIDs(products[1:]), productIDs(page.Products))
+	assert.False(t, page.HasNext)
+}
+
+// BenchmarkProductPagination compares reading a page deep into a 1M row
+// table by offset and by keyset. Run it with:
+//
+//	go test ./internal/infra/database -run '^$' -bench ProductPagination
+func BenchmarkProductPagination(b *testing.B) {
+	if testing.Short() {
+		b.Skip("seeding 1M products is slow")
+	}
+
+	const rows = 1_000_000
+	const limit = 20
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(b.TempDir(), "bench.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		b.Fatal(err)
+	}
+	if _, err := migrations.NewMigrator(db).Up(); err != nil {
+		b.Fatal(err)
+	}
+
+	// the rows go straight into the table, so they say whose they are.
+	tenantID, _ := TenantFromContext(testCtx)
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		batch := make([]map[string]interface{}, 0, 1000)
+		for i := 0; i < rows; i++ {
+			batch = append(batch, map[string]interface{}{
+				"id":             entityPkg.NewID().String(),
+				"tenant_id":      tenantID.String(),
+				"name":           fmt.Sprintf("Product %d", i),
+				"price_amount":   int64(1000),
+				"price_currency": "USD",
+				"created_at":     base.Add(time.Duration(i) * time.Millisecond),
+			})
+			if len(batch) == cap(batch) {
+				if err := tx.Table("products").Create(batch).Error; err != nil {
+					return err
+				}
+				batch = batch[:0]
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	repo := NewProduct(db)
+
+	for _, depth := range []int{1_000, 500_000, 990_000} {
+		page := depth / limit
+
+		var anchor entity.Product
+		if err := db.WithContext(testCtx).Order("created_at, id").Offset(depth - 1).First(&anchor).Error; err != nil {
+			b.Fatal(err)
+		}
+		cursor := &pagination.Cursor{CreatedAt: anchor.CreatedAt, ID: anchor.ID.String(), Direction: pagination.Next, Sort: "asc"}
+
+		b.Run(fmt.Sprintf("offset/row=%d", depth), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.FindAll(testCtx, page, limit, "asc"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("keyset/row=%d", depth), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.FindPage(testCtx, ProductFilter{}, cursor, limit, "asc"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestFindAllMatchingProducts(t *testing.T) {
+	db := openCategoryTestDB(t)
+	repo := NewProduct(db)
+
+	base := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	for i, p := range []struct {
+		name     string
+		price    string
+		currency string
+	}{
+		{"Blue Shirt", "20.00", "USD"},
+		{"Red Shirt", "15.00", "USD"},
+		{"Shirt_Special 100%", "30.00", "USD"},
+		{"Blue Jeans", "50.00", "USD"},
+		{"Blue Hat", "20.00", "EUR"},
+	} {
+		product, err := entity.NewProduct(p.name, entityPkg.MustParseMoney(p.price, p.currency))
+		assert.NoError(t, err)
+		product.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+		assert.NoError(t, repo.Create(testCtx, product))
+	}
+
+	names := func(filter ProductFilter) []string {
+		products, err := repo.FindAllMatching(testCtx, filter, 0, 10)
+		assert.NoError(t, err)
+		var names []string
+		for _, product := range products {
+			names = append(names, product.Name)
+		}
+		return names
+	}
+
+	gte := entityPkg.MustParseMoney("20.00", "USD")
+	lte := entityPkg.MustParseMoney("30.00", "USD")
+	from := base.Add(time.Hour)
+	to := base.Add(3 * time.Hour)
+
+	assert.Equal(t, []string{"Blue Shirt", "Red Shirt", "Shirt_Special 100%"}, names(ProductFilter{NameContains: "SHIRT"}))
+	assert.Equal(t, []string{"Blue Shirt", "Blue Jeans", "Blue Hat"}, names(ProductFilter{NamePrefix: "blue"}))
+	assert.Equal(t, []string{"Shirt_Special 100%"}, names(ProductFilter{NameContains: "_special 100%"}))
+	assert.Empty(t, names(ProductFilter{NameContains: "e_s"}))
+	assert.Equal(t, []string{"Blue Shirt", "Shirt_Special 100%"}, names(ProductFilter{PriceGTE: &gte, PriceLTE: &lte}))
+	assert.Equal(t, []string{"Blue Hat"}, names(ProductFilter{PriceCurrency: "EUR"}))
+	assert.Equal(t, []string{"Red Shirt", "Shirt_Special 100%", "Blue Jeans"}, names(ProductFilter{CreatedAtGTE: &from, CreatedAtLTE: &to}))
+	assert.Equal(t, []string{"Blue Jeans", "Shirt_Special 100%", "Blue Hat", "Blue Shirt", "Red Shirt"},
+		names(ProductFilter{Sort: []SortField{{Field: "price", Desc: true}, {Field: "name"}}}))
+
+	_, err := repo.FindAllMatching(testCtx, ProductFilter{Sort: []SortField{{Field: "name; DROP TABLE products"}}}, 0, 10)
+	assert.ErrorIs(t, err, ErrInvalidSortField)
+
+	eur := entityPkg.MustParseMoney("1.00", "EUR")
+	_, err = repo.FindAllMatching(testCtx, ProductFilter{PriceGTE: &gte, PriceLTE: &eur}, 0, 10)
+	assert.ErrorIs(t, err, entityPkg.ErrCurrencyMismatch)
+
+	var all []*entity.Product
+	assert.NoError(t, db.WithContext(testCtx).Order("name").Find(&all).Error)
+	ids := []string{all[0].ID.String(), all[1].ID.String()}
+	assert.Equal(t, []string{all[0].Name, all[1].Name}, names(ProductFilter{IDs: ids, Sort: []SortField{{Field: "name"}}}))
+	assert.Empty(t, names(ProductFilter{IDs: []string{}}))
+}
+
+func TestFindProductsCreatedBy(t *testing.T) {
+	repo := NewProduct(openCategoryTestDB(t))
+	ana, bia := entityPkg.NewID(), entityPkg.NewID()
+
+	for _, p := range []struct {
+		name      string
+		createdBy *entityPkg.ID
+	}{
+		{"Blue Shirt", &ana},
+		{"Red Shirt", &bia},
+		{"Legacy Shirt", nil},
+	} {
+		product, _ := entity.NewProduct(p.name, entityPkg.MustParseMoney("10.00", "USD"))
+		product.CreatedBy = p.createdBy
+		product.UpdatedBy = p.createdBy
+		assert.NoError(t, repo.Create(testCtx, product))
+	}
+
+	products, err := repo.FindAllMatching(testCtx, ProductFilter{CreatedBy: ana.String()}, 0, 10)
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+	assert.Equal(t, "Blue Shirt", products[0].Name)
+	assert.True(t, products[0].IsOwnedBy(ana))
+	assert.Equal(t, ana, *products[0].UpdatedBy)
+
+	products, err = repo.FindAllMatching(testCtx, ProductFilter{NamePrefix: "legacy"}, 0, 10)
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+	assert.Nil(t, products[0].CreatedBy)
+}
@@ -0,0 +1,339 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"gorm.io/gorm"
+)
+
+var ErrNoSearchIndex = errors.New("full-text search index is not available on this database")
+
+const (
+	highlightStart = "<mark>"
+	highlightEnd   = "</mark>"
+)
+
+// ProductSearchResult is one product matching a search, with Rank growing
+// with relevance and Snippet holding the name as HTML, escaped, with the
+// matches highlighted.
+type ProductSearchResult struct {
+	Product *entity.Product
+	Rank    float64
+	Snippet string
+}
+
+// productSearchIndex is a full-text index over product names. Writes run in
+// the same transaction as the product change they mirror. The index holds
+// the products of every tenant, so searches join the products table to only
+// match those of tenantID.
+type productSearchIndex interface {
+	put(tx *gorm.DB, product *entity.Product) error
+	remove(tx *gorm.DB, id string) error
+	search(db *gorm.DB, tenantID entityPkg.ID, terms []string, offset, limit int) ([]productMatch, error)
+	rebuild(tx *gorm.DB) error
+}
+
+type productMatch struct {
+	ProductID string
+	Rank      float64
+}
+
+// newProductSearchIndex picks the best index the database offers: FTS5 on
+// sqlite and tsvector on postgres once the search migration created their
+// tables, and a slow LIKE scan everywhere else.
+func newProductSearchIndex(db *gorm.DB) productSearchIndex {
+	switch db.Dialector.Name() {
+	case DriverSQLite:
+		if db.Migrator().HasTable(fts5Table) {
+			return fts5Index{}
+		}
+	case DriverPostgres:
+		if db.Migrator().HasTable(tsvectorTable) {
+			return tsvectorIndex{}
+		}
+	}
+	return likeIndex{}
+}
+
+// Search ranks the products of the tenant whose name matches every word of
+// q, matching word prefixes so that "shi" finds "Shirt".
+func (p *GormProductRepository) Search(ctx context.Context, q string, page, limit int) ([]*ProductSearchResult, error) {
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return nil, ErrNoTenant
+	}
+
+	terms := SearchTerms(q)
+	if len(terms) == 0 {
+		return []*ProductSearchResult{}, nil
+	}
+
+	db := p.DB.WithContext(ctx)
+	matches, err := p.search.search(db, tenantID, terms, page*limit, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(matches))
+	for i, match := range matches {
+		ids[i] = match.ProductID
+	}
+
+	var products []*entity.Product
+	if err := db.Preload("Categories").Where("id IN ?", ids).Find(&products).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*entity.Product, len(products))
+	for _, product := range products {
+		byID[product.ID.String()] = product
+	}
+
+	results := make([]*ProductSearchResult, 0, len(matches))
+	for _, match := range matches {
+		product, ok := byID[match.ProductID]
+		if !ok {
+			continue
+		}
+		results = append(results, &ProductSearchResult{Product: product, Rank: match.Rank, Snippet: highlight(product.Name, terms)})
+	}
+	return results, nil
+}
+
+// RebuildSearchIndex drops everything in the search index and indexes every
+// product of every tenant again. It creates the index table first when the
+// database supports one but it is missing, e.g. on sqlite built without FTS5
+// when migrating; this repository keeps searching as before, and those
+// created from then on use the new index.
+func (p *GormProductRepository) RebuildSearchIndex(ctx context.Context) (int64, error) {
+	db := p.DB.WithContext(AllTenants(ctx))
+	index := p.search
+	if _, ok := index.(likeIndex); ok && p.DB.Dialector.Name() == DriverSQLite && sqliteHasFTS5(db) {
+		if err := db.Exec(fts5CreateTable).Error; err != nil {
+			return 0, err
+		}
+		index = fts5Index{}
+	}
+	if _, ok := index.(likeIndex); ok {
+		return 0, ErrNoSearchIndex
+	}
+
+	if err := db.Transaction(index.rebuild); err != nil {
+		return 0, err
+	}
+
+	var count int64
+	err := db.Model(&entity.Product{}).Count(&count).Error
+	return count, err
+}
+
+// CheckSearchIndex fails with ErrNoSearchIndex on sqlite built without FTS5,
+// which would scan the products table although it could have an index.
+// Databases without a full-text engine, like mysql, scan it by design.
+func (p *GormProductRepository) CheckSearchIndex() error {
+	if _, ok := p.search.(likeIndex); ok && p.DB.Dialector.Name() == DriverSQLite {
+		return ErrNoSearchIndex
+	}
+	return nil
+}
+
+// SearchTerms splits q into the lower case words a search matches on.
+// Everything but letters and digits separates words, so no query syntax of
+// the underlying engines can leak through.
+func SearchTerms(q string) []string {
+	return strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+const (
+	fts5Table       = "products_fts"
+	fts5CreateTable = "CREATE VIRTUAL TABLE IF NOT EXISTS products_fts USING fts5(product_id UNINDEXED, name, tokenize = 'unicode61 remove_diacritics 2')"
+)
+
+// fts5Index keeps product names in an FTS5 virtual table and ranks matches
+// with bm25.
+type fts5Index struct{}
+
+func (fts5Index) put(tx *gorm.DB, product *entity.Product) error {
+	if err := tx.Exec("DELETE FROM products_fts WHERE product_id = ?", product.ID.String()).Error; err != nil {
+		return err
+	}
+	return tx.Exec("INSERT INTO products_fts (product_id, name) VALUES (?, ?)", product.ID.String(), product.Name).Error
+}
+
+func (fts5Index) remove(tx *gorm.DB, id string) error {
+	return tx.Exec("DELETE FROM products_fts WHERE product_id = ?", id).Error
+}
+
+func (fts5Index) search(db *gorm.DB, tenantID entityPkg.ID, terms []string, offset, limit int) ([]productMatch, error) {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + term + `"*`
+	}
+
+	// bm25 is lower for better matches, so it is negated to rank upwards.
+	var matches []productMatch
+	err := db.Raw(`SELECT products_fts.product_id, -bm25(products_fts) AS rank
+		FROM products_fts JOIN products ON products.id = products_fts.product_id
+		WHERE products_fts MATCH ? AND products.tenant_id = ?
+		ORDER BY bm25(products_fts), products_fts.product_id LIMIT ? OFFSET ?`,
+		strings.Join(quoted, " "), tenantID, limit, offset).Scan(&matches).Error
+	return matches, err
+}
+
+func (fts5Index) rebuild(tx *gorm.DB) error {
+	if err := tx.Exec("DELETE FROM products_fts").Error; err != nil {
+		return err
+	}
+	return tx.Exec("INSERT INTO products_fts (product_id, name) SELECT id, name FROM products").Error
+}
+
+func sqliteHasFTS5(db *gorm.DB) bool {
+	var enabled int
+	err := db.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled).Error
+	return err == nil && enabled == 1
+}
+
+const tsvectorTable = "product_search"
+
+// tsvectorIndex keeps a tsvector per product in product_search, behind a
+// GIN index, and ranks matches with ts_rank.
+type tsvectorIndex struct{}
+
+func (tsvectorIndex) put(tx *gorm.DB, product *entity.Product) error {
+	return tx.Exec(`INSERT INTO product_search (product_id, name, document)
+		VALUES (?, ?, to_tsvector('simple', ?))
+		ON CONFLICT (product_id) DO UPDATE SET name = excluded.name, document = excluded.document`,
+		product.ID.String(), product.Name, product.Name).Error
+}
+
+func (tsvectorIndex) remove(tx *gorm.DB, id string) error {
+	return tx.Exec("DELETE FROM product_search WHERE product_id = ?", id).Error
+}
+
+func (tsvectorIndex) search(db *gorm.DB, tenantID entityPkg.ID, terms []string, offset, limit int) ([]productMatch, error) {
+	prefixed := make([]string, len(terms))
+	for i, term := range terms {
+		prefixed[i] = term + ":*"
+	}
+
+	var matches []productMatch
+	err := db.Raw(`SELECT product_search.product_id, ts_rank(product_search.document, query) AS rank
+		FROM product_search JOIN products ON products.id = product_search.product_id,
+			to_tsquery('simple', ?) query
+		WHERE product_search.document @@ query AND products.tenant_id = ?
+		ORDER BY rank DESC, product_search.product_id LIMIT ? OFFSET ?`,
+		strings.Join(prefixed, " & "), tenantID, limit, offset).Scan(&matches).Error
+	return matches, err
+}
+
+func (tsvectorIndex) rebuild(tx *gorm.DB) error {
+	if err := tx.Exec("DELETE FROM product_search").Error; err != nil {
+		return err
+	}
+	return tx.Exec(`INSERT INTO product_search (product_id, name, document)
+		SELECT id, name, to_tsvector('simple', name) FROM products`).Error
+}
+
+// likeIndex has nothing to keep in sync: it scans the products table with
+// LIKE and ranks in Go, favouring matches early in the name. It is only meant
+// for databases without a full-text engine.
+type likeIndex struct{}
+
+func (likeIndex) put(*gorm.DB, *entity.Product) error { return nil }
+
+func (likeIndex) remove(*gorm.DB, string) error { return nil }
+
+func (likeIndex) rebuild(*gorm.DB) error { return nil }
+
+// search leaves the tenant to the callbacks scoping the products table.
+func (likeIndex) search(db *gorm.DB, _ entityPkg.ID, terms []string, offset, limit int) ([]productMatch, error) {
+	query := db.Model(&entity.Product{}).Select("id", "name")
+	for _, term := range terms {
+		query = query.Where("LOWER(name) LIKE ? ESCAPE '!'", "%"+escapeLike(term)+"%")
+	}
+
+	var rows []struct {
+		ID   string
+		Name string
+	}
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	matches := make([]productMatch, len(rows))
+	for i, row := range rows {
+		lower := strings.ToLower(row.Name)
+		rank := 0.0
+		for _, term := range terms {
+			rank += 1 / float64(1+strings.Index(lower, term))
+		}
+		matches[i] = productMatch{ProductID: row.ID, Rank: rank}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Rank != matches[j].Rank {
+			return matches[i].Rank > matches[j].Rank
+		}
+		return matches[i].ProductID < matches[j].ProductID
+	})
+
+	if offset >= len(matches) {
+		return []productMatch{}, nil
+	}
+	matches = matches[offset:]
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// highlight escapes name as HTML, wrapping every case-insensitive occurrence
+// of terms in it. The engines only tell which products match, the name is
+// highlighted here so that no markup in it ever reaches the snippet.
+func highlight(name string, terms []string) string {
+	lower := strings.ToLower(name)
+	if len(lower) != len(name) {
+		// a few runes change their encoded length when lowered, which
+		// would throw the offsets off.
+		return html.EscapeString(name)
+	}
+
+	marked := make([]bool, len(name))
+	for _, term := range terms {
+		for start := 0; ; {
+			i := strings.Index(lower[start:], term)
+			if i < 0 {
+				break
+			}
+			for j := start + i; j < start+i+len(term); j++ {
+				marked[j] = true
+			}
+			start += i + len(term)
+		}
+	}
+
+	// the name is escaped once marked, a run at a time, so that terms are
+	// matched against the name rather than against its entities.
+	var b strings.Builder
+	for start := 0; start < len(name); {
+		end := start + 1
+		for end < len(name) && marked[end] == marked[start] {
+			end++
+		}
+		if marked[start] {
+			b.WriteString(highlightStart + html.EscapeString(name[start:end]) + highlightEnd)
+		} else {
+			b.WriteString(html.EscapeString(name[start:end]))
+		}
+		start = end
+	}
+	return b.String()
+}
@@ -0,0 +1,178 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/Mazzael/go-api/internal/infra/database/migrations"
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// openSearchTestDB migrates a sqlite database, which has the FTS5 index when
+// the tests run with -tags sqlite_fts5 and the LIKE fallback otherwise.
+func openSearchTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrations.NewMigrator(db).Up(); err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("searching with %T", newProductSearchIndex(db))
+	return db
+}
+
+func createNamedProducts(t *testing.T, repo *GormProductRepository, names ...string) []*entity.Product {
+	var products []*entity.Product
+	for _, name := range names {
+		product, err := entity.NewProduct(name, entityPkg.MustParseMoney("10.00", "USD"))
+		assert.NoError(t, err)
+		assert.NoError(t, repo.Create(testCtx, product))
+		products = append(products, product)
+	}
+	return products
+}
+
+func searchNames(t *testing.T, repo *GormProductRepository, q string) []string {
+	results, err := repo.Search(testCtx, q, 0, 10)
+	assert.NoError(t, err)
+	names := []string{}
+	for _, result := range results {
+		names = append(names, result.Product.Name)
+	}
+	return names
+}
+
+func TestSearchProductsRanksAndHighlights(t *testing.T) {
+	repo := NewProduct(openSearchTestDB(t))
+	createNamedProducts(t, repo, "Blue Cotton Shirt With Long Sleeves", "Shirt", "Red Hat", "Crème Brûlée Torch")
+
+	results, err := repo.Search(testCtx, "shirt", 0, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, "Shirt", results[0].Product.Name)
+		assert.Greater(t, results[0].Rank, results[1].Rank)
+		assert.Contains(t, results[1].Snippet, "<mark>Shirt</mark>")
+	}
+
+	assert.Equal(t, []string{"Blue Cotton Shirt With Long Sleeves"}, searchNames(t, repo, "blu shi"))
+	assert.Empty(t, searchNames(t, repo, "shirt hat"))
+	assert.Empty(t, searchNames(t, repo, "   "))
+}
+
+func TestSearchProductsIgnoresQuerySyntax(t *testing.T) {
+	repo := NewProduct(openSearchTestDB(t))
+	createNamedProducts(t, repo, "Shirt")
+
+	for _, q := range []string{`"shirt`, "shirt OR", "NEAR(shirt", "shirt*", "shirt:*", "!shirt & |", "%", "_"} {
+		_, err := repo.Search(testCtx, q, 0, 10)
+		assert.NoError(t, err, q)
+	}
+	assert.Equal(t, []string{"Shirt"}, searchNames(t, repo, `"shirt*`))
+}
+
+func TestSearchIndexFollowsProductChanges(t *testing.T) {
+	repo := NewProduct(openSearchTestDB(t))
+	products := createNamedProducts(t, repo, "Old Name", "Other")
+
+	products[0].Name = "New Name"
+	assert.NoError(t, repo.Update(testCtx, products[0]))
+	assert.Empty(t, searchNames(t, repo, "old"))
+	assert.Equal(t, []string{"New Name"}, searchNames(t, repo, "new"))
+
+	assert.NoError(t, repo.Delete(testCtx, products[0].ID.String()))
+	assert.Empty(t, searchNames(t, repo, "name"))
+	assert.Equal(t, []string{"Other"}, searchNames(t, repo, "other"))
+}
+
+func TestSearchProductsPages(t *testing.T) {
+	repo := NewProduct(openSearchTestDB(t))
+	createNamedProducts(t, repo, "Shirt A", "Shirt B", "Shirt C")
+
+	first, err := repo.Search(testCtx, "shirt", 0, 2)
+	assert.NoError(t, err)
+	second, err := repo.Search(testCtx, "shirt", 1, 2)
+	assert.NoError(t, err)
+	assert.Len(t, first, 2)
+	assert.Len(t, second, 1)
+}
+
+func TestSearchSnippetsEscapeNames(t *testing.T) {
+	repo := NewProduct(openSearchTestDB(t))
+	createNamedProducts(t, repo, `<img src=x onerror="alert(1)"> Shirt & Tie`)
+
+	results, err := repo.Search(testCtx, "shirt", 0, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, `&lt;img src=x onerror=&#34;alert(1)&#34;&gt; <mark>Shirt</mark> &amp; Tie`, results[0].Snippet)
+	}
+}
+
+func TestRebuildSearchIndex(t *testing.T) {
+	db := openSearchTestDB(t)
+	repo := NewProduct(db)
+
+	// rows written behind the repository's back are not indexed yet.
+	product, _ := entity.NewProduct("Imported Lamp", entityPkg.MustParseMoney("10.00", "USD"))
+	assert.NoError(t, db.WithContext(testCtx).Create(product).Error)
+
+	count, err := repo.RebuildSearchIndex(testCtx)
+	if !sqliteHasFTS5(db) {
+		assert.ErrorIs(t, err, ErrNoSearchIndex)
+		return
+	}
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	assert.Equal(t, []string{"Imported Lamp"}, searchNames(t, repo, "lamp"))
+}
+
+func TestCheckSearchIndex(t *testing.T) {
+	db := openSearchTestDB(t)
+	if sqliteHasFTS5(db) {
+		assert.NoError(t, NewProduct(db).CheckSearchIndex())
+	} else {
+		assert.ErrorIs(t, NewProduct(db).CheckSearchIndex(), ErrNoSearchIndex)
+	}
+}
+
+func TestCheckSearchIndexAcceptsTheFallbackOnMySQL(t *testing.T) {
+	// nothing is sent to the server until a statement runs.
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "root:secret@tcp(localhost:3306)/products", SkipInitializeWithVersion: true}),
+		&gorm.Config{DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := NewProduct(db)
+	assert.IsType(t, likeIndex{}, repo.search)
+	assert.NoError(t, repo.CheckSearchIndex())
+}
+
+func TestRebuildSearchIndexOnFTS5(t *testing.T) {
+	db := openSearchTestDB(t)
+	if !sqliteHasFTS5(db) {
+		t.Skip("sqlite driver built without FTS5, run make test or go test -tags sqlite_fts5")
+	}
+	repo := NewProduct(db)
+	createNamedProducts(t, repo, "Lamp")
+
+	assert.NoError(t, db.Exec("DELETE FROM products_fts").Error)
+	assert.Empty(t, searchNames(t, repo, "lamp"))
+
+	_, err := repo.RebuildSearchIndex(testCtx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Lamp"}, searchNames(t, repo, "lamp"))
+}
+
+func TestHighlight(t *testing.T) {
+	assert.Equal(t, "<mark>Shirt</mark> and <mark>shirt</mark>s", highlight("Shirt and shirts", []string{"shirt"}))
+	assert.Equal(t, "<mark>Blue Sh</mark>irt", highlight("Blue Shirt", []string{"blue", "e sh"}))
+	assert.Equal(t, "Hat", highlight("Hat", []string{"shirt"}))
+	assert.Equal(t, "&lt;<mark>b</mark>&gt;<mark>B</mark>old&lt;/<mark>b</mark>&gt;", highlight("<b>Bold</b>", []string{"b"}))
+	assert.Equal(t, "Tom &amp; <mark>amp</mark>", highlight("Tom & amp", []string{"amp"}))
+}
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	"gorm.io/gorm"
+)
+
+type GormRecoveryCodeRepository struct {
+	DB *gorm.DB
+}
+
+func NewRecoveryCode(db *gorm.DB) *GormRecoveryCodeRepository {
+	return &GormRecoveryCodeRepository{DB: db}
+}
+
+// Replace stores codes as the only recovery codes of the user, so that
+// those issued before stop working.
+func (c *GormRecoveryCodeRepository) Replace(ctx context.Context, userID string, codes []*entity.RecoveryCode) error {
+	return c.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&entity.RecoveryCode{}, "user_id = ?", userID).Error; err != nil {
+			return err
+		}
+		return tx.Create(codes).Error
+	})
+}
+
+// Consume marks the unused code of the user with hash used. Unknown and
+// used codes fail with ErrInvalidMFACode; of several concurrent attempts
+// with the same code only one wins.
+func (c *GormRecoveryCodeRepository) Consume(ctx context.Context, userID, hash string, now time.Time) error {
+	result := c.DB.WithContext(ctx).Model(&entity.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return entity.ErrInvalidMFACode
+	}
+	return nil
+}
+
+// CountUnused counts the codes the user has left.
+func (c *GormRecoveryCodeRepository) CountUnused(ctx context.Context, userID string) (int64, error) {
+	var count int64
+	err := c.DB.WithContext(ctx).Model(&entity.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
+
+func (c *GormRecoveryCodeRepository) DeleteForUser(ctx context.Context, userID string) error {
+	return c.DB.WithContext(ctx).Delete(&entity.RecoveryCode{}, "user_id = ?", userID).Error
+}
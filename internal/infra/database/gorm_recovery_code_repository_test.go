@@ -0,0 +1,47 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsumeRecoveryCodeOnce(t *testing.T) {
+	db := openUserTestDB(t)
+	repo := NewRecoveryCode(db)
+	user, _ := entity.NewUser("John Doe", "johndoe@example.com", "123456")
+	other, _ := entity.NewUser("Jane Doe", "janedoe@example.com", "123456")
+
+	codes, plain, _ := entity.NewRecoveryCodes(user.ID, time.Now())
+	assert.NoError(t, repo.Replace(testCtx, user.ID.String(), codes))
+
+	err := repo.Consume(testCtx, other.ID.String(), entity.HashRecoveryCode(plain[0]), time.Now())
+	assert.ErrorIs(t, err, entity.ErrInvalidMFACode)
+	assert.NoError(t, repo.Consume(testCtx, user.ID.String(), entity.HashRecoveryCode(plain[0]), time.Now()))
+	err = repo.Consume(testCtx, user.ID.String(), entity.HashRecoveryCode(plain[0]), time.Now())
+	assert.ErrorIs(t, err, entity.ErrInvalidMFACode)
+
+	unused, err := repo.CountUnused(testCtx, user.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(entity.RecoveryCodeCount-1), unused)
+}
+
+func TestReplaceRecoveryCodesDropsThePreviousOnes(t *testing.T) {
+	repo := NewRecoveryCode(openUserTestDB(t))
+	user, _ := entity.NewUser("John Doe", "johndoe@example.com", "123456")
+
+	first, firstPlain, _ := entity.NewRecoveryCodes(user.ID, time.Now())
+	assert.NoError(t, repo.Replace(testCtx, user.ID.String(), first))
+	second, secondPlain, _ := entity.NewRecoveryCodes(user.ID, time.Now())
+	assert.NoError(t, repo.Replace(testCtx, user.ID.String(), second))
+
+	err := repo.Consume(testCtx, user.ID.String(), entity.HashRecoveryCode(firstPlain[0]), time.Now())
+	assert.ErrorIs(t, err, entity.ErrInvalidMFACode)
+	assert.NoError(t, repo.Consume(testCtx, user.ID.String(), entity.HashRecoveryCode(secondPlain[0]), time.Now()))
+
+	assert.NoError(t, repo.DeleteForUser(testCtx, user.ID.String()))
+	unused, _ := repo.CountUnused(testCtx, user.ID.String())
+	assert.Zero(t, unused)
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type GormStockRepository struct {
+	DB *gorm.DB
+}
+
+func NewStock(db *gorm.DB) *GormStockRepository {
+	return &GormStockRepository{DB: db}
+}
+
+// Apply records the movement in the ledger and changes the stock level in
+// the same transaction. The level is changed with a single conditional
+// UPDATE, so concurrent movements can never take the available quantity
+// below zero; when they would, ErrInsufficientStock is returned and nothing
+// is recorded.
+func (s *GormStockRepository) Apply(ctx context.Context, movement *entity.StockMovement) (*entity.StockLevel, error) {
+	var level entity.StockLevel
+
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&entity.StockLevel{
+			ProductID: movement.ProductID,
+			UpdatedAt: time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		result := tx.Model(&entity.StockLevel{}).
+			Where("product_id = ?", movement.ProductID).
+			Where("on_hand + ? >= reserved + ?", movement.OnHandDelta, movement.ReservedDelta).
+			Where("reserved + ? >= 0", movement.ReservedDelta).
+			Updates(map[string]interface{}{
+				"on_hand":    gorm.Expr("on_hand + ?", movement.OnHandDelta),
+				"reserved":   gorm.Expr("reserved + ?", movement.ReservedDelta),
+				"updated_at": time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return entity.ErrInsufficientStock
+		}
+
+		if err := tx.First(&level, "product_id = ?", movement.ProductID).Error; err != nil {
+			return err
+		}
+
+		movement.OnHandAfter = level.OnHand
+		movement.ReservedAfter = level.Reserved
+		return tx.Create(movement).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &level, nil
+}
+
+// FindLevel returns the stock of the product, which is zero until its first
+// movement.
+func (s *GormStockRepository) FindLevel(ctx context.Context, productID string) (*entity.StockLevel, error) {
+	id, err := entityPkg.ParseID(productID)
+	if err != nil {
+		return nil, entity.ErrInvalidID
+	}
+
+	var level entity.StockLevel
+	err = s.DB.WithContext(ctx).First(&level, "product_id = ?", productID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &entity.StockLevel{ProductID: id}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &level, nil
+}
+
+func (s *GormStockRepository) FindMovements(ctx context.Context, productID string, page, limit int) ([]*entity.StockMovement, error) {
+	var movements []*entity.StockMovement
+	err := s.DB.WithContext(ctx).Where("product_id = ?", productID).
+		Order("created_at desc").
+		Offset(page * limit).
+		Limit(limit).
+		Find(&movements).Error
+	if err != nil {
+		return nil, err
+	}
+	return movements, nil
+}
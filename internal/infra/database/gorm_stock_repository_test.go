@@ -0,0 +1,168 @@
+package database
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func applyMovement(t *testing.T, repo *GormStockRepository, productID entityPkg.ID, movementType entity.MovementType, quantity int64) (*entity.StockLevel, error) {
+	movement, err := entity.NewStockMovement(productID, movementType, quantity, "")
+	assert.NoError(t, err)
+	return repo.Apply(testCtx, movement)
+}
+
+func TestApplyStockMovements(t *testing.T) {
+	for name, cfg := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			repo := NewStock(openTestBackend(t, cfg))
+			productID := entityPkg.NewID()
+
+			level, err := repo.FindLevel(testCtx, productID.String())
+			assert.NoError(t, err)
+			assert.Equal(t, int64(0), level.OnHand)
+
+			level, err = applyMovement(t, repo, productID, entity.MovementReceipt, 10)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(10), level.OnHand)
+
+			level, err = applyMovement(t, repo, productID, entity.MovementReservation, 4)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(4), level.Reserved)
+			assert.Equal(t, int64(6), level.Available())
+
+			_, err = applyMovement(t, repo, productID, entity.MovementSale, 7)
+			assert.ErrorIs(t, err, entity.ErrInsufficientStock)
+
+			_, err = applyMovement(t, repo, productID, entity.MovementRelease, 5)
+			assert.ErrorIs(t, err, entity.ErrInsufficientStock)
+
+			level, err = applyMovement(t, repo, productID, entity.MovementSale, 6)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(4), level.OnHand)
+
+			level, err = applyMovement(t, repo, productID, entity.MovementAdjustment, -1)
+			assert.ErrorIs(t, err, entity.ErrInsufficientStock)
+
+			level, err = applyMovement(t, repo, productID, entity.MovementReturn, 1)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(5), level.OnHand)
+
+			movements, err := repo.FindMovements(testCtx, productID.String(), 0, 100)
+			assert.NoError(t, err)
+			assert.Len(t, movements, 4)
+			for _, m := range movements {
+				assert.GreaterOrEqual(t, m.OnHandAfter, m.ReservedAfter)
+			}
+		})
+	}
+}
+
+func TestConcurrentSalesNeverOversell(t *testing.T) {
+	const stock, buyers = 30, 100
+
+	for name, cfg := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			repo := NewStock(openTestBackend(t, cfg))
+			productID := entityPkg.NewID()
+
+			_, err := applyMovement(t, repo, productID, entity.MovementReceipt, stock)
+			assert.NoError(t, err)
+
+			var (
+				wg           sync.WaitGroup
+				mu           sync.Mutex
+				sold, denied int
+			)
+			for i := 0; i < buyers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := applyMovement(t, repo, productID, entity.MovementSale, 1)
+
+					mu.Lock()
+					defer mu.Unlock()
+					switch {
+					case err == nil:
+						sold++
+					case errors.Is(err, entity.ErrInsufficientStock):
+						denied++
+					default:
+						t.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			assert.Equal(t, stock, sold)
+			assert.Equal(t, buyers-stock, denied)
+
+			level, err := repo.FindLevel(testCtx, productID.String())
+			assert.NoError(t, err)
+			assert.Equal(t, int64(0), level.OnHand)
+
+			movements, err := repo.FindMovements(testCtx, productID.String(), 0, buyers+1)
+			assert.NoError(t, err)
+			assert.Len(t, movements, stock+1)
+		})
+	}
+}
+
+func TestConcurrentMovementsKeepLedgerConsistent(t *testing.T) {
+	for name, cfg := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			repo := NewStock(openTestBackend(t, cfg))
+			productID := entityPkg.NewID()
+
+			types := []entity.MovementType{
+				entity.MovementReceipt,
+				entity.MovementSale,
+				entity.MovementSale,
+				entity.MovementReservation,
+				entity.MovementRelease,
+				entity.MovementReturn,
+				entity.MovementAdjustment,
+			}
+
+			var wg sync.WaitGroup
+			for i := 0; i < 50; i++ {
+				for j, movementType := range types {
+					quantity := int64(j%3 + 1)
+					if movementType == entity.MovementAdjustment && i%2 == 0 {
+						quantity = -quantity
+					}
+
+					wg.Add(1)
+					go func(movementType entity.MovementType, quantity int64) {
+						defer wg.Done()
+						_, err := applyMovement(t, repo, productID, movementType, quantity)
+						if err != nil && !errors.Is(err, entity.ErrInsufficientStock) {
+							t.Error(err)
+						}
+					}(movementType, quantity)
+				}
+			}
+			wg.Wait()
+
+			level, err := repo.FindLevel(testCtx, productID.String())
+			assert.NoError(t, err)
+			assert.GreaterOrEqual(t, level.Reserved, int64(0))
+			assert.GreaterOrEqual(t, level.Available(), int64(0))
+
+			movements, err := repo.FindMovements(testCtx, productID.String(), 0, 1000)
+			assert.NoError(t, err)
+
+			var onHand, reserved int64
+			for _, m := range movements {
+				onHand += m.OnHandDelta
+				reserved += m.ReservedDelta
+			}
+			assert.Equal(t, level.OnHand, onHand)
+			assert.Equal(t, level.Reserved, reserved)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	"gorm.io/gorm"
+)
+
+type GormTenantRepository struct {
+	DB *gorm.DB
+}
+
+func NewTenant(db *gorm.DB) *GormTenantRepository {
+	scopeByTenant(db)
+	return &GormTenantRepository{DB: db}
+}
+
+// Create stores tenant together with its first admin, failing with
+// ErrTenantSlugTaken when another tenant has the slug.
+func (t *GormTenantRepository) Create(ctx context.Context, tenant *entity.Tenant, admin *entity.User) error {
+	err := t.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(tenant).Error; err != nil {
+			return err
+		}
+		return tx.WithContext(WithTenant(ctx, tenant.ID)).Create(admin).Error
+	})
+	if translator, ok := t.DB.Dialector.(gorm.ErrorTranslator); ok && err != nil {
+		err = translator.Translate(err)
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return entity.ErrTenantSlugTaken
+	}
+	return err
+}
+
+// FindBySlug fails with entity.ErrUnknownTenant when no tenant has the slug.
+func (t *GormTenantRepository) FindBySlug(ctx context.Context, slug string) (*entity.Tenant, error) {
+	var tenant entity.Tenant
+	err := t.DB.WithContext(ctx).First(&tenant, "slug = ?", slug).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, entity.ErrUnknownTenant
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type GormRefreshTokenRepository struct {
+	DB *gorm.DB
+}
+
+func NewRefreshToken(db *gorm.DB) *GormRefreshTokenRepository {
+	return &GormRefreshTokenRepository{DB: db}
+}
+
+func (t *GormRefreshTokenRepository) Create(ctx context.Context, token *entity.RefreshToken) error {
+	return t.DB.WithContext(ctx).Create(token).Error
+}
+
+func (t *GormRefreshTokenRepository) FindByHash(ctx context.Context, hash string) (*entity.RefreshToken, error) {
+	var token entity.RefreshToken
+	if err := t.DB.WithContext(ctx).First(&token, "token_hash = ?", hash).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Rotate revokes current, points it at next and stores next. Only one of
+// several concurrent rotations of the same token can win; the others get
+// ErrRefreshTokenReused.
+func (t *GormRefreshTokenRepository) Rotate(ctx context.Context, current, next *entity.RefreshToken) error {
+	return t.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&entity.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", current.ID).
+			Updates(map[string]interface{}{"revoked_at": now, "replaced_by_id": next.ID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return entity.ErrRefreshTokenReused
+		}
+
+		current.RevokedAt = &now
+		current.ReplacedByID = &next.ID
+		return tx.Create(next).Error
+	})
+}
+
+func (t *GormRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	return t.DB.WithContext(ctx).Model(&entity.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeAllForUser revokes every refresh token of every login of the user.
+func (t *GormRefreshTokenRepository) RevokeAllForUser(ctx context.Context, userID string) error {
+	return t.DB.WithContext(ctx).Model(&entity.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// DeleteExpired removes tokens that can no longer be used or reused.
+func (t *GormRefreshTokenRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result := t.DB.WithContext(ctx).Where("expires_at <= ?", now).Delete(&entity.RefreshToken{})
+	return result.RowsAffected, result.Error
+}
+
+type GormRevokedTokenRepository struct {
+	DB *gorm.DB
+}
+
+func NewRevokedToken(db *gorm.DB) *GormRevokedTokenRepository {
+	return &GormRevokedTokenRepository{DB: db}
+}
+
+func (t *GormRevokedTokenRepository) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	return t.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&entity.RevokedAccessToken{
+		JTI:       jti,
+		ExpiresAt: expiresAt,
+	}).Error
+}
+
+func (t *GormRevokedTokenRepository) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	var count int64
+	err := t.DB.WithContext(ctx).Model(&entity.RevokedAccessToken{}).Where("jti = ?", jti).Count(&count).Error
+	return count > 0, err
+}
+
+// DeleteExpired removes blacklist entries whose tokens have expired anyway.
+func (t *GormRevokedTokenRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result := t.DB.WithContext(ctx).Where("expires_at <= ?", now).Delete(&entity.RevokedAccessToken{})
+	return result.RowsAffected, result.Error
+}
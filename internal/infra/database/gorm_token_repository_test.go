@@ -0,0 +1,148 @@
+package database
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func openTokenTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.AutoMigrate(&entity.RefreshToken{}, &entity.RevokedAccessToken{})
+	return db
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	repo := NewRefreshToken(openTokenTestDB(t))
+
+	current, plain, _ := entity.NewRefreshToken(entityPkg.NewID(), entityPkg.ID{}, time.Hour)
+	assert.NoError(t, repo.Create(testCtx, current))
+
+	found, err := repo.FindByHash(testCtx, entityPkg.HashToken(plain))
+	assert.NoError(t, err)
+	assert.Equal(t, current.ID, found.ID)
+
+	next, _, _ := entity.NewRefreshToken(current.UserID, current.FamilyID, time.Hour)
+	assert.NoError(t, repo.Rotate(testCtx, found, next))
+
+	found, err = repo.FindByHash(testCtx, entityPkg.HashToken(plain))
+	assert.NoError(t, err)
+	assert.True(t, found.IsRevoked())
+	assert.True(t, found.IsRotated())
+	assert.Equal(t, next.ID, *found.ReplacedByID)
+
+	again, _, _ := entity.NewRefreshToken(current.UserID, current.FamilyID, time.Hour)
+	assert.ErrorIs(t, repo.Rotate(testCtx, current, again), entity.ErrRefreshTokenReused)
+}
+
+func TestConcurrentRotationHasOneWinner(t *testing.T) {
+	db, err := NewConnection(Config{Driver: DriverSQLite, Name: SQLiteInMemory}, nil)
+	assert.NoError(t, err)
+	db.AutoMigrate(&entity.RefreshToken{})
+	repo := NewRefreshToken(db)
+
+	current, _, _ := entity.NewRefreshToken(entityPkg.NewID(), entityPkg.ID{}, time.Hour)
+	assert.NoError(t, repo.Create(testCtx, current))
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		wins int
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stale := *current
+			next, _, _ := entity.NewRefreshToken(current.UserID, current.FamilyID, time.Hour)
+			if repo.Rotate(testCtx, &stale, next) == nil {
+				mu.Lock()
+				wins++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, wins)
+}
+
+func TestRevokeRefreshTokenFamily(t *testing.T) {
+	repo := NewRefreshToken(openTokenTestDB(t))
+
+	first, _, _ := entity.NewRefreshToken(entityPkg.NewID(), entityPkg.ID{}, time.Hour)
+	second, secondPlain, _ := entity.NewRefreshToken(first.UserID, first.FamilyID, time.Hour)
+	other, otherPlain, _ := entity.NewRefreshToken(first.UserID, entityPkg.ID{}, time.Hour)
+	assert.NoError(t, repo.Create(testCtx, first))
+	assert.NoError(t, repo.Rotate(testCtx, first, second))
+	assert.NoError(t, repo.Create(testCtx, other))
+
+	assert.NoError(t, repo.RevokeFamily(testCtx, first.FamilyID.String()))
+
+	found, _ := repo.FindByHash(testCtx, entityPkg.HashToken(secondPlain))
+	assert.True(t, found.IsRevoked())
+
+	found, _ = repo.FindByHash(testCtx, entityPkg.HashToken(otherPlain))
+	assert.False(t, found.IsRevoked())
+}
+
+func TestRevokeAllRefreshTokensOfUser(t *testing.T) {
+	repo := NewRefreshToken(openTokenTestDB(t))
+
+	first, firstPlain, _ := entity.NewRefreshToken(entityPkg.NewID(), entityPkg.ID{}, time.Hour)
+	second, secondPlain, _ := entity.NewRefreshToken(first.UserID, entityPkg.ID{}, time.Hour)
+	other, otherPlain, _ := entity.NewRefreshToken(entityPkg.NewID(), entityPkg.ID{}, time.Hour)
+	assert.NoError(t, repo.Create(testCtx, first))
+	assert.NoError(t, repo.Create(testCtx, second))
+	assert.NoError(t, repo.Create(testCtx, other))
+
+	assert.NoError(t, repo.RevokeAllForUser(testCtx, first.UserID.String()))
+
+	for plain, revoked := range map[string]bool{firstPlain: true, secondPlain: true, otherPlain: false} {
+		found, _ := repo.FindByHash(testCtx, entityPkg.HashToken(plain))
+		assert.Equal(t, revoked, found.IsRevoked())
+	}
+}
+
+func TestDeleteExpiredRefreshTokens(t *testing.T) {
+	repo := NewRefreshToken(openTokenTestDB(t))
+
+	expired, _, _ := entity.NewRefreshToken(entityPkg.NewID(), entityPkg.ID{}, -time.Minute)
+	valid, _, _ := entity.NewRefreshToken(entityPkg.NewID(), entityPkg.ID{}, time.Hour)
+	assert.NoError(t, repo.Create(testCtx, expired))
+	assert.NoError(t, repo.Create(testCtx, valid))
+
+	deleted, err := repo.DeleteExpired(testCtx, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
+
+func TestRevokeAccessToken(t *testing.T) {
+	repo := NewRevokedToken(openTokenTestDB(t))
+
+	revoked, err := repo.IsRevoked(testCtx, "jti-1")
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	assert.NoError(t, repo.Revoke(testCtx, "jti-1", time.Now().Add(time.Hour)))
+	assert.NoError(t, repo.Revoke(testCtx, "jti-1", time.Now().Add(time.Hour)))
+	assert.NoError(t, repo.Revoke(testCtx, "jti-2", time.Now().Add(-time.Minute)))
+
+	revoked, err = repo.IsRevoked(testCtx, "jti-1")
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	deleted, err := repo.DeleteExpired(testCtx, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
@@ -1,6 +1,9 @@
 package database
 
 import (
+	"context"
+	"errors"
+
 	"github.com/Mazzael/go-api/internal/entity"
 	"gorm.io/gorm"
 )
@@ -10,16 +13,95 @@ type GormUserRepository struct {
 }
 
 func NewUser(db *gorm.DB) *GormUserRepository {
+	scopeByTenant(db)
 	return &GormUserRepository{DB: db}
 }
 
-func (u *GormUserRepository) Create(user *entity.User) error {
-	return u.DB.Create(user).Error
+// Create stores user in the tenant of ctx, failing with ErrEmailTaken when
+// another user of the tenant already has the email.
+func (u *GormUserRepository) Create(ctx context.Context, user *entity.User) error {
+	return u.translate(u.DB.WithContext(ctx).Create(user).Error)
+}
+
+func (u *GormUserRepository) FindByEmail(ctx context.Context, email string) (*entity.User, error) {
+	var user entity.User
+	if err := u.DB.WithContext(ctx).Where("email = ?", entity.NormalizeEmail(email)).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
 }
-func (u *GormUserRepository) FindByEmail(email string) (*entity.User, error) {
+
+func (u *GormUserRepository) FindByID(ctx context.Context, id string) (*entity.User, error) {
 	var user entity.User
-	if err := u.DB.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := u.DB.WithContext(ctx).First(&user, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
+
+// FindAll lists users ordered by email and paged by offset.
+func (u *GormUserRepository) FindAll(ctx context.Context, page, limit int) ([]*entity.User, error) {
+	var users []*entity.User
+	err := u.DB.WithContext(ctx).Order("email").Order("id").
+		Offset(page * limit).
+		Limit(limit).
+		Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// Update saves user, failing with ErrEmailTaken when its new email belongs
+// to another user of the tenant.
+func (u *GormUserRepository) Update(ctx context.Context, user *entity.User) error {
+	_, err := u.FindByID(ctx, user.ID.String())
+	if err != nil {
+		return err
+	}
+
+	return u.translate(u.DB.WithContext(ctx).Save(user).Error)
+}
+
+// Delete removes the user together with every refresh token, mailed token,
+// API key and recovery code issued to it.
+func (u *GormUserRepository) Delete(ctx context.Context, id string) error {
+	return u.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&entity.User{}, "id = ?", id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Delete(&entity.RefreshToken{}, "user_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&entity.UserToken{}, "user_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&entity.APIKey{}, "user_id = ?", id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&entity.RecoveryCode{}, "user_id = ?", id).Error
+	})
+}
+
+// CountByRole counts the enabled users of the tenant holding role.
+func (u *GormUserRepository) CountByRole(ctx context.Context, role entity.Role) (int64, error) {
+	var count int64
+	err := u.DB.WithContext(ctx).Model(&entity.User{}).Where("role = ? AND disabled = ?", role, false).Count(&count).Error
+	return count, err
+}
+
+// translate turns the unique violation on users.tenant_id and email into
+// ErrEmailTaken.
+func (u *GormUserRepository) translate(err error) error {
+	if translator, ok := u.DB.Dialector.(gorm.ErrorTranslator); ok && err != nil {
+		err = translator.Translate(err)
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return entity.ErrEmailTaken
+	}
+	return err
+}
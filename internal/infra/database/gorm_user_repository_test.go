@@ -1,16 +1,19 @@
 package database
 
 import (
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/Mazzael/go-api/internal/entity"
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 func TestCreateUser(t *testing.T) {
-	db, err := gorm.Open(sqlite.Open("file:memory:"), &gorm.Config{})
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
 	if err != nil {
 		t.Error(err)
 	}
@@ -19,11 +22,11 @@ func TestCreateUser(t *testing.T) {
 	user, _ := entity.NewUser("John Doe", "johndoe@example.com", "123456")
 	gormUserRepository := NewUser(db)
 
-	err = gormUserRepository.Create(user)
+	err = gormUserRepository.Create(testCtx, user)
 	assert.Nil(t, err)
 
 	var userFound entity.User
-	err = db.First(&userFound, "email = ?", user.Email).Error
+	err = db.WithContext(testCtx).First(&userFound, "email = ?", user.Email).Error
 	assert.Nil(t, err)
 	assert.Equal(t, user.Name, userFound.Name)
 	assert.Equal(t, user.Email, userFound.Email)
@@ -31,7 +34,7 @@ func TestCreateUser(t *testing.T) {
 }
 
 func TestFindByEmail(t *testing.T) {
-	db, err := gorm.Open(sqlite.Open("file:memory:"), &gorm.Config{})
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
 	if err != nil {
 		t.Error(err)
 	}
@@ -40,13 +43,135 @@ func TestFindByEmail(t *testing.T) {
 	user, _ := entity.NewUser("John Doe", "johndoe@example.com", "123456")
 	gormUserRepository := NewUser(db)
 
-	err = gormUserRepository.Create(user)
+	err = gormUserRepository.Create(testCtx, user)
 	assert.Nil(t, err)
 
-	userFound, err := gormUserRepository.FindByEmail("johndoe@example.com")
+	userFound, err := gormUserRepository.FindByEmail(testCtx, "JohnDoe@Example.com")
 	assert.Nil(t, err)
 	assert.NotNil(t, userFound)
 	assert.Equal(t, user.Name, userFound.Name)
 	assert.Equal(t, user.Email, userFound.Email)
 	assert.NotEmpty(t, userFound.Password)
 }
+
+func TestFindUserByID(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{})
+
+	user, _ := entity.NewUser("John Doe", "johndoe@example.com", "123456")
+	gormUserRepository := NewUser(db)
+
+	err = gormUserRepository.Create(testCtx, user)
+	assert.Nil(t, err)
+
+	userFound, err := gormUserRepository.FindByID(testCtx, user.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, user.Email, userFound.Email)
+	assert.Equal(t, entity.RoleViewer, userFound.Role)
+}
+
+func TestUpdateUserRole(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{})
+
+	user, _ := entity.NewUser("John Doe", "johndoe@example.com", "123456")
+	gormUserRepository := NewUser(db)
+	assert.Nil(t, gormUserRepository.Create(testCtx, user))
+
+	count, err := gormUserRepository.CountByRole(testCtx, entity.RoleAdmin)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), count)
+
+	user.Role = entity.RoleAdmin
+	assert.Nil(t, gormUserRepository.Update(testCtx, user))
+
+	count, err = gormUserRepository.CountByRole(testCtx, entity.RoleAdmin)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func openUserTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.AutoMigrate(&entity.User{}, &entity.RefreshToken{}, &entity.UserToken{}, &entity.APIKey{}, &entity.RecoveryCode{})
+	return db
+}
+
+func TestCreateUserWithTakenEmail(t *testing.T) {
+	gormUserRepository := NewUser(openUserTestDB(t))
+
+	first, _ := entity.NewUser("John Doe", "johndoe@example.com", "123456")
+	assert.Nil(t, gormUserRepository.Create(testCtx, first))
+
+	second, _ := entity.NewUser("Johnny Doe", "JOHNDOE@example.com", "654321")
+	assert.Equal(t, entity.ErrEmailTaken, gormUserRepository.Create(testCtx, second))
+
+	other, _ := entity.NewUser("Jane Doe", "janedoe@example.com", "123456")
+	assert.Nil(t, gormUserRepository.Create(testCtx, other))
+
+	other.Email = first.Email
+	assert.Equal(t, entity.ErrEmailTaken, gormUserRepository.Update(testCtx, other))
+}
+
+func TestFindAllUsers(t *testing.T) {
+	gormUserRepository := NewUser(openUserTestDB(t))
+
+	for _, email := range []string{"carol@example.com", "alice@example.com", "bob@example.com"} {
+		user, _ := entity.NewUser("User", email, "123456")
+		assert.Nil(t, gormUserRepository.Create(testCtx, user))
+	}
+
+	users, err := gormUserRepository.FindAll(testCtx, 0, 2)
+	assert.Nil(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, "alice@example.com", users[0].Email)
+	assert.Equal(t, "bob@example.com", users[1].Email)
+
+	users, err = gormUserRepository.FindAll(testCtx, 1, 2)
+	assert.Nil(t, err)
+	assert.Len(t, users, 1)
+	assert.Equal(t, "carol@example.com", users[0].Email)
+}
+
+func TestDeleteUser(t *testing.T) {
+	db := openUserTestDB(t)
+	gormUserRepository := NewUser(db)
+
+	user, _ := entity.NewUser("John Doe", "johndoe@example.com", "123456")
+	assert.Nil(t, gormUserRepository.Create(testCtx, user))
+	token, _, _ := entity.NewRefreshToken(user.ID, entityPkg.ID{}, time.Hour)
+	assert.Nil(t, NewRefreshToken(db).Create(testCtx, token))
+
+	assert.Nil(t, gormUserRepository.Delete(testCtx, user.ID.String()))
+
+	_, err := gormUserRepository.FindByID(testCtx, user.ID.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	var tokens int64
+	db.Model(&entity.RefreshToken{}).Where("user_id = ?", user.ID.String()).Count(&tokens)
+	assert.Equal(t, int64(0), tokens)
+
+	assert.ErrorIs(t, gormUserRepository.Delete(testCtx, user.ID.String()), gorm.ErrRecordNotFound)
+}
+
+func TestCountByRoleSkipsDisabledUsers(t *testing.T) {
+	gormUserRepository := NewUser(openUserTestDB(t))
+
+	user, _ := entity.NewUser("John Doe", "johndoe@example.com", "123456")
+	user.Role = entity.RoleAdmin
+	assert.Nil(t, gormUserRepository.Create(testCtx, user))
+
+	user.Disabled = true
+	assert.Nil(t, gormUserRepository.Update(testCtx, user))
+
+	count, err := gormUserRepository.CountByRole(testCtx, entity.RoleAdmin)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), count)
+}
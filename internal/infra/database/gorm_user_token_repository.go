@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	"gorm.io/gorm"
+)
+
+type GormUserTokenRepository struct {
+	DB *gorm.DB
+}
+
+func NewUserToken(db *gorm.DB) *GormUserTokenRepository {
+	return &GormUserTokenRepository{DB: db}
+}
+
+func (t *GormUserTokenRepository) Create(ctx context.Context, token *entity.UserToken) error {
+	return t.DB.WithContext(ctx).Create(token).Error
+}
+
+// Find returns the usable token with hash issued for purpose without using
+// it up, failing like Consume otherwise.
+func (t *GormUserTokenRepository) Find(ctx context.Context, hash string, purpose entity.TokenPurpose, now time.Time) (*entity.UserToken, error) {
+	var token entity.UserToken
+	err := t.DB.WithContext(ctx).First(&token, "token_hash = ? AND purpose = ?", hash, purpose).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, entity.ErrInvalidUserToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !token.IsUsable(now) {
+		return nil, entity.ErrInvalidUserToken
+	}
+	return &token, nil
+}
+
+// Consume marks the token with hash used and returns it. Unknown, expired
+// and already used tokens, and tokens issued for another purpose, all fail
+// with ErrInvalidUserToken; of several concurrent attempts only one wins.
+func (t *GormUserTokenRepository) Consume(ctx context.Context, hash string, purpose entity.TokenPurpose, now time.Time) (*entity.UserToken, error) {
+	var token entity.UserToken
+	err := t.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.First(&token, "token_hash = ? AND purpose = ?", hash, purpose).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entity.ErrInvalidUserToken
+		}
+		if err != nil {
+			return err
+		}
+		if !token.IsUsable(now) {
+			return entity.ErrInvalidUserToken
+		}
+
+		result := tx.Model(&entity.UserToken{}).
+			Where("id = ? AND used_at IS NULL", token.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return entity.ErrInvalidUserToken
+		}
+		token.UsedAt = &now
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// DeleteForUser removes the tokens of the user issued for purpose, so that
+// only the latest one mailed stays valid.
+func (t *GormUserTokenRepository) DeleteForUser(ctx context.Context, userID string, purpose entity.TokenPurpose) error {
+	return t.DB.WithContext(ctx).Where("user_id = ? AND purpose = ?", userID, purpose).Delete(&entity.UserToken{}).Error
+}
+
+// DeleteExpired removes tokens that can no longer be redeemed.
+func (t *GormUserTokenRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result := t.DB.WithContext(ctx).Where("expires_at <= ? OR used_at IS NOT NULL", now).Delete(&entity.UserToken{})
+	return result.RowsAffected, result.Error
+}
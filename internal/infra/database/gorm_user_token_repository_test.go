@@ -0,0 +1,93 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func openUserTokenTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.AutoMigrate(&entity.UserToken{})
+	return db
+}
+
+func TestConsumeUserTokenOnce(t *testing.T) {
+	repo := NewUserToken(openUserTokenTestDB(t))
+
+	token, plain, _ := entity.NewUserToken(entityPkg.NewID(), entity.TokenPurposePasswordReset, time.Hour)
+	assert.NoError(t, repo.Create(testCtx, token))
+
+	_, err := repo.Consume(testCtx, entityPkg.HashToken(plain), entity.TokenPurposeEmailVerification, time.Now())
+	assert.ErrorIs(t, err, entity.ErrInvalidUserToken)
+
+	consumed, err := repo.Consume(testCtx, entityPkg.HashToken(plain), entity.TokenPurposePasswordReset, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, token.UserID, consumed.UserID)
+	assert.NotNil(t, consumed.UsedAt)
+
+	_, err = repo.Consume(testCtx, entityPkg.HashToken(plain), entity.TokenPurposePasswordReset, time.Now())
+	assert.ErrorIs(t, err, entity.ErrInvalidUserToken)
+	_, err = repo.Consume(testCtx, entityPkg.HashToken("unknown"), entity.TokenPurposePasswordReset, time.Now())
+	assert.ErrorIs(t, err, entity.ErrInvalidUserToken)
+}
+
+func TestConsumeExpiredUserToken(t *testing.T) {
+	repo := NewUserToken(openUserTokenTestDB(t))
+
+	token, plain, _ := entity.NewUserToken(entityPkg.NewID(), entity.TokenPurposeEmailVerification, time.Hour)
+	assert.NoError(t, repo.Create(testCtx, token))
+
+	_, err := repo.Consume(testCtx, entityPkg.HashToken(plain), entity.TokenPurposeEmailVerification, time.Now().Add(2*time.Hour))
+	assert.ErrorIs(t, err, entity.ErrInvalidUserToken)
+
+	deleted, err := repo.DeleteExpired(testCtx, time.Now().Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
+
+func TestDeleteUserTokensForUser(t *testing.T) {
+	repo := NewUserToken(openUserTokenTestDB(t))
+	userID := entityPkg.NewID()
+
+	reset, resetPlain, _ := entity.NewUserToken(userID, entity.TokenPurposePasswordReset, time.Hour)
+	verify, verifyPlain, _ := entity.NewUserToken(userID, entity.TokenPurposeEmailVerification, time.Hour)
+	assert.NoError(t, repo.Create(testCtx, reset))
+	assert.NoError(t, repo.Create(testCtx, verify))
+
+	assert.NoError(t, repo.DeleteForUser(testCtx, userID.String(), entity.TokenPurposePasswordReset))
+
+	_, err := repo.Consume(testCtx, entityPkg.HashToken(resetPlain), entity.TokenPurposePasswordReset, time.Now())
+	assert.ErrorIs(t, err, entity.ErrInvalidUserToken)
+	_, err = repo.Consume(testCtx, entityPkg.HashToken(verifyPlain), entity.TokenPurposeEmailVerification, time.Now())
+	assert.NoError(t, err)
+}
+
+func TestFindUserTokenLeavesItUsable(t *testing.T) {
+	repo := NewUserToken(openUserTokenTestDB(t))
+
+	token, plain, _ := entity.NewUserToken(entityPkg.NewID(), entity.TokenPurposeMFAChallenge, time.Minute)
+	assert.NoError(t, repo.Create(testCtx, token))
+
+	found, err := repo.Find(testCtx, entityPkg.HashToken(plain), entity.TokenPurposeMFAChallenge, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, token.ID, found.ID)
+	_, err = repo.Find(testCtx, entityPkg.HashToken(plain), entity.TokenPurposePasswordReset, time.Now())
+	assert.ErrorIs(t, err, entity.ErrInvalidUserToken)
+	_, err = repo.Find(testCtx, entityPkg.HashToken(plain), entity.TokenPurposeMFAChallenge, time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, entity.ErrInvalidUserToken)
+
+	_, err = repo.Consume(testCtx, entityPkg.HashToken(plain), entity.TokenPurposeMFAChallenge, time.Now())
+	assert.NoError(t, err)
+	_, err = repo.Find(testCtx, entityPkg.HashToken(plain), entity.TokenPurposeMFAChallenge, time.Now())
+	assert.ErrorIs(t, err, entity.ErrInvalidUserToken)
+}
@@ -1,16 +1,109 @@
 package database
 
-import "github.com/Mazzael/go-api/internal/entity"
+import (
+	"context"
+	"time"
 
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/Mazzael/go-api/pkg/pagination"
+)
+
+// TenantRepository keeps the tenants sharing the deployment.
+type TenantRepository interface {
+	Create(ctx context.Context, tenant *entity.Tenant, admin *entity.User) error
+	FindBySlug(ctx context.Context, slug string) (*entity.Tenant, error)
+}
+
+// UserRepository only sees the users of the tenant of ctx; see WithTenant.
 type UserRepository interface {
-	Create(user *entity.User) error
-	FindByEmail(email string) (*entity.User, error)
+	Create(ctx context.Context, user *entity.User) error
+	FindByEmail(ctx context.Context, email string) (*entity.User, error)
+	FindByID(ctx context.Context, id string) (*entity.User, error)
+	FindAll(ctx context.Context, page, limit int) ([]*entity.User, error)
+	Update(ctx context.Context, user *entity.User) error
+	Delete(ctx context.Context, id string) error
+	CountByRole(ctx context.Context, role entity.Role) (int64, error)
 }
 
+// ProductRepository only sees the products of the tenant of ctx; see
+// WithTenant.
 type ProductRepository interface {
-	Create(product *entity.Product) error
-	FindAll(page, limit int, sort string) ([]*entity.Product, error)
-	FindByID(id string) (*entity.Product, error)
-	Update(product *entity.Product) error
-	Delete(id string) error
+	Create(ctx context.Context, product *entity.Product) error
+	FindAll(ctx context.Context, page, limit int, sort string) ([]*entity.Product, error)
+	FindAllByCategories(ctx context.Context, categoryIDs []string, page, limit int, sort string) ([]*entity.Product, error)
+	FindAllMatching(ctx context.Context, filter ProductFilter, page, limit int) ([]*entity.Product, error)
+	FindPage(ctx context.Context, filter ProductFilter, cursor *pagination.Cursor, limit int, sort string) (*ProductPage, error)
+	Search(ctx context.Context, q string, page, limit int) ([]*ProductSearchResult, error)
+	FindByID(ctx context.Context, id string) (*entity.Product, error)
+	Update(ctx context.Context, product *entity.Product) error
+	Delete(ctx context.Context, id string) error
+}
+
+type CategoryRepository interface {
+	Create(ctx context.Context, category *entity.Category) error
+	FindAll(ctx context.Context) ([]*entity.Category, error)
+	FindByID(ctx context.Context, id string) (*entity.Category, error)
+	FindByIDs(ctx context.Context, ids []string) ([]*entity.Category, error)
+	FindAncestors(ctx context.Context, id string) ([]*entity.Category, error)
+	FindDescendantIDs(ctx context.Context, id string) ([]string, error)
+	Update(ctx context.Context, category *entity.Category) error
+	Delete(ctx context.Context, id string) error
+}
+
+type StockRepository interface {
+	Apply(ctx context.Context, movement *entity.StockMovement) (*entity.StockLevel, error)
+	FindLevel(ctx context.Context, productID string) (*entity.StockLevel, error)
+	FindMovements(ctx context.Context, productID string, page, limit int) ([]*entity.StockMovement, error)
+}
+
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *entity.RefreshToken) error
+	FindByHash(ctx context.Context, hash string) (*entity.RefreshToken, error)
+	Rotate(ctx context.Context, current, next *entity.RefreshToken) error
+	RevokeFamily(ctx context.Context, familyID string) error
+	RevokeAllForUser(ctx context.Context, userID string) error
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+type RevokedTokenRepository interface {
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// UserTokenRepository keeps the single use tokens mailed to users to verify
+// their email or reset their password, and the challenges of logins waiting
+// for a second factor.
+type UserTokenRepository interface {
+	Create(ctx context.Context, token *entity.UserToken) error
+	Find(ctx context.Context, hash string, purpose entity.TokenPurpose, now time.Time) (*entity.UserToken, error)
+	Consume(ctx context.Context, hash string, purpose entity.TokenPurpose, now time.Time) (*entity.UserToken, error)
+	DeleteForUser(ctx context.Context, userID string, purpose entity.TokenPurpose) error
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *entity.APIKey) error
+	FindByHash(ctx context.Context, hash string) (*entity.APIKey, error)
+	FindByUser(ctx context.Context, userID string) ([]*entity.APIKey, error)
+	Revoke(ctx context.Context, id, userID string, now time.Time) error
+	Touch(ctx context.Context, key *entity.APIKey, now time.Time) error
+}
+
+// RecoveryCodeRepository keeps the hashed recovery codes that stand in for
+// a TOTP code once each.
+type RecoveryCodeRepository interface {
+	Replace(ctx context.Context, userID string, codes []*entity.RecoveryCode) error
+	Consume(ctx context.Context, userID, hash string, now time.Time) error
+	CountUnused(ctx context.Context, userID string) (int64, error)
+	DeleteForUser(ctx context.Context, userID string) error
+}
+
+// LoginAttemptRepository keeps the failed logins per account and client IP
+// that throttle further attempts.
+type LoginAttemptRepository interface {
+	Find(ctx context.Context, key string) (*entity.LoginAttempts, error)
+	RecordFailure(ctx context.Context, key string, now time.Time, policy entity.LoginPolicy) (*entity.LoginAttempts, error)
+	Reset(ctx context.Context, key string) error
+	DeleteExpired(ctx context.Context, now, before time.Time) (int64, error)
 }
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+var testLoginPolicy = entity.LoginPolicy{
+	FreeAttempts: 1,
+	BaseDelay:    time.Second,
+	MaxDelay:     time.Minute,
+	MaxFailures:  3,
+	Lockout:      15 * time.Minute,
+	Window:       time.Hour,
+}
+
+// loginAttemptRepositories returns every implementation of
+// LoginAttemptRepository, which must all behave the same.
+func loginAttemptRepositories(t *testing.T) map[string]LoginAttemptRepository {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.AutoMigrate(&entity.LoginAttempts{})
+
+	return map[string]LoginAttemptRepository{
+		"gorm":   NewLoginAttempt(db),
+		"memory": NewMemoryLoginAttempt(),
+	}
+}
+
+func TestLoginAttemptRepositoryLocksOut(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	for name, repo := range loginAttemptRepositories(t) {
+		attempts, err := repo.Find(testCtx, "account:ana@example.com")
+		assert.NoError(t, err, name)
+		assert.Equal(t, 0, attempts.Failures, name)
+
+		for i := 0; i < 3; i++ {
+			attempts, err = repo.RecordFailure(testCtx, "account:ana@example.com", now, testLoginPolicy)
+			assert.NoError(t, err, name)
+		}
+		assert.Equal(t, 3, attempts.Failures, name)
+		assert.True(t, attempts.IsLockedOut(now, testLoginPolicy), name)
+
+		attempts, err = repo.Find(testCtx, "account:ana@example.com")
+		assert.NoError(t, err, name)
+		assert.Equal(t, 15*time.Minute, attempts.RetryAfter(now), name)
+
+		other, _ := repo.Find(testCtx, "ip:192.0.2.1")
+		assert.Equal(t, time.Duration(0), other.RetryAfter(now), name)
+
+		assert.NoError(t, repo.Reset(testCtx, "account:ana@example.com"), name)
+		attempts, _ = repo.Find(testCtx, "account:ana@example.com")
+		assert.Equal(t, 0, attempts.Failures, name)
+	}
+}
+
+func TestLoginAttemptRepositoryDeletesExpired(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	for name, repo := range loginAttemptRepositories(t) {
+		repo.RecordFailure(testCtx, "ip:192.0.2.1", now.Add(-2*time.Hour), testLoginPolicy)
+		for i := 0; i < 3; i++ {
+			repo.RecordFailure(testCtx, "ip:192.0.2.2", now.Add(-2*time.Hour), entity.LoginPolicy{MaxFailures: 3, Lockout: 3 * time.Hour})
+		}
+		repo.RecordFailure(testCtx, "ip:192.0.2.3", now, testLoginPolicy)
+
+		deleted, err := repo.DeleteExpired(testCtx, now, now.Add(-time.Hour))
+		assert.NoError(t, err, name)
+		assert.Equal(t, int64(1), deleted, name)
+
+		attempts, _ := repo.Find(testCtx, "ip:192.0.2.2")
+		assert.Equal(t, 3, attempts.Failures, name)
+	}
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/entity"
+)
+
+// MemoryLoginAttemptRepository keeps failed logins in process memory. It
+// suits a single instance; several instances each count on their own, and a
+// restart forgets everything.
+type MemoryLoginAttemptRepository struct {
+	mu       sync.Mutex
+	attempts map[string]entity.LoginAttempts
+}
+
+func NewMemoryLoginAttempt() *MemoryLoginAttemptRepository {
+	return &MemoryLoginAttemptRepository{attempts: map[string]entity.LoginAttempts{}}
+}
+
+func (m *MemoryLoginAttemptRepository) Find(ctx context.Context, key string) (*entity.LoginAttempts, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attempts, ok := m.attempts[key]
+	if !ok {
+		attempts = entity.LoginAttempts{Key: key}
+	}
+	return &attempts, nil
+}
+
+func (m *MemoryLoginAttemptRepository) RecordFailure(ctx context.Context, key string, now time.Time, policy entity.LoginPolicy) (*entity.LoginAttempts, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attempts, ok := m.attempts[key]
+	if !ok {
+		attempts = entity.LoginAttempts{Key: key}
+	}
+	attempts.Fail(now, policy)
+	m.attempts[key] = attempts
+	return &attempts, nil
+}
+
+func (m *MemoryLoginAttemptRepository) Reset(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.attempts, key)
+	return nil
+}
+
+func (m *MemoryLoginAttemptRepository) DeleteExpired(ctx context.Context, now, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted int64
+	for key, attempts := range m.attempts {
+		if attempts.BlockedUntil.Before(now) && attempts.LastFailure.Before(before) {
+			delete(m.attempts, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,54 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The baseline snapshots the products and users tables as db.AutoMigrate
+// used to create them. Tables that already exist are left untouched so
+// databases created before migrations were introduced upgrade cleanly.
+
+type baselineProduct struct {
+	ID        string `gorm:"type:varchar(36);primaryKey"`
+	Name      string
+	Price     float64
+	CreatedAt time.Time
+}
+
+func (baselineProduct) TableName() string {
+	return "products"
+}
+
+type baselineUser struct {
+	ID       string `gorm:"type:varchar(36);primaryKey"`
+	Name     string
+	Email    string
+	Password string
+}
+
+func (baselineUser) TableName() string {
+	return "users"
+}
+
+func init() {
+	Register(&Migration{
+		Version: "20250705000000",
+		Name:    "baseline",
+		Up: func(tx *gorm.DB) error {
+			for _, table := range []interface{}{&baselineProduct{}, &baselineUser{}} {
+				if tx.Migrator().HasTable(table) {
+					continue
+				}
+				if err := tx.Migrator().CreateTable(table); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&baselineUser{}, &baselineProduct{})
+		},
+	})
+}
@@ -0,0 +1,88 @@
+package migrations
+
+import (
+	"math"
+
+	"github.com/Mazzael/go-api/pkg/entity"
+	"gorm.io/gorm"
+)
+
+// Product prices move from a float64 price column to an integer amount of
+// minor units plus an ISO 4217 currency code. Existing prices are taken to
+// be in entity.DefaultCurrency.
+
+type moneyProduct struct {
+	ID            string `gorm:"type:varchar(36);primaryKey"`
+	Price         float64
+	PriceAmount   int64
+	PriceCurrency string `gorm:"type:varchar(3)"`
+}
+
+func (moneyProduct) TableName() string {
+	return "products"
+}
+
+func init() {
+	Register(&Migration{
+		Version: "20261018100000",
+		Name:    "product_price_money",
+		Up: func(tx *gorm.DB) error {
+			for _, column := range []string{"PriceAmount", "PriceCurrency"} {
+				if err := tx.Migrator().AddColumn(&moneyProduct{}, column); err != nil {
+					return err
+				}
+			}
+
+			var products []moneyProduct
+			err := tx.Select("id", "price").FindInBatches(&products, 500, func(batch *gorm.DB, _ int) error {
+				for _, p := range products {
+					price, err := entity.NewMoneyFromFloat(p.Price, entity.DefaultCurrency, entity.RoundHalfUp)
+					if err != nil {
+						return err
+					}
+					err = batch.Model(&moneyProduct{}).Where("id = ?", p.ID).Updates(map[string]interface{}{
+						"price_amount":   price.Amount,
+						"price_currency": price.Currency,
+					}).Error
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			}).Error
+			if err != nil {
+				return err
+			}
+
+			return tx.Migrator().DropColumn(&moneyProduct{}, "Price")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&moneyProduct{}, "Price"); err != nil {
+				return err
+			}
+
+			var products []moneyProduct
+			err := tx.Select("id", "price_amount", "price_currency").FindInBatches(&products, 500, func(batch *gorm.DB, _ int) error {
+				for _, p := range products {
+					money := entity.Money{Amount: p.PriceAmount, Currency: p.PriceCurrency}
+					price := float64(money.Amount) / math.Pow10(money.Exponent())
+					err := batch.Model(&moneyProduct{}).Where("id = ?", p.ID).Update("price", price).Error
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			}).Error
+			if err != nil {
+				return err
+			}
+
+			for _, column := range []string{"PriceAmount", "PriceCurrency"} {
+				if err := tx.Migrator().DropColumn(&moneyProduct{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
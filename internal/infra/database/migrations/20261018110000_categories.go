@@ -0,0 +1,40 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type categoriesCategory struct {
+	ID        string  `gorm:"type:varchar(36);primaryKey"`
+	Name      string  `gorm:"not null"`
+	ParentID  *string `gorm:"type:varchar(36);index"`
+	CreatedAt time.Time
+}
+
+func (categoriesCategory) TableName() string {
+	return "categories"
+}
+
+type categoriesProductCategory struct {
+	ProductID  string `gorm:"type:varchar(36);primaryKey"`
+	CategoryID string `gorm:"type:varchar(36);primaryKey;index"`
+}
+
+func (categoriesProductCategory) TableName() string {
+	return "product_categories"
+}
+
+func init() {
+	Register(&Migration{
+		Version: "20261018110000",
+		Name:    "categories",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&categoriesCategory{}, &categoriesProductCategory{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&categoriesProductCategory{}, &categoriesCategory{})
+		},
+	})
+}
@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type stockLevel struct {
+	ProductID string `gorm:"type:varchar(36);primaryKey"`
+	OnHand    int64  `gorm:"not null;default:0;check:chk_stock_levels_available,on_hand >= reserved"`
+	Reserved  int64  `gorm:"not null;default:0;check:chk_stock_levels_reserved,reserved >= 0"`
+	UpdatedAt time.Time
+}
+
+func (stockLevel) TableName() string {
+	return "stock_levels"
+}
+
+type stockMovement struct {
+	ID            string `gorm:"type:varchar(36);primaryKey"`
+	ProductID     string `gorm:"type:varchar(36);not null;index"`
+	Type          string `gorm:"type:varchar(20);not null"`
+	Quantity      int64  `gorm:"not null"`
+	OnHandDelta   int64  `gorm:"not null"`
+	ReservedDelta int64  `gorm:"not null"`
+	OnHandAfter   int64  `gorm:"not null"`
+	ReservedAfter int64  `gorm:"not null"`
+	Reason        string
+	CreatedAt     time.Time `gorm:"index"`
+}
+
+func (stockMovement) TableName() string {
+	return "stock_movements"
+}
+
+func init() {
+	Register(&Migration{
+		Version: "20261018120000",
+		Name:    "stock",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&stockLevel{}, &stockMovement{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&stockMovement{}, &stockLevel{})
+		},
+	})
+}
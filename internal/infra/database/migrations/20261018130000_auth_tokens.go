@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type authRefreshToken struct {
+	ID           string    `gorm:"type:varchar(36);primaryKey"`
+	UserID       string    `gorm:"type:varchar(36);not null;index"`
+	FamilyID     string    `gorm:"type:varchar(36);not null;index"`
+	TokenHash    string    `gorm:"type:varchar(64);not null;uniqueIndex"`
+	ExpiresAt    time.Time `gorm:"not null"`
+	RevokedAt    *time.Time
+	ReplacedByID *string `gorm:"type:varchar(36)"`
+	CreatedAt    time.Time
+}
+
+func (authRefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+type authRevokedAccessToken struct {
+	JTI       string    `gorm:"type:varchar(64);primaryKey"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
+
+func (authRevokedAccessToken) TableName() string {
+	return "revoked_access_tokens"
+}
+
+func init() {
+	Register(&Migration{
+		Version: "20261018130000",
+		Name:    "auth_tokens",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&authRefreshToken{}, &authRevokedAccessToken{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&authRevokedAccessToken{}, &authRefreshToken{})
+		},
+	})
+}
@@ -0,0 +1,27 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// Every existing user starts as a viewer; the first admin is created or
+// promoted with the bootstrap command.
+
+type rolesUser struct {
+	Role string `gorm:"type:varchar(20);not null;default:viewer;index"`
+}
+
+func (rolesUser) TableName() string {
+	return "users"
+}
+
+func init() {
+	Register(&Migration{
+		Version: "20261018140000",
+		Name:    "user_roles",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().AddColumn(&rolesUser{}, "Role")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&rolesUser{}, "Role")
+		},
+	})
+}
@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Keyset pagination orders and seeks products on (created_at, id).
+
+type createdAtIndexProduct struct {
+	ID        string    `gorm:"type:varchar(36);primaryKey;index:idx_products_created_at_id,priority:2"`
+	CreatedAt time.Time `gorm:"index:idx_products_created_at_id,priority:1"`
+}
+
+func (createdAtIndexProduct) TableName() string {
+	return "products"
+}
+
+func init() {
+	Register(&Migration{
+		Version: "20261018150000",
+		Name:    "products_created_at_index",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateIndex(&createdAtIndexProduct{}, "idx_products_created_at_id")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropIndex(&createdAtIndexProduct{}, "idx_products_created_at_id")
+		},
+	})
+}
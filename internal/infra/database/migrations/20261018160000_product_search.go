@@ -0,0 +1,54 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// Full-text search over product names. sqlite gets an FTS5 virtual table
+// when the driver was built with FTS5 (go build -tags sqlite_fts5) and
+// postgres a tsvector table behind a GIN index. Other databases, and sqlite
+// without FTS5, fall back to scanning products; the reindex command creates
+// the FTS5 table later if the binary is rebuilt with FTS5.
+
+func init() {
+	Register(&Migration{
+		Version: "20261018160000",
+		Name:    "product_search",
+		Up: func(tx *gorm.DB) error {
+			switch tx.Dialector.Name() {
+			case "sqlite":
+				var enabled int
+				if err := tx.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled).Error; err != nil || enabled != 1 {
+					return err
+				}
+				return execAll(tx,
+					"CREATE VIRTUAL TABLE products_fts USING fts5(product_id UNINDEXED, name, tokenize = 'unicode61 remove_diacritics 2')",
+					"INSERT INTO products_fts (product_id, name) SELECT id, name FROM products",
+				)
+			case "postgres":
+				return execAll(tx,
+					"CREATE TABLE product_search (product_id varchar(36) PRIMARY KEY, name text NOT NULL, document tsvector NOT NULL)",
+					"CREATE INDEX idx_product_search_document ON product_search USING GIN (document)",
+					"INSERT INTO product_search (product_id, name, document) SELECT id, name, to_tsvector('simple', name) FROM products",
+				)
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			switch tx.Dialector.Name() {
+			case "sqlite":
+				return tx.Exec("DROP TABLE IF EXISTS products_fts").Error
+			case "postgres":
+				return tx.Exec("DROP TABLE IF EXISTS product_search").Error
+			}
+			return nil
+		},
+	})
+}
+
+func execAll(tx *gorm.DB, statements ...string) error {
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
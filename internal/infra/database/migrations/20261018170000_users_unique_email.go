@@ -0,0 +1,56 @@
+package migrations
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Emails are stored trimmed and lower cased and must be unique. Databases
+// that already hold users sharing an email refuse to migrate until those
+// accounts are merged or removed, since picking one silently would lock the
+// other user out.
+
+type uniqueEmailUser struct {
+	Email string `gorm:"type:varchar(254);uniqueIndex:idx_users_email"`
+}
+
+func (uniqueEmailUser) TableName() string {
+	return "users"
+}
+
+func init() {
+	Register(&Migration{
+		Version: "20261018170000",
+		Name:    "users_unique_email",
+		Up: func(tx *gorm.DB) error {
+			var duplicates []string
+			err := tx.Table("users").
+				Select("LOWER(TRIM(email))").
+				Group("LOWER(TRIM(email))").
+				Having("COUNT(*) > 1").
+				Scan(&duplicates).Error
+			if err != nil {
+				return err
+			}
+			if len(duplicates) > 0 {
+				return fmt.Errorf("users share the emails %s: merge or remove them before migrating", strings.Join(duplicates, ", "))
+			}
+
+			if err := tx.Exec("UPDATE users SET email = LOWER(TRIM(email))").Error; err != nil {
+				return err
+			}
+			if tx.Dialector.Name() == "mysql" {
+				// mysql cannot index a text column without a prefix length.
+				if err := tx.Migrator().AlterColumn(&uniqueEmailUser{}, "Email"); err != nil {
+					return err
+				}
+			}
+			return tx.Migrator().CreateIndex(&uniqueEmailUser{}, "idx_users_email")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropIndex(&uniqueEmailUser{}, "idx_users_email")
+		},
+	})
+}
@@ -0,0 +1,31 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// Admins can disable accounts; disabled users cannot log in or refresh.
+
+type disabledUser struct {
+	Disabled bool `gorm:"not null;default:false"`
+}
+
+func (disabledUser) TableName() string {
+	return "users"
+}
+
+func init() {
+	Register(&Migration{
+		Version: "20261018180000",
+		Name:    "users_disabled",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().AddColumn(&disabledUser{}, "Disabled")
+		},
+		Down: func(tx *gorm.DB) error {
+			if tx.Dialector.Name() == "sqlite" {
+				// the sqlite migrator drops columns by recreating the table,
+				// which loses idx_users_email.
+				return tx.Exec("ALTER TABLE users DROP COLUMN disabled").Error
+			}
+			return tx.Migrator().DropColumn(&disabledUser{}, "Disabled")
+		},
+	})
+}
@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type loginAttempts struct {
+	LoginKey     string    `gorm:"type:varchar(320);primaryKey"`
+	Failures     int       `gorm:"not null"`
+	LastFailure  time.Time `gorm:"not null"`
+	BlockedUntil time.Time `gorm:"not null;index"`
+}
+
+func (loginAttempts) TableName() string {
+	return "login_attempts"
+}
+
+func init() {
+	Register(&Migration{
+		Version: "20261018190000",
+		Name:    "login_attempts",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&loginAttempts{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&loginAttempts{})
+		},
+	})
+}
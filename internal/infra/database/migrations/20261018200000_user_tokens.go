@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type userToken struct {
+	ID        string    `gorm:"type:varchar(36);primaryKey"`
+	UserID    string    `gorm:"type:varchar(36);not null;index"`
+	Purpose   string    `gorm:"type:varchar(32);not null"`
+	TokenHash string    `gorm:"type:varchar(64);not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (userToken) TableName() string {
+	return "user_tokens"
+}
+
+func init() {
+	Register(&Migration{
+		Version: "20261018200000",
+		Name:    "user_tokens",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&userToken{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&userToken{})
+		},
+	})
+}
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Users verify their email before they may change products. Accounts that
+// exist already are taken as verified, so upgrading does not lock anyone
+// out of what they could do before.
+
+type emailVerifiedUser struct {
+	EmailVerifiedAt *time.Time
+}
+
+func (emailVerifiedUser) TableName() string {
+	return "users"
+}
+
+func init() {
+	Register(&Migration{
+		Version: "20261018210000",
+		Name:    "users_email_verified",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&emailVerifiedUser{}, "EmailVerifiedAt"); err != nil {
+				return err
+			}
+			return tx.Table("users").Where("1 = 1").Update("email_verified_at", time.Now()).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			if tx.Dialector.Name() == "sqlite" {
+				// see users_disabled.
+				return tx.Exec("ALTER TABLE users DROP COLUMN email_verified_at").Error
+			}
+			return tx.Migrator().DropColumn(&emailVerifiedUser{}, "EmailVerifiedAt")
+		},
+	})
+}
@@ -0,0 +1,68 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type totpUser struct {
+	TOTPSecret    string `gorm:"type:varchar(64)"`
+	TOTPEnabledAt *time.Time
+	TOTPLastStep  int64 `gorm:"not null;default:0"`
+}
+
+func (totpUser) TableName() string {
+	return "users"
+}
+
+type recoveryCode struct {
+	ID        string `gorm:"type:varchar(36);primaryKey"`
+	UserID    string `gorm:"type:varchar(36);not null;index"`
+	CodeHash  string `gorm:"type:varchar(64);not null;uniqueIndex"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (recoveryCode) TableName() string {
+	return "recovery_codes"
+}
+
+var totpColumns = map[string]string{
+	"TOTPSecret":    "totp_secret",
+	"TOTPEnabledAt": "totp_enabled_at",
+	"TOTPLastStep":  "totp_last_step",
+}
+
+func init() {
+	Register(&Migration{
+		Version: "20261018230000",
+		Name:    "user_totp",
+		Up: func(tx *gorm.DB) error {
+			for _, field := range []string{"TOTPSecret", "TOTPEnabledAt", "TOTPLastStep"} {
+				if err := tx.Migrator().AddColumn(&totpUser{}, field); err != nil {
+					return err
+				}
+			}
+			return tx.Migrator().CreateTable(&recoveryCode{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&recoveryCode{}); err != nil {
+				return err
+			}
+			for _, field := range []string{"TOTPLastStep", "TOTPEnabledAt", "TOTPSecret"} {
+				var err error
+				if tx.Dialector.Name() == "sqlite" {
+					// see users_disabled.
+					err = tx.Exec("ALTER TABLE users DROP COLUMN " + totpColumns[field]).Error
+				} else {
+					err = tx.Migrator().DropColumn(&totpUser{}, field)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
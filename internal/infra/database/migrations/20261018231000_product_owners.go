@@ -0,0 +1,48 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// Products record the users who created and last changed them. Products
+// created before have no owner.
+
+type ownedProduct struct {
+	CreatedBy *string `gorm:"type:varchar(36);index:idx_products_created_by"`
+	UpdatedBy *string `gorm:"type:varchar(36)"`
+}
+
+func (ownedProduct) TableName() string {
+	return "products"
+}
+
+func init() {
+	Register(&Migration{
+		Version: "20261018231000",
+		Name:    "product_owners",
+		Up: func(tx *gorm.DB) error {
+			for _, field := range []string{"CreatedBy", "UpdatedBy"} {
+				if err := tx.Migrator().AddColumn(&ownedProduct{}, field); err != nil {
+					return err
+				}
+			}
+			return tx.Migrator().CreateIndex(&ownedProduct{}, "idx_products_created_by")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropIndex(&ownedProduct{}, "idx_products_created_by"); err != nil {
+				return err
+			}
+			if tx.Dialector.Name() == "sqlite" {
+				// see users_disabled.
+				if err := tx.Exec("ALTER TABLE products DROP COLUMN updated_by").Error; err != nil {
+					return err
+				}
+				return tx.Exec("ALTER TABLE products DROP COLUMN created_by").Error
+			}
+			for _, field := range []string{"UpdatedBy", "CreatedBy"} {
+				if err := tx.Migrator().DropColumn(&ownedProduct{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,105 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/Mazzael/go-api/pkg/entity"
+	"gorm.io/gorm"
+)
+
+// Users and products belong to a tenant. Everything from before is moved
+// into the default tenant, and emails only have to be unique per tenant.
+
+type tenant struct {
+	ID        string `gorm:"type:varchar(36);primaryKey"`
+	Slug      string `gorm:"type:varchar(63);not null;uniqueIndex"`
+	Name      string `gorm:"type:varchar(100);not null"`
+	CreatedAt time.Time
+}
+
+func (tenant) TableName() string {
+	return "tenants"
+}
+
+type tenantUser struct {
+	TenantID string `gorm:"type:varchar(36);uniqueIndex:idx_users_tenant_email,priority:1"`
+	Email    string `gorm:"type:varchar(254);uniqueIndex:idx_users_tenant_email,priority:2"`
+}
+
+func (tenantUser) TableName() string {
+	return "users"
+}
+
+type tenantProduct struct {
+	TenantID string `gorm:"type:varchar(36);index:idx_products_tenant_id"`
+}
+
+func (tenantProduct) TableName() string {
+	return "products"
+}
+
+func init() {
+	Register(&Migration{
+		Version: "20261018232000",
+		Name:    "tenants",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().CreateTable(&tenant{}); err != nil {
+				return err
+			}
+			defaultTenant := tenant{ID: entity.NewID().String(), Slug: "default", Name: "Default", CreatedAt: time.Now()}
+			if err := tx.Create(&defaultTenant).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Migrator().AddColumn(&tenantUser{}, "TenantID"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().AddColumn(&tenantProduct{}, "TenantID"); err != nil {
+				return err
+			}
+			for _, table := range []string{"users", "products"} {
+				if err := tx.Table(table).Where("1 = 1").Update("tenant_id", defaultTenant.ID).Error; err != nil {
+					return err
+				}
+			}
+
+			if err := tx.Migrator().DropIndex(&uniqueEmailUser{}, "idx_users_email"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().CreateIndex(&tenantUser{}, "idx_users_tenant_email"); err != nil {
+				return err
+			}
+			return tx.Migrator().CreateIndex(&tenantProduct{}, "idx_products_tenant_id")
+		},
+		Down: func(tx *gorm.DB) error {
+			// fails on emails used in several tenants, like users_unique_email.
+			if err := tx.Migrator().DropIndex(&tenantUser{}, "idx_users_tenant_email"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().CreateIndex(&uniqueEmailUser{}, "idx_users_email"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().DropIndex(&tenantProduct{}, "idx_products_tenant_id"); err != nil {
+				return err
+			}
+
+			if tx.Dialector.Name() == "sqlite" {
+				// see users_disabled.
+				if err := tx.Exec("ALTER TABLE products DROP COLUMN tenant_id").Error; err != nil {
+					return err
+				}
+				if err := tx.Exec("ALTER TABLE users DROP COLUMN tenant_id").Error; err != nil {
+					return err
+				}
+			} else {
+				if err := tx.Migrator().DropColumn(&tenantProduct{}, "TenantID"); err != nil {
+					return err
+				}
+				if err := tx.Migrator().DropColumn(&tenantUser{}, "TenantID"); err != nil {
+					return err
+				}
+			}
+			return tx.Migrator().DropTable(&tenant{})
+		},
+	})
+}
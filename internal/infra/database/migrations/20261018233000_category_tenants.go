@@ -0,0 +1,44 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// Categories belong to a tenant too. Those from before are moved into the
+// default tenant, like the users and products were by tenants.
+
+type tenantCategory struct {
+	TenantID string `gorm:"type:varchar(36);index:idx_categories_tenant_id"`
+}
+
+func (tenantCategory) TableName() string {
+	return "categories"
+}
+
+func init() {
+	Register(&Migration{
+		Version: "20261018233000",
+		Name:    "category_tenants",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&tenantCategory{}, "TenantID"); err != nil {
+				return err
+			}
+			var defaultTenant tenant
+			if err := tx.First(&defaultTenant, "slug = ?", "default").Error; err != nil {
+				return err
+			}
+			if err := tx.Table("categories").Where("1 = 1").Update("tenant_id", defaultTenant.ID).Error; err != nil {
+				return err
+			}
+			return tx.Migrator().CreateIndex(&tenantCategory{}, "idx_categories_tenant_id")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropIndex(&tenantCategory{}, "idx_categories_tenant_id"); err != nil {
+				return err
+			}
+			if tx.Dialector.Name() == "sqlite" {
+				// see users_disabled.
+				return tx.Exec("ALTER TABLE categories DROP COLUMN tenant_id").Error
+			}
+			return tx.Migrator().DropColumn(&tenantCategory{}, "TenantID")
+		},
+	})
+}
@@ -0,0 +1,61 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const VersionLayout = "20060102150405"
+
+var ErrInvalidName = errors.New("invalid migration name")
+
+var nonAlphanumeric = regexp.MustCompile(`[^a-z0-9]+`)
+
+var migrationTemplate = template.Must(template.New("migration").Parse(`package migrations
+
+import "gorm.io/gorm"
+
+func init() {
+	Register(&Migration{
+		Version: "{{.Version}}",
+		Name:    "{{.Name}}",
+		Up: func(tx *gorm.DB) error {
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			return nil
+		},
+	})
+}
+`))
+
+// Create writes an empty migration file named <version>_<name>.go into dir
+// and returns its path.
+func Create(dir, name string, now time.Time) (string, error) {
+	name = strings.Trim(nonAlphanumeric.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", ErrInvalidName
+	}
+
+	version := now.UTC().Format(VersionLayout)
+	path := filepath.Join(dir, fmt.Sprintf("%s_%s.go", version, name))
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	err = migrationTemplate.Execute(file, struct{ Version, Name string }{version, name})
+	if err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
@@ -0,0 +1,198 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDuplicateVersion = errors.New("duplicate migration version")
+	ErrUnknownMigration = errors.New("applied migration is not registered")
+	ErrMissingDown      = errors.New("migration has no down step")
+)
+
+// Migration is a single versioned schema change. Versions are timestamps
+// (YYYYMMDDHHMMSS) and are applied in ascending order.
+type Migration struct {
+	Version string
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaMigration is a row of the schema_migrations table, one per applied
+// migration.
+type SchemaMigration struct {
+	Version   string    `gorm:"type:varchar(14);primaryKey"`
+	Name      string    `gorm:"type:varchar(255)"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+type Status struct {
+	Version   string     `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+var registered []*Migration
+
+// Register adds a migration to the set returned by All. It is meant to be
+// called from the init function of each migration file.
+func Register(m *Migration) {
+	registered = append(registered, m)
+}
+
+// All returns every registered migration sorted by version.
+func All() []*Migration {
+	all := make([]*Migration, len(registered))
+	copy(all, registered)
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+	return all
+}
+
+type Migrator struct {
+	DB         *gorm.DB
+	Migrations []*Migration
+}
+
+func NewMigrator(db *gorm.DB) *Migrator {
+	return &Migrator{DB: db, Migrations: All()}
+}
+
+// Up applies every pending migration in order and returns the ones applied.
+func (m *Migrator) Up() ([]*Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []*Migration
+	for _, migration := range m.Migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		err := m.DB.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %s_%s: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// Down rolls back the last steps applied migrations, newest first, and
+// returns the ones rolled back.
+func (m *Migrator) Down(steps int) ([]*Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]string, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(versions)))
+
+	if steps < len(versions) {
+		versions = versions[:steps]
+	}
+
+	var done []*Migration
+	for _, version := range versions {
+		migration := m.find(version)
+		if migration == nil {
+			return done, fmt.Errorf("%w: %s", ErrUnknownMigration, version)
+		}
+		if migration.Down == nil {
+			return done, fmt.Errorf("%w: %s_%s", ErrMissingDown, migration.Version, migration.Name)
+		}
+
+		err := m.DB.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, "version = ?", migration.Version).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %s_%s: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// Status lists every known migration together with the time it was applied,
+// or nil when it is still pending.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.Migrations))
+	for _, migration := range m.Migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if row, ok := applied[migration.Version]; ok {
+			appliedAt := row.AppliedAt
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+func (m *Migrator) applied() (map[string]SchemaMigration, error) {
+	seen := make(map[string]bool, len(m.Migrations))
+	for _, migration := range m.Migrations {
+		if seen[migration.Version] {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateVersion, migration.Version)
+		}
+		seen[migration.Version] = true
+	}
+
+	if err := m.DB.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, err
+	}
+
+	var rows []SchemaMigration
+	if err := m.DB.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	applied := make(map[string]SchemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+func (m *Migrator) find(version string) *Migration {
+	for _, migration := range m.Migrations {
+		if migration.Version == version {
+			return migration
+		}
+	}
+	return nil
+}
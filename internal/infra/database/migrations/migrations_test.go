@@ -0,0 +1,296 @@
+package migrations
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestUpAppliesAllMigrations(t *testing.T) {
+	db := openTestDB(t)
+	migrator := NewMigrator(db)
+
+	applied, err := migrator.Up()
+	assert.NoError(t, err)
+	assert.Len(t, applied, len(All()))
+	assert.True(t, db.Migrator().HasTable("products"))
+	assert.True(t, db.Migrator().HasTable("users"))
+
+	applied, err = migrator.Up()
+	assert.NoError(t, err)
+	assert.Empty(t, applied)
+
+	statuses, err := migrator.Status()
+	assert.NoError(t, err)
+	for _, s := range statuses {
+		assert.NotNil(t, s.AppliedAt, s.Version)
+	}
+}
+
+// createLegacySchema recreates the tables exactly as db.AutoMigrate created
+// them before migrations existed.
+func createLegacySchema(t *testing.T, db *gorm.DB) {
+	assert.NoError(t, db.Exec("CREATE TABLE `products` (`id` text,`name` text,`price` real,`created_at` datetime,PRIMARY KEY (`id`))").Error)
+	assert.NoError(t, db.Exec("CREATE TABLE `users` (`id` text,`name` text,`email` text,`password` text,PRIMARY KEY (`id`))").Error)
+}
+
+func TestDownRevertsAllMigrations(t *testing.T) {
+	db := openTestDB(t)
+	migrator := NewMigrator(db)
+
+	_, err := migrator.Up()
+	assert.NoError(t, err)
+
+	reverted, err := migrator.Down(len(migrator.Migrations))
+	assert.NoError(t, err)
+	assert.Len(t, reverted, len(migrator.Migrations))
+	assert.False(t, db.Migrator().HasTable("products"))
+	assert.False(t, db.Migrator().HasTable("users"))
+
+	_, err = migrator.Up()
+	assert.NoError(t, err)
+}
+
+func TestBaselineUpgradesExistingDatabase(t *testing.T) {
+	db := openTestDB(t)
+	createLegacySchema(t, db)
+
+	id := entityPkg.NewID()
+	assert.NoError(t, db.Exec("INSERT INTO products (id, name, price, created_at) VALUES (?, ?, ?, ?)", id.String(), "Product 1", 0.1+0.2, time.Now()).Error)
+
+	_, err := NewMigrator(db).Up()
+	assert.NoError(t, err)
+
+	var product entity.Product
+	assert.NoError(t, db.First(&product, "id = ?", id.String()).Error)
+	assert.Equal(t, "Product 1", product.Name)
+	assert.Equal(t, entityPkg.Money{Amount: 30, Currency: entityPkg.DefaultCurrency}, product.Price)
+}
+
+func TestProductPriceMoneyRollsBack(t *testing.T) {
+	db := openTestDB(t)
+	migrator := migratorUpTo(db, "product_price_money")
+	_, err := migrator.Up()
+	assert.NoError(t, err)
+
+	product, _ := entity.NewProduct("Product 1", entityPkg.MustParseMoney("15.50", "USD"))
+	// the columns added since are not there yet.
+	assert.NoError(t, db.Omit("Categories", "CreatedBy", "UpdatedBy", "TenantID").Create(product).Error)
+
+	_, err = migrator.Down(1)
+	assert.NoError(t, err)
+	assert.False(t, db.Migrator().HasColumn("products", "price_amount"))
+
+	var price float64
+	assert.NoError(t, db.Table("products").Select("price").Where("id = ?", product.ID.String()).Scan(&price).Error)
+	assert.Equal(t, 15.5, price)
+}
+
+// migratorUpTo returns a migrator for the migrations up to and including
+// the one called name.
+func migratorUpTo(db *gorm.DB, name string) *Migrator {
+	migrator := NewMigrator(db)
+	for migrator.Migrations[len(migrator.Migrations)-1].Name != name {
+		migrator.Migrations = migrator.Migrations[:len(migrator.Migrations)-1]
+	}
+	return migrator
+}
+
+func TestUsersUniqueEmailNormalizesExistingEmails(t *testing.T) {
+	db := openTestDB(t)
+	migrator := migratorUpTo(db, "users_unique_email")
+	migrator.Migrations = migrator.Migrations[:len(migrator.Migrations)-1]
+	_, err := migrator.Up()
+	assert.NoError(t, err)
+
+	assert.NoError(t, db.Exec("INSERT INTO users (id, name, email, password) VALUES (?, ?, ?, ?)", entityPkg.NewID().String(), "John", " John@Example.com", "x").Error)
+
+	_, err = migratorUpTo(db, "users_unique_email").Up()
+	assert.NoError(t, err)
+
+	var email string
+	assert.NoError(t, db.Table("users").Select("email").Scan(&email).Error)
+	assert.Equal(t, "john@example.com", email)
+	assert.Error(t, db.Exec("INSERT INTO users (id, name, email, password) VALUES (?, ?, ?, ?)", entityPkg.NewID().String(), "Johnny", "john@example.com", "x").Error)
+}
+
+func TestUsersUniqueEmailRefusesDuplicates(t *testing.T) {
+	db := openTestDB(t)
+	migrator := migratorUpTo(db, "users_unique_email")
+	migrator.Migrations = migrator.Migrations[:len(migrator.Migrations)-1]
+	_, err := migrator.Up()
+	assert.NoError(t, err)
+
+	for _, email := range []string{"john@example.com", "JOHN@example.com"} {
+		assert.NoError(t, db.Exec("INSERT INTO users (id, name, email, password) VALUES (?, ?, ?, ?)", entityPkg.NewID().String(), "John", email, "x").Error)
+	}
+
+	_, err = migratorUpTo(db, "users_unique_email").Up()
+	assert.ErrorContains(t, err, "john@example.com")
+}
+
+func TestDownRollsBackInReverseOrder(t *testing.T) {
+	db := openTestDB(t)
+	var calls []string
+	migrator := &Migrator{DB: db, Migrations: []*Migration{
+		{
+			Version: "20250101000000",
+			Name:    "first",
+			Up:      func(tx *gorm.DB) error { calls = append(calls, "up first"); return nil },
+			Down:    func(tx *gorm.DB) error { calls = append(calls, "down first"); return nil },
+		},
+		{
+			Version: "20250102000000",
+			Name:    "second",
+			Up:      func(tx *gorm.DB) error { calls = append(calls, "up second"); return nil },
+			Down:    func(tx *gorm.DB) error { calls = append(calls, "down second"); return nil },
+		},
+	}}
+
+	_, err := migrator.Up()
+	assert.NoError(t, err)
+
+	reverted, err := migrator.Down(1)
+	assert.NoError(t, err)
+	assert.Len(t, reverted, 1)
+	assert.Equal(t, "second", reverted[0].Name)
+
+	statuses, err := migrator.Status()
+	assert.NoError(t, err)
+	assert.NotNil(t, statuses[0].AppliedAt)
+	assert.Nil(t, statuses[1].AppliedAt)
+
+	reverted, err = migrator.Down(10)
+	assert.NoError(t, err)
+	assert.Len(t, reverted, 1)
+
+	assert.Equal(t, []string{"up first", "up second", "down second", "down first"}, calls)
+}
+
+func TestFailedMigrationIsNotRecorded(t *testing.T) {
+	db := openTestDB(t)
+	errBoom := errors.New("boom")
+	migrator := &Migrator{DB: db, Migrations: []*Migration{
+		{
+			Version: "20250101000000",
+			Name:    "broken",
+			Up: func(tx *gorm.DB) error {
+				if err := tx.Exec("CREATE TABLE broken (id integer)").Error; err != nil {
+					return err
+				}
+				return errBoom
+			},
+		},
+	}}
+
+	_, err := migrator.Up()
+	assert.ErrorIs(t, err, errBoom)
+	assert.False(t, db.Migrator().HasTable("broken"))
+
+	statuses, err := migrator.Status()
+	assert.NoError(t, err)
+	assert.Nil(t, statuses[0].AppliedAt)
+}
+
+func TestDuplicateVersion(t *testing.T) {
+	migrator := &Migrator{DB: openTestDB(t), Migrations: []*Migration{
+		{Version: "20250101000000", Name: "a"},
+		{Version: "20250101000000", Name: "b"},
+	}}
+
+	_, err := migrator.Up()
+	assert.ErrorIs(t, err, ErrDuplicateVersion)
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2025, 7, 5, 12, 30, 0, 0, time.UTC)
+
+	path, err := Create(dir, "Add Product SKU", now)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "20250705123000_add_product_sku.go"), path)
+
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `Version: "20250705123000"`)
+	assert.Contains(t, string(content), `Name:    "add_product_sku"`)
+
+	_, err = Create(dir, "Add Product SKU", now)
+	assert.Error(t, err)
+
+	_, err = Create(dir, "  --  ", now)
+	assert.ErrorIs(t, err, ErrInvalidName)
+}
+
+func TestUsersEmailVerifiedTakesExistingUsersAsVerified(t *testing.T) {
+	db := openTestDB(t)
+	migrator := migratorUpTo(db, "users_email_verified")
+	migrator.Migrations = migrator.Migrations[:len(migrator.Migrations)-1]
+	_, err := migrator.Up()
+	assert.NoError(t, err)
+
+	assert.NoError(t, db.Exec("INSERT INTO users (id, name, email, password) VALUES (?, ?, ?, ?)", entityPkg.NewID().String(), "John", "john@example.com", "x").Error)
+
+	_, err = migratorUpTo(db, "users_email_verified").Up()
+	assert.NoError(t, err)
+
+	var user entity.User
+	assert.NoError(t, db.First(&user).Error)
+	assert.True(t, user.IsVerified())
+}
+
+func TestTenantsMovesExistingRowsIntoTheDefaultTenant(t *testing.T) {
+	db := openTestDB(t)
+	migrator := migratorUpTo(db, "tenants")
+	migrator.Migrations = migrator.Migrations[:len(migrator.Migrations)-1]
+	_, err := migrator.Up()
+	assert.NoError(t, err)
+
+	assert.NoError(t, db.Exec("INSERT INTO users (id, name, email, password) VALUES (?, ?, ?, ?)", entityPkg.NewID().String(), "John", "john@example.com", "x").Error)
+
+	_, err = migratorUpTo(db, "tenants").Up()
+	assert.NoError(t, err)
+
+	var defaultTenant entity.Tenant
+	assert.NoError(t, db.First(&defaultTenant, "slug = ?", entity.DefaultTenantSlug).Error)
+	var tenantID string
+	assert.NoError(t, db.Table("users").Select("tenant_id").Scan(&tenantID).Error)
+	assert.Equal(t, defaultTenant.ID.String(), tenantID)
+
+	// the same email is fine in another tenant, not twice in one.
+	insert := "INSERT INTO users (id, name, email, password, tenant_id) VALUES (?, ?, ?, ?, ?)"
+	assert.NoError(t, db.Exec(insert, entityPkg.NewID().String(), "John", "john@example.com", "x", entityPkg.NewID().String()).Error)
+	assert.Error(t, db.Exec(insert, entityPkg.NewID().String(), "Johnny", "john@example.com", "x", tenantID).Error)
+}
+
+func TestCategoryTenantsMovesExistingCategoriesIntoTheDefaultTenant(t *testing.T) {
+	db := openTestDB(t)
+	_, err := migratorUpTo(db, "tenants").Up()
+	assert.NoError(t, err)
+	assert.NoError(t, db.Exec("INSERT INTO categories (id, name) VALUES (?, ?)", entityPkg.NewID().String(), "Shirts").Error)
+
+	_, err = migratorUpTo(db, "category_tenants").Up()
+	assert.NoError(t, err)
+
+	var defaultTenant entity.Tenant
+	assert.NoError(t, db.First(&defaultTenant, "slug = ?", entity.DefaultTenantSlug).Error)
+	var tenantID string
+	assert.NoError(t, db.Table("categories").Select("tenant_id").Scan(&tenantID).Error)
+	assert.Equal(t, defaultTenant.ID.String(), tenantID)
+}
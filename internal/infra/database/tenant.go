@@ -0,0 +1,151 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	ErrNoTenant       = errors.New("no tenant in context")
+	ErrTenantMismatch = errors.New("row belongs to another tenant")
+)
+
+// tenantField is the field that makes a model belong to a tenant.
+const tenantField = "TenantID"
+
+// tenantScope is what the tenant scoped repositories see in a context:
+// either the rows of one tenant or, with all set, those of every tenant.
+type tenantScope struct {
+	tenantID entityPkg.ID
+	all      bool
+}
+
+type tenantKey struct{}
+
+// WithTenant returns a context in which the tenant scoped repositories only
+// see and write the rows of tenantID.
+func WithTenant(ctx context.Context, tenantID entityPkg.ID) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantScope{tenantID: tenantID})
+}
+
+// TenantFromContext returns the tenant ctx is scoped to.
+func TenantFromContext(ctx context.Context) (entityPkg.ID, bool) {
+	scope, ok := ctx.Value(tenantKey{}).(tenantScope)
+	return scope.tenantID, ok && !scope.all
+}
+
+// AllTenants returns a context in which the tenant scoped repositories see
+// the rows of every tenant, whatever tenant ctx was scoped to. It is meant
+// for looking users up by credentials that identify them across tenants,
+// like refresh tokens and API keys, and for maintenance; everything else
+// must name a tenant with WithTenant.
+func AllTenants(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantScope{all: true})
+}
+
+func isAllTenants(ctx context.Context) bool {
+	scope, _ := ctx.Value(tenantKey{}).(tenantScope)
+	return scope.all
+}
+
+// scopeByTenant registers the callbacks that scope every statement on a
+// model with a TenantID to the tenant of its context: reads, updates and
+// deletes only match rows of that tenant, and created rows are put in it.
+// A statement whose context names no tenant fails with ErrNoTenant rather
+// than running unscoped, so a repository forgetting a filter cannot leak
+// rows. Registering again on the same db does nothing.
+func scopeByTenant(db *gorm.DB) {
+	if db.Callback().Query().Get("tenant:query") != nil {
+		return
+	}
+
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Create().Before("gorm:create").Register("tenant:create", assignTenant),
+		callbacks.Query().Before("gorm:query").Register("tenant:query", filterTenant),
+		callbacks.Row().Before("gorm:row").Register("tenant:row", filterTenant),
+		callbacks.Update().Before("gorm:update").Register("tenant:update", func(db *gorm.DB) {
+			assignTenant(db)
+			filterTenant(db)
+		}),
+		callbacks.Delete().Before("gorm:delete").Register("tenant:delete", filterTenant),
+	} {
+		if err != nil {
+			panic("failed to register the tenant callbacks: " + err.Error())
+		}
+	}
+}
+
+// tenantFieldOf is the TenantID field of the model of the statement, nil for
+// models that do not belong to a tenant.
+func tenantFieldOf(db *gorm.DB) *schema.Field {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return nil
+	}
+	return db.Statement.Schema.LookUpField(tenantField)
+}
+
+// filterTenant narrows the statement down to the rows of the tenant.
+func filterTenant(db *gorm.DB) {
+	field := tenantFieldOf(db)
+	if field == nil {
+		return
+	}
+
+	ctx := db.Statement.Context
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		if !isAllTenants(ctx) {
+			db.AddError(ErrNoTenant)
+		}
+		return
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: tenantID},
+	}})
+}
+
+// assignTenant puts the rows written into the tenant, refusing rows that
+// already belong to another one.
+func assignTenant(db *gorm.DB) {
+	field := tenantFieldOf(db)
+	if field == nil {
+		return
+	}
+
+	ctx := db.Statement.Context
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok && !isAllTenants(ctx) {
+		db.AddError(ErrNoTenant)
+		return
+	}
+
+	set := func(row reflect.Value) {
+		value, zero := field.ValueOf(ctx, row)
+		switch {
+		case zero && !ok:
+			// across tenants rows must say which one they belong to.
+			db.AddError(ErrNoTenant)
+		case zero:
+			db.AddError(field.Set(ctx, row, tenantID))
+		case ok && value != tenantID:
+			db.AddError(ErrTenantMismatch)
+		}
+	}
+
+	rows := db.Statement.ReflectValue
+	switch rows.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rows.Len(); i++ {
+			set(reflect.Indirect(rows.Index(i)))
+		}
+	case reflect.Struct:
+		set(rows)
+	}
+}
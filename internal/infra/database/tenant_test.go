@@ -0,0 +1,284 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// testCtx is the tenant the repository tests act in.
+var testCtx = WithTenant(context.Background(), entityPkg.NewID())
+
+// tenantIsolation holds two tenants, each with a user, a category and a
+// product of its own, over the migrated schema.
+type tenantIsolation struct {
+	db         *gorm.DB
+	users      *GormUserRepository
+	products   *GormProductRepository
+	categories *GormCategoryRepository
+	a, b       context.Context
+	userA      *entity.User
+	category   *entity.Category
+	product    *entity.Product
+}
+
+func newTenantIsolation(t *testing.T) *tenantIsolation {
+	db := openSearchTestDB(t)
+	s := &tenantIsolation{
+		db:         db,
+		users:      NewUser(db),
+		products:   NewProduct(db),
+		categories: NewCategory(db),
+		a:          WithTenant(context.Background(), entityPkg.NewID()),
+		b:          WithTenant(context.Background(), entityPkg.NewID()),
+	}
+
+	s.userA, _ = entity.NewUser("Ana", "ana@example.com", "123456")
+	s.userA.Role = entity.RoleAdmin
+	assert.NoError(t, s.users.Create(s.a, s.userA))
+	userB, _ := entity.NewUser("Bruno", "bruno@example.com", "123456")
+	userB.Role = entity.RoleAdmin
+	assert.NoError(t, s.users.Create(s.b, userB))
+
+	s.category, _ = entity.NewCategory("Shirts", nil)
+	assert.NoError(t, s.categories.Create(s.a, s.category))
+	s.product, _ = entity.NewProduct("Blue Shirt", entityPkg.MustParseMoney("10.00", "USD"))
+	s.product.Categories = []*entity.Category{s.category}
+	assert.NoError(t, s.products.Create(s.a, s.product))
+	categoryB, _ := entity.NewCategory("Hats", nil)
+	assert.NoError(t, s.categories.Create(s.b, categoryB))
+	productB, _ := entity.NewProduct("Red Shirt", entityPkg.MustParseMoney("12.00", "USD"))
+	productB.Categories = []*entity.Category{categoryB}
+	assert.NoError(t, s.products.Create(s.b, productB))
+	return s
+}
+
+func TestTenantsDoNotSeeEachOthersUsers(t *testing.T) {
+	s := newTenantIsolation(t)
+
+	_, err := s.users.FindByID(s.b, s.userA.ID.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = s.users.FindByEmail(s.b, "ana@example.com")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	users, err := s.users.FindAll(s.b, 0, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, users, 1) {
+		assert.Equal(t, "bruno@example.com", users[0].Email)
+	}
+
+	admins, err := s.users.CountByRole(s.b, entity.RoleAdmin)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), admins)
+
+	found, err := s.users.FindByID(s.a, s.userA.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, s.userA.TenantID, found.TenantID)
+}
+
+func TestTenantsCannotChangeEachOthersUsers(t *testing.T) {
+	s := newTenantIsolation(t)
+
+	changed := *s.userA
+	changed.Name = "Mallory"
+	changed.TenantID = entityPkg.ID{}
+	assert.ErrorIs(t, s.users.Update(s.b, &changed), gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, s.users.Delete(s.b, s.userA.ID.String()), gorm.ErrRecordNotFound)
+
+	found, err := s.users.FindByID(s.a, s.userA.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, "Ana", found.Name)
+}
+
+func TestEmailsAreUniquePerTenant(t *testing.T) {
+	s := newTenantIsolation(t)
+
+	sameEmail, _ := entity.NewUser("Other Ana", "ANA@example.com", "654321")
+	assert.NoError(t, s.users.Create(s.b, sameEmail))
+
+	again, _ := entity.NewUser("Ana Again", "ana@example.com", "654321")
+	assert.Equal(t, entity.ErrEmailTaken, s.users.Create(s.a, again))
+
+	found, err := s.users.FindByEmail(s.b, "ana@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, sameEmail.ID, found.ID)
+}
+
+func TestTenantsDoNotSeeEachOthersProducts(t *testing.T) {
+	s := newTenantIsolation(t)
+
+	_, err := s.products.FindByID(s.b, s.product.ID.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	products, err := s.products.FindAll(s.b, 0, 10, "asc")
+	assert.NoError(t, err)
+	if assert.Len(t, products, 1) {
+		assert.Equal(t, "Red Shirt", products[0].Name)
+	}
+
+	products, err = s.products.FindAllByCategories(s.b, []string{s.product.Categories[0].ID.String()}, 0, 10, "asc")
+	assert.NoError(t, err)
+	assert.Empty(t, products)
+
+	products, err = s.products.FindAllMatching(s.b, ProductFilter{IDs: []string{s.product.ID.String()}}, 0, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, products)
+
+	page, err := s.products.FindPage(s.b, ProductFilter{}, nil, 10, "asc")
+	assert.NoError(t, err)
+	assert.Len(t, page.Products, 1)
+
+	results, err := s.products.Search(s.b, "shirt", 0, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "Red Shirt", results[0].Product.Name)
+	}
+}
+
+func TestTenantsCannotChangeEachOthersProducts(t *testing.T) {
+	s := newTenantIsolation(t)
+
+	changed := *s.product
+	changed.Name = "Stolen Shirt"
+	changed.TenantID = entityPkg.ID{}
+	assert.ErrorIs(t, s.products.Update(s.b, &changed), gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, s.products.Delete(s.b, s.product.ID.String()), gorm.ErrRecordNotFound)
+
+	found, err := s.products.FindByID(s.a, s.product.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, "Blue Shirt", found.Name)
+	assert.Len(t, found.Categories, 1)
+	results, err := s.products.Search(s.a, "blue", 0, 10)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+}
+
+func TestTenantsDoNotSeeEachOthersCategories(t *testing.T) {
+	s := newTenantIsolation(t)
+	id := s.category.ID.String()
+
+	_, err := s.categories.FindByID(s.b, id)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = s.categories.FindByIDs(s.b, []string{id})
+	assert.ErrorIs(t, err, entity.ErrCategoryNotFound)
+
+	categories, err := s.categories.FindAll(s.b)
+	assert.NoError(t, err)
+	if assert.Len(t, categories, 1) {
+		assert.Equal(t, "Hats", categories[0].Name)
+	}
+
+	descendants, err := s.categories.FindDescendantIDs(s.b, id)
+	assert.NoError(t, err)
+	assert.Empty(t, descendants)
+
+	// nor can they nest their categories under it.
+	child, _ := entity.NewCategory("Polo Shirts", &s.category.ID)
+	assert.ErrorIs(t, s.categories.Create(s.b, child), entity.ErrInvalidParent)
+	assert.NoError(t, s.categories.Create(s.a, child))
+	ancestors, err := s.categories.FindAncestors(s.b, child.ID.String())
+	assert.NoError(t, err)
+	assert.Empty(t, ancestors)
+}
+
+func TestTenantsCannotChangeEachOthersCategories(t *testing.T) {
+	s := newTenantIsolation(t)
+	id := s.category.ID.String()
+
+	changed := *s.category
+	changed.Name = "Stolen Shirts"
+	changed.TenantID = entityPkg.ID{}
+	assert.ErrorIs(t, s.categories.Update(s.b, &changed), gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, s.categories.Delete(s.b, id), gorm.ErrRecordNotFound)
+
+	// a product cannot be linked to a category of another tenant either.
+	product, _ := entity.NewProduct("Green Shirt", entityPkg.MustParseMoney("10.00", "USD"))
+	product.Categories = []*entity.Category{s.category}
+	assert.ErrorIs(t, s.products.Create(s.b, product), ErrTenantMismatch)
+
+	found, err := s.categories.FindByID(s.a, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "Shirts", found.Name)
+	products, err := s.products.FindAllByCategories(s.a, []string{id}, 0, 10, "asc")
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+}
+
+func TestRowsOfAnotherTenantCannotBeWritten(t *testing.T) {
+	s := newTenantIsolation(t)
+
+	product, _ := entity.NewProduct("Green Shirt", entityPkg.MustParseMoney("10.00", "USD"))
+	product.TenantID = s.userA.TenantID
+	assert.ErrorIs(t, s.products.Create(s.b, product), ErrTenantMismatch)
+
+	moved := *s.userA
+	moved.TenantID = entityPkg.NewID()
+	assert.ErrorIs(t, s.users.Update(s.a, &moved), ErrTenantMismatch)
+}
+
+func TestStatementsWithoutTenantFail(t *testing.T) {
+	s := newTenantIsolation(t)
+	ctx := context.Background()
+	id := s.product.ID.String()
+
+	user, _ := entity.NewUser("Nobody", "nobody@example.com", "123456")
+	product, _ := entity.NewProduct("Hat", entityPkg.MustParseMoney("10.00", "USD"))
+	for name, err := range map[string]error{
+		"create user":     s.users.Create(ctx, user),
+		"update user":     s.users.Update(ctx, s.userA),
+		"delete user":     s.users.Delete(ctx, s.userA.ID.String()),
+		"create product":  s.products.Create(ctx, product),
+		"update product":  s.products.Update(ctx, s.product),
+		"delete product":  s.products.Delete(ctx, id),
+		"find product":    errOf(s.products.FindByID(ctx, id)),
+		"list products":   errOf(s.products.FindAll(ctx, 0, 10, "asc")),
+		"search":          errOf(s.products.Search(ctx, "shirt", 0, 10)),
+		"find user":       errOf(s.users.FindByEmail(ctx, "ana@example.com")),
+		"list users":      errOf(s.users.FindAll(ctx, 0, 10)),
+		"count users":     errOf(s.users.CountByRole(ctx, entity.RoleAdmin)),
+		"find category":   errOf(s.categories.FindByID(ctx, s.category.ID.String())),
+		"ancestors":       errOf(s.categories.FindAncestors(ctx, s.category.ID.String())),
+		"descendants":     errOf(s.categories.FindDescendantIDs(ctx, s.category.ID.String())),
+		"delete category": s.categories.Delete(ctx, s.category.ID.String()),
+	} {
+		assert.ErrorIs(t, err, ErrNoTenant, name)
+	}
+
+	// even a query forgetting about tenants cannot read across them.
+	var products []*entity.Product
+	assert.ErrorIs(t, s.db.Find(&products).Error, ErrNoTenant)
+	var count int64
+	assert.ErrorIs(t, s.db.Model(&entity.User{}).Count(&count).Error, ErrNoTenant)
+}
+
+func TestAllTenantsSeesEveryTenant(t *testing.T) {
+	s := newTenantIsolation(t)
+	all := AllTenants(s.b)
+
+	found, err := s.users.FindByID(all, s.userA.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, "Ana", found.Name)
+
+	users, err := s.users.FindAll(all, 0, 10)
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+
+	// creating across tenants needs rows that say which tenant they are in.
+	user, _ := entity.NewUser("Nobody", "nobody@example.com", "123456")
+	assert.ErrorIs(t, s.users.Create(all, user), ErrNoTenant)
+
+	// scoping to a tenant again takes over.
+	_, err = s.users.FindByID(WithTenant(all, found.TenantID), s.userA.ID.String())
+	assert.NoError(t, err)
+	_, err = s.users.FindByID(s.b, s.userA.ID.String())
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+}
+
+func errOf[T any](_ T, err error) error {
+	return err
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// timeoutKey is where a statement keeps its deadline between the callbacks.
+const timeoutKey = "timeouts:deadline"
+
+// Timeouts bound how long a single statement may run: queries get Read and
+// inserts, updates, deletes and Exec get Write. A zero duration leaves the
+// statement to the deadline of its context, if any. A statement that runs
+// out of time fails with context.DeadlineExceeded.
+type Timeouts struct {
+	Read  time.Duration
+	Write time.Duration
+}
+
+// deadline is the context a statement ran with before its timeout, and how
+// to release the timeout.
+type deadline struct {
+	parent context.Context
+	cancel context.CancelFunc
+}
+
+func (t Timeouts) Name() string {
+	return "timeouts"
+}
+
+func (t Timeouts) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("*").Register("timeouts:before_create", startTimeout(t.Write)),
+		callbacks.Create().After("*").Register("timeouts:after_create", stopTimeout),
+		callbacks.Query().Before("*").Register("timeouts:before_query", startTimeout(t.Read)),
+		callbacks.Query().After("*").Register("timeouts:after_query", stopTimeout),
+		callbacks.Update().Before("*").Register("timeouts:before_update", startTimeout(t.Write)),
+		callbacks.Update().After("*").Register("timeouts:after_update", stopTimeout),
+		callbacks.Delete().Before("*").Register("timeouts:before_delete", startTimeout(t.Write)),
+		callbacks.Delete().After("*").Register("timeouts:after_delete", stopTimeout),
+		callbacks.Raw().Before("*").Register("timeouts:before_raw", startTimeout(t.Write)),
+		callbacks.Raw().After("*").Register("timeouts:after_raw", stopTimeout),
+		callbacks.Row().Before("*").Register("timeouts:before_row", startTimeout(t.Read)),
+		callbacks.Row().After("*").Register("timeouts:after_row", leaveTimeout),
+	)
+}
+
+// startTimeout gives the statement timeout to run, unless it is zero.
+func startTimeout(timeout time.Duration) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if timeout <= 0 {
+			return
+		}
+		parent := db.Statement.Context
+		ctx, cancel := context.WithTimeout(parent, timeout)
+		db.Statement.Context = ctx
+		db.InstanceSet(timeoutKey, deadline{parent: parent, cancel: cancel})
+	}
+}
+
+// stopTimeout releases the timeout of the statement and puts its context
+// back, so that a query built once and run again gets a timeout of its own.
+func stopTimeout(db *gorm.DB) {
+	if value, ok := db.InstanceGet(timeoutKey); ok {
+		d := value.(deadline)
+		d.cancel()
+		db.Statement.Context = d.parent
+	}
+}
+
+// leaveTimeout puts the context of a row statement back, but leaves its
+// timeout running: the caller reads the rows after the callbacks, which
+// cancelling would cut short. The timeout is released once it expires.
+func leaveTimeout(db *gorm.DB) {
+	if value, ok := db.InstanceGet(timeoutKey); ok {
+		db.Statement.Context = value.(deadline).parent
+	}
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+// createCategoryCycle makes two categories each other's parent, which the
+// repository refuses, so that walking up from either never ends.
+func createCategoryCycle(t *testing.T, repo *GormCategoryRepository) *entity.Category {
+	parent, _ := entity.NewCategory("Parent", nil)
+	child, _ := entity.NewCategory("Child", &parent.ID)
+	assert.NoError(t, repo.Create(testCtx, parent))
+	assert.NoError(t, repo.Create(testCtx, child))
+	assert.NoError(t, repo.DB.WithContext(testCtx).Model(parent).Update("parent_id", child.ID).Error)
+	return child
+}
+
+// findAncestorsWithin walks up from category, failing the test unless the
+// query gives up within a few seconds.
+func findAncestorsWithin(t *testing.T, ctx context.Context, repo *GormCategoryRepository, category *entity.Category) error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := repo.FindAncestors(ctx, category.ID.String())
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("the query kept running")
+		return nil
+	}
+}
+
+func TestCancelledContextAbortsASlowQuery(t *testing.T) {
+	repo := NewCategory(openCategoryTestDB(t))
+	category := createCategoryCycle(t, repo)
+
+	ctx, cancel := context.WithCancel(testCtx)
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	err := findAncestorsWithin(t, ctx, repo, category)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestTimeoutsFailSlowStatements(t *testing.T) {
+	db := openCategoryTestDB(t)
+	if err := db.Use(Timeouts{Read: 50 * time.Millisecond, Write: time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	repo := NewCategory(db)
+	category := createCategoryCycle(t, repo)
+
+	err := findAncestorsWithin(t, testCtx, repo, category)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// a query run twice gets a fresh timeout each time.
+	query := db.WithContext(testCtx).Model(&entity.Category{})
+	var count int64
+	assert.NoError(t, query.Count(&count).Error)
+	time.Sleep(100 * time.Millisecond)
+	var categories []*entity.Category
+	assert.NoError(t, query.Find(&categories).Error)
+	assert.Len(t, categories, int(count))
+}
@@ -0,0 +1,102 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type linkData struct {
+	Name      string
+	Email     string
+	Link      string
+	ExpiresIn string
+}
+
+func TestRenderTemplates(t *testing.T) {
+	templates, err := NewTemplates()
+	assert.NoError(t, err)
+
+	for _, name := range []string{"verify_email", "password_reset"} {
+		msg, err := templates.Render(name, "ana@example.com", linkData{
+			Name:      "Ana <script>",
+			Email:     "ana@example.com",
+			Link:      "https://example.com/x?token=abc&next=1",
+			ExpiresIn: "1 hour",
+		})
+		assert.NoError(t, err, name)
+		assert.Equal(t, "ana@example.com", msg.To)
+		assert.NotEmpty(t, msg.Subject, name)
+		assert.Contains(t, msg.Text, "https://example.com/x?token=abc&next=1", name)
+		assert.Contains(t, msg.Text, "Ana <script>", name)
+		assert.Contains(t, msg.HTML, "https://example.com/x?token=abc&amp;next=1", name)
+		assert.Contains(t, msg.HTML, "Ana &lt;script&gt;", name)
+		assert.NotContains(t, msg.Text, "subject", name)
+	}
+
+	_, err = templates.Render("missing", "ana@example.com", nil)
+	assert.Error(t, err)
+}
+
+func TestWriterMailerWritesMultipartMessages(t *testing.T) {
+	var buf bytes.Buffer
+	mailer := NewWriter(&buf, "API <no-reply@example.com>")
+
+	err := mailer.Send(Message{To: "ana@example.com", Subject: "Olá", Text: "plain body", HTML: "<p>html body</p>"})
+	assert.NoError(t, err)
+
+	out := buf.String()
+	assert.Contains(t, out, "From: API <no-reply@example.com>\r\n")
+	assert.Contains(t, out, "To: ana@example.com\r\n")
+	assert.Contains(t, out, "Subject: =?utf-8?q?Ol=C3=A1?=\r\n")
+	assert.Contains(t, out, "Content-Type: multipart/alternative")
+	assert.Contains(t, out, "plain body")
+	assert.Contains(t, out, "<p>html body</p>")
+	assert.Less(t, strings.Index(out, "text/plain"), strings.Index(out, "text/html"))
+}
+
+// fakeSMTP answers a single SMTP session, accepting every command.
+func fakeSMTP(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 localhost ready")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			if strings.HasPrefix(line, "QUIT") {
+				text.PrintfLine("221 bye")
+				return
+			}
+			text.PrintfLine("250 ok")
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestSMTPMailerCheckGreetsTheServer(t *testing.T) {
+	mailer := &SMTPMailer{Addr: fakeSMTP(t), From: "no-reply@example.com"}
+	assert.NoError(t, mailer.Check(context.Background()))
+
+	// nothing listens there once the fake server is gone.
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	listener.Close()
+	mailer.Addr = listener.Addr().String()
+	assert.Error(t, mailer.Check(context.Background()))
+}
@@ -0,0 +1,15 @@
+// Package mail sends the emails the API needs, like password resets and
+// address verification, through a pluggable Mailer.
+package mail
+
+// Message is an email with a plain text body and an HTML alternative.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+type Mailer interface {
+	Send(msg Message) error
+}
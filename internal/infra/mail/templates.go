@@ -0,0 +1,86 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+// Templates renders messages from the pair of templates sharing a name:
+// templates/<name>.txt.tmpl for the text body and subject, which it defines
+// in a "subject" block, and templates/<name>.html.tmpl for the HTML body.
+type Templates struct {
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+func NewTemplates() (*Templates, error) {
+	t := &Templates{text: map[string]*texttemplate.Template{}, html: map[string]*htmltemplate.Template{}}
+
+	files, err := fs.Glob(templateFS, "templates/*.txt.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		name := strings.TrimSuffix(path.Base(file), ".txt.tmpl")
+
+		text, err := texttemplate.ParseFS(templateFS, file)
+		if err != nil {
+			return nil, err
+		}
+		if text.Lookup("subject") == nil {
+			return nil, templateError(file + " defines no subject")
+		}
+		html, err := htmltemplate.ParseFS(templateFS, "templates/"+name+".html.tmpl")
+		if err != nil {
+			return nil, err
+		}
+
+		t.text[name] = text
+		t.html[name] = html
+	}
+	return t, nil
+}
+
+// Render builds the message called name for to. data is escaped for the
+// HTML body.
+func (t *Templates) Render(name, to string, data interface{}) (Message, error) {
+	msg := Message{To: to}
+
+	text, ok := t.text[name]
+	if !ok {
+		return msg, templateError("no template " + name)
+	}
+
+	var buf bytes.Buffer
+	if err := text.ExecuteTemplate(&buf, "subject", data); err != nil {
+		return msg, err
+	}
+	msg.Subject = strings.TrimSpace(buf.String())
+
+	buf.Reset()
+	if err := text.Execute(&buf, data); err != nil {
+		return msg, err
+	}
+	msg.Text = strings.TrimSpace(buf.String()) + "\n"
+
+	buf.Reset()
+	if err := t.html[name].Execute(&buf, data); err != nil {
+		return msg, err
+	}
+	msg.HTML = buf.String()
+	return msg, nil
+}
+
+type templateError string
+
+func (e templateError) Error() string {
+	return "mail: " + string(e)
+}
@@ -0,0 +1,44 @@
+package mail
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// WriterMailer writes every message as a MIME email to W instead of sending
+// it, which is all local development and tests need.
+type WriterMailer struct {
+	W    io.Writer
+	From string
+	mu   sync.Mutex
+}
+
+func NewWriter(w io.Writer, from string) *WriterMailer {
+	return &WriterMailer{W: w, From: from}
+}
+
+// NewFile appends messages to the file at path, creating it if needed.
+func NewFile(path, from string) (*WriterMailer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriter(f, from), nil
+}
+
+func (m *WriterMailer) Send(msg Message) error {
+	body, err := encode(m.From, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.W.Write(body); err != nil {
+		return err
+	}
+	_, err = io.WriteString(m.W, "\r\n")
+	return err
+}
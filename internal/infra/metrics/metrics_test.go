@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/Mazzael/go-api/internal/infra/database"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// scrape returns what /metrics serves for m.
+func scrape(t *testing.T, m *Metrics) string {
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	body, _ := io.ReadAll(rec.Body)
+	return string(body)
+}
+
+func TestMiddlewareLabelsRequestsByRoutePattern(t *testing.T) {
+	m := New()
+	r := chi.NewRouter()
+	r.Use(m.Middleware)
+	r.Route("/products", func(r chi.Router) {
+		r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {})
+		r.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		})
+	})
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/products/1", nil),
+		httptest.NewRequest(http.MethodGet, "/products/2", nil),
+		httptest.NewRequest(http.MethodDelete, "/products/3", nil),
+		httptest.NewRequest(http.MethodGet, "/wp-login.php", nil),
+	} {
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `goapi_http_requests_total{method="GET",route="/products/{id}",status="200"} 2`)
+	assert.Contains(t, body, `goapi_http_requests_total{method="DELETE",route="/products/{id}",status="404"} 1`)
+	assert.Contains(t, body, `goapi_http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.Contains(t, body, `goapi_http_request_duration_seconds_count{method="GET",route="/products/{id}",status="200"} 2`)
+	assert.NotContains(t, body, "/products/1")
+}
+
+// failingUsers fails every call with err.
+type failingUsers struct {
+	database.UserRepository
+	err error
+}
+
+func (u *failingUsers) FindByID(ctx context.Context, id string) (*entity.User, error) {
+	return nil, u.err
+}
+
+func TestRepositoryDecoratorsTimeCallsAndCountErrors(t *testing.T) {
+	m := New()
+	ctx := context.Background()
+
+	_, err := m.Users(&failingUsers{err: gorm.ErrRecordNotFound}).FindByID(ctx, "1")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	broken := errors.New("connection reset")
+	_, err = m.Users(&failingUsers{err: broken}).FindByID(ctx, "1")
+	assert.Equal(t, broken, err)
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `goapi_repository_call_duration_seconds_count{method="FindByID",repository="users"} 2`)
+	assert.Contains(t, body, `goapi_repository_errors_total{method="FindByID",repository="users"} 1`)
+}
+
+func TestEventsAreCounted(t *testing.T) {
+	m := New()
+	m.Login("succeeded")
+	m.Login("failed")
+	m.Login("failed")
+	m.ProductCreated()
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `goapi_logins_total{outcome="failed"} 2`)
+	assert.Contains(t, body, `goapi_logins_total{outcome="succeeded"} 1`)
+	assert.Contains(t, body, `goapi_products_created_total 1`)
+}
+
+func TestRegisterDBExposesPoolStats(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(t.TempDir()+"/test.db"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := New()
+	assert.NoError(t, m.RegisterDB(db, "main"))
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `go_sql_max_open_connections{db_name="main"}`)
+	assert.Contains(t, body, `go_sql_wait_count_total{db_name="main"}`)
+}
@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/Mazzael/go-api/internal/infra/database"
+	"github.com/Mazzael/go-api/pkg/pagination"
+	"gorm.io/gorm"
+)
+
+// observe times a call to method of repository that started at start, and
+// counts it as failed when it returned an error other than a record that was
+// not found. It is deferred, with err pointing at the error the call returns.
+func (m *Metrics) observe(repository, method string, start time.Time, err *error) {
+	m.repositoryCalls.WithLabelValues(repository, method).Observe(time.Since(start).Seconds())
+	if *err != nil && !errors.Is(*err, gorm.ErrRecordNotFound) {
+		m.repositoryErrors.WithLabelValues(repository, method).Inc()
+	}
+}
+
+type userRepository struct {
+	next    database.UserRepository
+	metrics *Metrics
+}
+
+// Users decorates repo with metrics for each of its methods.
+func (m *Metrics) Users(repo database.UserRepository) database.UserRepository {
+	return &userRepository{next: repo, metrics: m}
+}
+
+func (r *userRepository) Create(ctx context.Context, user *entity.User) (err error) {
+	defer r.metrics.observe("users", "Create", time.Now(), &err)
+	return r.next.Create(ctx, user)
+}
+
+func (r *userRepository) FindByEmail(ctx context.Context, email string) (user *entity.User, err error) {
+	defer r.metrics.observe("users", "FindByEmail", time.Now(), &err)
+	return r.next.FindByEmail(ctx, email)
+}
+
+func (r *userRepository) FindByID(ctx context.Context, id string) (user *entity.User, err error) {
+	defer r.metrics.observe("users", "FindByID", time.Now(), &err)
+	return r.next.FindByID(ctx, id)
+}
+
+func (r *userRepository) FindAll(ctx context.Context, page, limit int) (users []*entity.User, err error) {
+	defer r.metrics.observe("users", "FindAll", time.Now(), &err)
+	return r.next.FindAll(ctx, page, limit)
+}
+
+func (r *userRepository) Update(ctx context.Context, user *entity.User) (err error) {
+	defer r.metrics.observe("users", "Update", time.Now(), &err)
+	return r.next.Update(ctx, user)
+}
+
+func (r *userRepository) Delete(ctx context.Context, id string) (err error) {
+	defer r.metrics.observe("users", "Delete", time.Now(), &err)
+	return r.next.Delete(ctx, id)
+}
+
+func (r *userRepository) CountByRole(ctx context.Context, role entity.Role) (count int64, err error) {
+	defer r.metrics.observe("users", "CountByRole", time.Now(), &err)
+	return r.next.CountByRole(ctx, role)
+}
+
+type productRepository struct {
+	next    database.ProductRepository
+	metrics *Metrics
+}
+
+// Products decorates repo with metrics for each of its methods.
+func (m *Metrics) Products(repo database.ProductRepository) database.ProductRepository {
+	return &productRepository{next: repo, metrics: m}
+}
+
+func (r *productRepository) Create(ctx context.Context, product *entity.Product) (err error) {
+	defer r.metrics.observe("products", "Create", time.Now(), &err)
+	return r.next.Create(ctx, product)
+}
+
+func (r *productRepository) FindAll(ctx context.Context, page, limit int, sort string) (products []*entity.Product, err error) {
+	defer r.metrics.observe("products", "FindAll", time.Now(), &err)
+	return r.next.FindAll(ctx, page, limit, sort)
+}
+
+func (r *productRepository) FindAllByCategories(ctx context.Context, categoryIDs []string, page, limit int, sort string) (products []*entity.Product, err error) {
+	defer r.metrics.observe("products", "FindAllByCategories", time.Now(), &err)
+	return r.next.FindAllByCategories(ctx, categoryIDs, page, limit, sort)
+}
+
+func (r *productRepository) FindAllMatching(ctx context.Context, filter database.ProductFilter, page, limit int) (products []*entity.Product, err error) {
+	defer r.metrics.observe("products", "FindAllMatching", time.Now(), &err)
+	return r.next.FindAllMatching(ctx, filter, page, limit)
+}
+
+func (r *productRepository) FindPage(ctx context.Context, filter database.ProductFilter, cursor *pagination.Cursor, limit int, sort string) (page *database.ProductPage, err error) {
+	defer r.metrics.observe("products", "FindPage", time.Now(), &err)
+	return r.next.FindPage(ctx, filter, cursor, limit, sort)
+}
+
+func (r *productRepository) Search(ctx context.Context, q string, page, limit int) (results []*database.ProductSearchResult, err error) {
+	defer r.metrics.observe("products", "Search", time.Now(), &err)
+	return r.next.Search(ctx, q, page, limit)
+}
+
+func (r *productRepository) FindByID(ctx context.Context, id string) (product *entity.Product, err error) {
+	defer r.metrics.observe("products", "FindByID", time.Now(), &err)
+	return r.next.FindByID(ctx, id)
+}
+
+func (r *productRepository) Update(ctx context.Context, product *entity.Product) (err error) {
+	defer r.metrics.observe("products", "Update", time.Now(), &err)
+	return r.next.Update(ctx, product)
+}
+
+func (r *productRepository) Delete(ctx context.Context, id string) (err error) {
+	defer r.metrics.observe("products", "Delete", time.Now(), &err)
+	return r.next.Delete(ctx, id)
+}
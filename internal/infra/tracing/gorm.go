@@ -0,0 +1,101 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// spanKey is where a statement keeps its span between the callbacks.
+const spanKey = "tracing:span"
+
+// gormPlugin starts a span for each statement gorm runs, see GormPlugin.
+type gormPlugin struct {
+	tracing *Tracing
+}
+
+// GormPlugin is the plugin to Use on a *gorm.DB for a span per SQL
+// statement, a child of the span in the context of the statement. The spans
+// carry the statement with its placeholders, never the values bound to them.
+func (t *Tracing) GormPlugin() gorm.Plugin {
+	return &gormPlugin{tracing: t}
+}
+
+func (p *gormPlugin) Name() string {
+	return "tracing"
+}
+
+func (p *gormPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("*").Register("tracing:before_create", p.before("create")),
+		callbacks.Create().After("*").Register("tracing:after_create", p.after("create")),
+		callbacks.Query().Before("*").Register("tracing:before_query", p.before("query")),
+		callbacks.Query().After("*").Register("tracing:after_query", p.after("query")),
+		callbacks.Update().Before("*").Register("tracing:before_update", p.before("update")),
+		callbacks.Update().After("*").Register("tracing:after_update", p.after("update")),
+		callbacks.Delete().Before("*").Register("tracing:before_delete", p.before("delete")),
+		callbacks.Delete().After("*").Register("tracing:after_delete", p.after("delete")),
+		callbacks.Row().Before("*").Register("tracing:before_row", p.before("row")),
+		callbacks.Row().After("*").Register("tracing:after_row", p.after("row")),
+		callbacks.Raw().Before("*").Register("tracing:before_raw", p.before("raw")),
+		callbacks.Raw().After("*").Register("tracing:after_raw", p.after("raw")),
+	)
+}
+
+// before starts the span of a statement of the operation, named after the
+// table by after once the statement knows it.
+func (p *gormPlugin) before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		// the statement keeps its context, as it may run again once this
+		// span has ended.
+		_, span := p.tracing.tracer.Start(db.Statement.Context, operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(systemOf(db.Dialector.Name()), semconv.DBOperationName(operation)),
+		)
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+// after ends the span of a statement of the operation with what was run and
+// its outcome.
+func (p *gormPlugin) after(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(spanKey)
+		if !ok {
+			return
+		}
+		span := value.(trace.Span)
+		defer span.End()
+
+		if table := db.Statement.Table; table != "" {
+			span.SetName(operation + " " + table)
+			span.SetAttributes(semconv.DBCollectionName(table))
+		}
+		span.SetAttributes(
+			semconv.DBQueryText(db.Statement.SQL.String()),
+			attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+		)
+		if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			span.RecordError(db.Error)
+			span.SetStatus(codes.Error, db.Error.Error())
+		}
+	}
+}
+
+// systemOf is the db.system attribute of the gorm dialector named name.
+func systemOf(name string) attribute.KeyValue {
+	switch name {
+	case "sqlite":
+		return semconv.DBSystemSqlite
+	case "postgres":
+		return semconv.DBSystemPostgreSQL
+	case "mysql":
+		return semconv.DBSystemMySQL
+	}
+	return semconv.DBSystemOtherSQL
+}
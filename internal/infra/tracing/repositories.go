@@ -0,0 +1,147 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/Mazzael/go-api/internal/infra/database"
+	"github.com/Mazzael/go-api/pkg/pagination"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// start starts the span of a call to method of repository, a child of the
+// span in ctx. The SQL statements the call runs with the returned context
+// are its children in turn.
+func (t *Tracing) start(ctx context.Context, repository, method string) (context.Context, trace.Span) {
+	return t.tracer.Start(ctx, repository+"."+method, trace.WithSpanKind(trace.SpanKindInternal))
+}
+
+// end ends span, failing it when the call returned an error other than a
+// record that was not found. It is deferred, with err pointing at the error
+// the call returns.
+func end(span trace.Span, err *error) {
+	if *err != nil && !errors.Is(*err, gorm.ErrRecordNotFound) {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}
+
+type userRepository struct {
+	next    database.UserRepository
+	tracing *Tracing
+}
+
+// Users decorates repo with a span for each call to its methods.
+func (t *Tracing) Users(repo database.UserRepository) database.UserRepository {
+	return &userRepository{next: repo, tracing: t}
+}
+
+func (r *userRepository) Create(ctx context.Context, user *entity.User) (err error) {
+	ctx, span := r.tracing.start(ctx, "users", "Create")
+	defer end(span, &err)
+	return r.next.Create(ctx, user)
+}
+
+func (r *userRepository) FindByEmail(ctx context.Context, email string) (user *entity.User, err error) {
+	ctx, span := r.tracing.start(ctx, "users", "FindByEmail")
+	defer end(span, &err)
+	return r.next.FindByEmail(ctx, email)
+}
+
+func (r *userRepository) FindByID(ctx context.Context, id string) (user *entity.User, err error) {
+	ctx, span := r.tracing.start(ctx, "users", "FindByID")
+	defer end(span, &err)
+	return r.next.FindByID(ctx, id)
+}
+
+func (r *userRepository) FindAll(ctx context.Context, page, limit int) (users []*entity.User, err error) {
+	ctx, span := r.tracing.start(ctx, "users", "FindAll")
+	defer end(span, &err)
+	return r.next.FindAll(ctx, page, limit)
+}
+
+func (r *userRepository) Update(ctx context.Context, user *entity.User) (err error) {
+	ctx, span := r.tracing.start(ctx, "users", "Update")
+	defer end(span, &err)
+	return r.next.Update(ctx, user)
+}
+
+func (r *userRepository) Delete(ctx context.Context, id string) (err error) {
+	ctx, span := r.tracing.start(ctx, "users", "Delete")
+	defer end(span, &err)
+	return r.next.Delete(ctx, id)
+}
+
+func (r *userRepository) CountByRole(ctx context.Context, role entity.Role) (count int64, err error) {
+	ctx, span := r.tracing.start(ctx, "users", "CountByRole")
+	defer end(span, &err)
+	return r.next.CountByRole(ctx, role)
+}
+
+type productRepository struct {
+	next    database.ProductRepository
+	tracing *Tracing
+}
+
+// Products decorates repo with a span for each call to its methods.
+func (t *Tracing) Products(repo database.ProductRepository) database.ProductRepository {
+	return &productRepository{next: repo, tracing: t}
+}
+
+func (r *productRepository) Create(ctx context.Context, product *entity.Product) (err error) {
+	ctx, span := r.tracing.start(ctx, "products", "Create")
+	defer end(span, &err)
+	return r.next.Create(ctx, product)
+}
+
+func (r *productRepository) FindAll(ctx context.Context, page, limit int, sort string) (products []*entity.Product, err error) {
+	ctx, span := r.tracing.start(ctx, "products", "FindAll")
+	defer end(span, &err)
+	return r.next.FindAll(ctx, page, limit, sort)
+}
+
+func (r *productRepository) FindAllByCategories(ctx context.Context, categoryIDs []string, page, limit int, sort string) (products []*entity.Product, err error) {
+	ctx, span := r.tracing.start(ctx, "products", "FindAllByCategories")
+	defer end(span, &err)
+	return r.next.FindAllByCategories(ctx, categoryIDs, page, limit, sort)
+}
+
+func (r *productRepository) FindAllMatching(ctx context.Context, filter database.ProductFilter, page, limit int) (products []*entity.Product, err error) {
+	ctx, span := r.tracing.start(ctx, "products", "FindAllMatching")
+	defer end(span, &err)
+	return r.next.FindAllMatching(ctx, filter, page, limit)
+}
+
+func (r *productRepository) FindPage(ctx context.Context, filter database.ProductFilter, cursor *pagination.Cursor, limit int, sort string) (page *database.ProductPage, err error) {
+	ctx, span := r.tracing.start(ctx, "products", "FindPage")
+	defer end(span, &err)
+	return r.next.FindPage(ctx, filter, cursor, limit, sort)
+}
+
+func (r *productRepository) Search(ctx context.Context, q string, page, limit int) (results []*database.ProductSearchResult, err error) {
+	ctx, span := r.tracing.start(ctx, "products", "Search")
+	defer end(span, &err)
+	return r.next.Search(ctx, q, page, limit)
+}
+
+func (r *productRepository) FindByID(ctx context.Context, id string) (product *entity.Product, err error) {
+	ctx, span := r.tracing.start(ctx, "products", "FindByID")
+	defer end(span, &err)
+	return r.next.FindByID(ctx, id)
+}
+
+func (r *productRepository) Update(ctx context.Context, product *entity.Product) (err error) {
+	ctx, span := r.tracing.start(ctx, "products", "Update")
+	defer end(span, &err)
+	return r.next.Update(ctx, product)
+}
+
+func (r *productRepository) Delete(ctx context.Context, id string) (err error) {
+	ctx, span := r.tracing.start(ctx, "products", "Delete")
+	defer end(span, &err)
+	return r.next.Delete(ctx, id)
+}
@@ -0,0 +1,158 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/Mazzael/go-api/internal/infra/database"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// record traces with a provider whose spans end up in the returned recorder.
+func record() (*Tracing, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	return New(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))), recorder
+}
+
+// attributeOf is the value of the attribute key of span, empty if missing.
+func attributeOf(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestMiddlewareContinuesTheTraceOfTheRequest(t *testing.T) {
+	tracing, recorder := record()
+	r := chi.NewRouter()
+	r.Use(tracing.Middleware)
+	r.Route("/products", func(r chi.Router) {
+		r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			assert.True(t, trace.SpanFromContext(r.Context()).SpanContext().IsValid())
+		})
+		r.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/products/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/products/1", nil))
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 2) {
+		get := spans[0]
+		assert.Equal(t, "GET /products/{id}", get.Name())
+		assert.Equal(t, trace.SpanKindServer, get.SpanKind())
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", get.SpanContext().TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", get.Parent().SpanID().String())
+		assert.Equal(t, "/products/{id}", attributeOf(get, "http.route").AsString())
+		assert.Equal(t, int64(200), attributeOf(get, "http.response.status_code").AsInt64())
+		assert.Equal(t, codes.Unset, get.Status().Code)
+
+		del := spans[1]
+		assert.Equal(t, "DELETE /products/{id}", del.Name())
+		assert.False(t, del.Parent().IsValid())
+		assert.Equal(t, codes.Error, del.Status().Code)
+	}
+}
+
+// failingUsers fails every call with err.
+type failingUsers struct {
+	database.UserRepository
+	err error
+}
+
+func (u *failingUsers) FindByID(ctx context.Context, id string) (*entity.User, error) {
+	return nil, u.err
+}
+
+func TestRepositoryDecoratorsFailSpansOnErrors(t *testing.T) {
+	tracing, recorder := record()
+	ctx := context.Background()
+
+	_, err := tracing.Users(&failingUsers{err: gorm.ErrRecordNotFound}).FindByID(ctx, "1")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	broken := errors.New("connection reset")
+	_, err = tracing.Users(&failingUsers{err: broken}).FindByID(ctx, "1")
+	assert.Equal(t, broken, err)
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 2) {
+		assert.Equal(t, "users.FindByID", spans[0].Name())
+		assert.Equal(t, codes.Unset, spans[0].Status().Code)
+		assert.Equal(t, codes.Error, spans[1].Status().Code)
+		assert.Equal(t, "connection reset", spans[1].Status().Description)
+	}
+}
+
+func TestGormPluginTracesStatementsUnderTheRepositoryCall(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(t.TempDir()+"/test.db"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&entity.Product{}); err != nil {
+		t.Fatal(err)
+	}
+	repository := database.NewProduct(db)
+	tracing, recorder := record()
+	if err := db.Use(tracing.GormPlugin()); err != nil {
+		t.Fatal(err)
+	}
+
+	products := tracing.Products(repository)
+	_, err = products.FindByID(database.AllTenants(context.Background()), "00000000-0000-0000-0000-000000000000")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 2) {
+		query, call := spans[0], spans[1]
+		assert.Equal(t, "products.FindByID", call.Name())
+		assert.Equal(t, "query products", query.Name())
+		assert.Equal(t, trace.SpanKindClient, query.SpanKind())
+		assert.Equal(t, call.SpanContext().SpanID(), query.Parent().SpanID())
+		assert.Equal(t, "sqlite", attributeOf(query, "db.system").AsString())
+		assert.Equal(t, "products", attributeOf(query, "db.collection.name").AsString())
+		text := attributeOf(query, "db.query.text").AsString()
+		assert.Contains(t, text, "SELECT")
+		assert.NotContains(t, text, "00000000-0000-0000-0000-000000000000")
+		assert.Equal(t, codes.Unset, query.Status().Code)
+	}
+}
+
+func TestFileExporterWritesSpansOnShutdown(t *testing.T) {
+	path := t.TempDir() + "/traces.json"
+	exporter, err := NewFileExporter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider, err := NewProvider(context.Background(), exporter, "go-api-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, span := New(provider).tracer.Start(context.Background(), "users.FindByID")
+	span.End()
+	assert.NoError(t, provider.Shutdown(context.Background()))
+
+	written, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(written), `"Name":"users.FindByID"`)
+	assert.Contains(t, string(written), "go-api-test")
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/Mazzael/go-api/internal/infra/database"
+	"github.com/Mazzael/go-api/internal/infra/mail"
+)
+
+// AccountMailer mails users the single use links that verify their email
+// and reset their password. Links point at AppURL, the frontend that posts
+// the token back to the API.
+type AccountMailer struct {
+	Tokens    database.UserTokenRepository
+	Mailer    mail.Mailer
+	Templates *mail.Templates
+	AppURL    string
+	VerifyTTL time.Duration
+	ResetTTL  time.Duration
+	// Go runs a delivery in the background. It is a bare goroutine unless
+	// set, like to webserver.Server.Go for shutdown to wait for the mail.
+	Go func(func(ctx context.Context))
+}
+
+func NewAccountMailer(tokens database.UserTokenRepository, mailer mail.Mailer, templates *mail.Templates, appURL string, verifyTTL, resetTTL time.Duration) *AccountMailer {
+	return &AccountMailer{
+		Tokens:    tokens,
+		Mailer:    mailer,
+		Templates: templates,
+		AppURL:    strings.TrimSuffix(appURL, "/"),
+		VerifyTTL: verifyTTL,
+		ResetTTL:  resetTTL,
+		Go: func(deliver func(ctx context.Context)) {
+			go deliver(context.Background())
+		},
+	}
+}
+
+// accountEmail is what the account templates are rendered with.
+type accountEmail struct {
+	Name      string
+	Email     string
+	Link      string
+	ExpiresIn string
+}
+
+func (m *AccountMailer) sendVerification(ctx context.Context, u *entity.User) error {
+	return m.send(ctx, u, entity.TokenPurposeEmailVerification, m.VerifyTTL, "verify_email", "/verify-email")
+}
+
+func (m *AccountMailer) sendPasswordReset(ctx context.Context, u *entity.User) error {
+	return m.send(ctx, u, entity.TokenPurposePasswordReset, m.ResetTTL, "password_reset", "/reset-password")
+}
+
+// send issues a new token for purpose, invalidating those mailed before,
+// and mails the link carrying it. The message is delivered in the
+// background, so a slow mail server neither delays the response nor tells
+// apart accounts that exist from those that do not; failures are logged.
+func (m *AccountMailer) send(ctx context.Context, u *entity.User, purpose entity.TokenPurpose, ttl time.Duration, template, path string) error {
+	token, plain, err := entity.NewUserToken(u.ID, purpose, ttl)
+	if err != nil {
+		return err
+	}
+	if err := m.Tokens.DeleteForUser(ctx, u.ID.String(), purpose); err != nil {
+		return err
+	}
+	if err := m.Tokens.Create(ctx, token); err != nil {
+		return err
+	}
+
+	msg, err := m.Templates.Render(template, u.Email, accountEmail{
+		Name:      u.Name,
+		Email:     u.Email,
+		Link:      m.AppURL + path + "?token=" + plain,
+		ExpiresIn: humanDuration(ttl),
+	})
+	if err != nil {
+		return err
+	}
+
+	m.Go(func(context.Context) {
+		if err := m.Mailer.Send(msg); err != nil {
+			log.Printf("failed to mail %s to user %s: %v", template, u.ID, err)
+		}
+	})
+	return nil
+}
+
+// humanDuration spells d out in the largest whole unit, like "2 days".
+func humanDuration(d time.Duration) string {
+	for _, unit := range []struct {
+		name string
+		size time.Duration
+	}{
+		{"day", 24 * time.Hour},
+		{"hour", time.Hour},
+		{"minute", time.Minute},
+	} {
+		if d >= unit.size && d%unit.size == 0 {
+			return plural(int(d/unit.size), unit.name)
+		}
+	}
+	if d >= time.Minute {
+		return plural(int(d/time.Minute), "minute")
+	}
+	return plural(int(d/time.Second), "second")
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
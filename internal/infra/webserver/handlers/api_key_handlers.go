@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/dto"
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/Mazzael/go-api/internal/infra/database"
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth"
+)
+
+type APIKeyHandler struct {
+	GormAPIKeyRepository database.APIKeyRepository
+}
+
+func NewAPIKeyHandler(repo database.APIKeyRepository) *APIKeyHandler {
+	return &APIKeyHandler{GormAPIKeyRepository: repo}
+}
+
+// CreateAPIKey godoc
+// @Summary      Create API key
+// @Description  Create a personal API key for scripts and integrations. Send it in the X-API-Key header, or as
+// @Description  a Bearer token, to the product and category endpoints. It grants its scopes only as far as the
+// @Description  role of the user allows. The key is in this response only; it cannot be retrieved later.
+// @Tags         api keys
+// @Accept       json
+// @Produce      json
+// @Param        request  body      dto.CreateAPIKeyInput  true  "key name, scopes and optional expiry"
+// @Success      201      {object}  dto.CreateAPIKeyOutput
+// @Failure      400      {object}  Problem
+// @Failure      401      {object}  Problem
+// @Failure      422      {object}  Problem
+// @Failure      500      {object}  Problem
+// @Router       /users/me/api-keys [post]
+// @Security ApiKeyAuth
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := tokenSubject(w, r)
+	if !ok {
+		return
+	}
+
+	var input dto.CreateAPIKeyInput
+	err := decodeJSON(r, &input)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	scopes := make([]entity.Permission, len(input.Scopes))
+	for i, scope := range input.Scopes {
+		scopes[i] = entity.Permission(scope)
+	}
+	key, plain, err := entity.NewAPIKey(userID, input.Name, scopes, input.ExpiresAt, time.Now())
+	if err == nil {
+		err = h.GormAPIKeyRepository.Create(r.Context(), key)
+	}
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dto.CreateAPIKeyOutput{
+		ID:        key.ID,
+		Name:      key.Name,
+		Key:       plain,
+		Scopes:    input.Scopes,
+		ExpiresAt: key.ExpiresAt,
+		CreatedAt: key.CreatedAt,
+	})
+}
+
+// ListAPIKeys godoc
+// @Summary      List API keys
+// @Description  List the API keys of the current user, newest first, including revoked and expired ones
+// @Tags         api keys
+// @Produce      json
+// @Success      200  {array}   entity.APIKey
+// @Failure      401  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /users/me/api-keys [get]
+// @Security ApiKeyAuth
+func (h *APIKeyHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, ok := tokenSubject(w, r)
+	if !ok {
+		return
+	}
+
+	keys, err := h.GormAPIKeyRepository.FindByUser(r.Context(), userID.String())
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(keys)
+}
+
+// RevokeAPIKey godoc
+// @Summary      Revoke API key
+// @Description  Stop an API key of the current user from working. Revoking it again does nothing.
+// @Tags         api keys
+// @Produce      json
+// @Param        id   path  string  true  "API key ID" Format(uuid)
+// @Success      204
+// @Failure      401  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /users/me/api-keys/{id} [delete]
+// @Security ApiKeyAuth
+func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := tokenSubject(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.GormAPIKeyRepository.Revoke(r.Context(), chi.URLParam(r, "id"), userID.String(), time.Now())
+	if err != nil {
+		writeNotFound(w, r, "API key", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// tokenSubject is the id of the user the access token of r was issued to.
+// Without one it answers 401.
+func tokenSubject(w http.ResponseWriter, r *http.Request) (entityPkg.ID, bool) {
+	id, ok := subjectOf(r)
+	if !ok {
+		WriteProblem(w, r, NewProblem(http.StatusUnauthorized, "a valid access token is required"))
+	}
+	return id, ok
+}
+
+// subjectOf is the id of the user the access token of r was issued to, if
+// it has a valid one.
+func subjectOf(r *http.Request) (entityPkg.ID, bool) {
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err != nil || token == nil {
+		return entityPkg.ID{}, false
+	}
+	id, err := entityPkg.ParseID(token.Subject())
+	return id, err == nil
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/Mazzael/go-api/internal/dto"
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKeysAreShownOnceAndRevokedByTheirOwner(t *testing.T) {
+	s := newUserTestServer(t)
+	token := s.signUp(t, "ana@example.com", "password1")
+	other := s.signUp(t, "bia@example.com", "password1")
+
+	res := s.do(t, http.MethodPost, "/users/me/api-keys", token, `{"name": "ci", "scopes": ["products:read", "products:write"]}`)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	var created dto.CreateAPIKeyOutput
+	json.NewDecoder(res.Body).Decode(&created)
+	assert.True(t, entity.IsAPIKey(created.Key))
+	assert.Equal(t, []string{"products:read", "products:write"}, created.Scopes)
+
+	res = s.do(t, http.MethodGet, "/users/me/api-keys", token, "")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	body, _ := io.ReadAll(res.Body)
+	assert.NotContains(t, string(body), created.Key)
+	var keys []entity.APIKey
+	json.Unmarshal(body, &keys)
+	assert.Len(t, keys, 1)
+	assert.Equal(t, created.ID, keys[0].ID)
+
+	res = s.do(t, http.MethodGet, "/users/me/api-keys", other, "")
+	json.NewDecoder(res.Body).Decode(&keys)
+	assert.Empty(t, keys)
+
+	res = s.do(t, http.MethodDelete, "/users/me/api-keys/"+created.ID.String(), other, "")
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	res = s.do(t, http.MethodDelete, "/users/me/api-keys/"+created.ID.String(), token, "")
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+
+	res = s.do(t, http.MethodGet, "/users/me/api-keys", token, "")
+	json.NewDecoder(res.Body).Decode(&keys)
+	assert.NotNil(t, keys[0].RevokedAt)
+}
+
+func TestCreateAPIKeyValidatesScopes(t *testing.T) {
+	s := newUserTestServer(t)
+	token := s.signUp(t, "ana@example.com", "password1")
+
+	res := s.do(t, http.MethodPost, "/users/me/api-keys", token, `{"name": "ci", "scopes": ["users:manage"]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+
+	res = s.do(t, http.MethodPost, "/users/me/api-keys", token, `{"name": "ci", "scopes": ["products:read"], "expires_at": "2000-01-01T00:00:00Z"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+	var p Problem
+	json.NewDecoder(res.Body).Decode(&p)
+	assert.Equal(t, "expires_at", p.Errors[0].Field)
+}
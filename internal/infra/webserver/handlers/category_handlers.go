@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Mazzael/go-api/internal/dto"
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/Mazzael/go-api/internal/infra/database"
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"github.com/go-chi/chi/v5"
+)
+
+type CategoryHandler struct {
+	GormCategoryRepository database.CategoryRepository
+}
+
+func NewCategoryHandler(repo database.CategoryRepository) *CategoryHandler {
+	return &CategoryHandler{
+		GormCategoryRepository: repo,
+	}
+}
+
+// CreateCategory godoc
+// @Summary      Create category
+// @Description  Create a category, optionally nested under a parent category
+// @Tags         categories
+// @Accept       json
+// @Produce      json
+// @Param        request     body      dto.CreateCategoryInput  true  "category request"
+// @Success      201         {object}  entity.Category
+// @Failure      400         {object}  Problem
+// @Failure      422         {object}  Problem
+// @Failure      403         {object}  Problem
+// @Failure      500         {object}  Problem
+// @Router       /categories [post]
+// @Security ApiKeyAuth
+// @Security PersonalAPIKey
+func (h *CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	var input dto.CreateCategoryInput
+
+	err := decodeJSON(r, &input)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	parentID, err := parseParentID(input.ParentID)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	category, err := entity.NewCategory(input.Name, parentID)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	err = h.GormCategoryRepository.Create(r.Context(), category)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(category)
+}
+
+// GetCategory godoc
+// @Summary      Get a category
+// @Description  Get a category together with its ancestors, from the root down, for breadcrumbs
+// @Tags         categories
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "category ID" Format(uuid)
+// @Success      200  {object}  dto.CategoryOutput
+// @Failure      400  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /categories/{id} [get]
+// @Security ApiKeyAuth
+// @Security PersonalAPIKey
+func (h *CategoryHandler) GetCategory(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		WriteProblem(w, r, NewProblem(http.StatusBadRequest, "category id is required"))
+		return
+	}
+
+	category, err := h.GormCategoryRepository.FindByID(r.Context(), id)
+	if err != nil {
+		writeNotFound(w, r, "category", err)
+		return
+	}
+
+	ancestors, err := h.GormCategoryRepository.FindAncestors(r.Context(), id)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	output := dto.CategoryOutput{
+		ID:        category.ID,
+		Name:      category.Name,
+		ParentID:  category.ParentID,
+		CreatedAt: category.CreatedAt,
+		Ancestors: make([]dto.CategoryBreadcrumb, 0, len(ancestors)),
+	}
+	for _, ancestor := range ancestors {
+		output.Ancestors = append(output.Ancestors, dto.CategoryBreadcrumb{ID: ancestor.ID, Name: ancestor.Name})
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(output)
+}
+
+// GetCategories godoc
+// @Summary      List categories
+// @Description  get all categories
+// @Tags         categories
+// @Accept       json
+// @Produce      json
+// @Success      200       {array}   entity.Category
+// @Failure      403       {object}  Problem
+// @Failure      500       {object}  Problem
+// @Router       /categories [get]
+// @Security ApiKeyAuth
+// @Security PersonalAPIKey
+func (h *CategoryHandler) GetCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.GormCategoryRepository.FindAll(r.Context())
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(categories)
+}
+
+// UpdateCategory godoc
+// @Summary      Update a category
+// @Description  Rename a category or move it under another parent
+// @Tags         categories
+// @Accept       json
+// @Produce      json
+// @Param        id          path      string                   true  "category ID" Format(uuid)
+// @Param        request     body      dto.CreateCategoryInput  true  "category request"
+// @Success      200
+// @Failure      400       {object}  Problem
+// @Failure      422       {object}  Problem
+// @Failure      404       {object}  Problem
+// @Failure      403       {object}  Problem
+// @Failure      500       {object}  Problem
+// @Router       /categories/{id} [put]
+// @Security ApiKeyAuth
+// @Security PersonalAPIKey
+func (h *CategoryHandler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		WriteProblem(w, r, NewProblem(http.StatusBadRequest, "category id is required"))
+		return
+	}
+
+	var input dto.CreateCategoryInput
+
+	err := decodeJSON(r, &input)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	category, err := h.GormCategoryRepository.FindByID(r.Context(), id)
+	if err != nil {
+		writeNotFound(w, r, "category", err)
+		return
+	}
+
+	category.Name = input.Name
+	category.ParentID, err = parseParentID(input.ParentID)
+	if err == nil {
+		err = category.Validate()
+	}
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	err = h.GormCategoryRepository.Update(r.Context(), category)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// DeleteCategory godoc
+// @Summary      Delete a category
+// @Description  Delete a category that has no child categories
+// @Tags         categories
+// @Accept       json
+// @Produce      json
+// @Param        id        path      string                  true  "category ID" Format(uuid)
+// @Success      204
+// @Failure      400       {object}  Problem
+// @Failure      404       {object}  Problem
+// @Failure      409       {object}  Problem
+// @Failure      403       {object}  Problem
+// @Failure      500       {object}  Problem
+// @Router       /categories/{id} [delete]
+// @Security ApiKeyAuth
+// @Security PersonalAPIKey
+func (h *CategoryHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		WriteProblem(w, r, NewProblem(http.StatusBadRequest, "category id is required"))
+		return
+	}
+
+	_, err := h.GormCategoryRepository.FindByID(r.Context(), id)
+	if err != nil {
+		writeNotFound(w, r, "category", err)
+		return
+	}
+
+	err = h.GormCategoryRepository.Delete(r.Context(), id)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func parseParentID(parentID *string) (*entityPkg.ID, error) {
+	if parentID == nil || *parentID == "" {
+		return nil, nil
+	}
+
+	id, err := entityPkg.ParseID(*parentID)
+	if err != nil {
+		return nil, entity.ErrInvalidParent
+	}
+	return &id, nil
+}
@@ -0,0 +1,24 @@
+package handlers
+
+// Outcomes of a login attempt, see Events.
+const (
+	LoginSucceeded = "succeeded"
+	LoginFailed    = "failed"
+	LoginThrottled = "throttled"
+)
+
+// Events is told what happens in the domain, e.g. to count it as metrics.
+type Events interface {
+	// Login is an attempt to log in, with a password or a second factor,
+	// that got an outcome: one that needs a second factor has none yet.
+	Login(outcome string)
+	ProductCreated()
+}
+
+// NoEvents ignores every event. The handlers report to it unless given
+// other Events.
+type NoEvents struct{}
+
+func (NoEvents) Login(outcome string) {}
+
+func (NoEvents) ProductCreated() {}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Mazzael/go-api/pkg/health"
+)
+
+type HealthHandler struct {
+	Health *health.Health
+}
+
+func NewHealthHandler(h *health.Health) *HealthHandler {
+	return &HealthHandler{Health: h}
+}
+
+// Live godoc
+// @Summary      Liveness
+// @Description  200 as long as the process serves HTTP at all; it checks no dependency, so a failing database does
+// @Description  not get the instance restarted.
+// @Tags         health
+// @Produce      json
+// @Success      200
+// @Router       /healthz [get]
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": health.StatusUp})
+}
+
+// Ready godoc
+// @Summary      Readiness
+// @Description  Checks every dependency the instance needs to serve requests, like the database and the mailer,
+// @Description  and reports each with its status and latency. 503 when any of them is down, or once the instance
+// @Description  is shutting down. Reports are cached briefly, see checked_at.
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  health.Report
+// @Failure      503  {object}  health.Report
+// @Router       /readyz [get]
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	report := h.Health.Check(r.Context())
+	status := http.StatusOK
+	if !report.Up() {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/infra/database"
+	"github.com/Mazzael/go-api/pkg/health"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestLiveChecksNothing(t *testing.T) {
+	h := health.New(0, time.Second)
+	h.Register("database", health.CheckerFunc(func(ctx context.Context) error { return errors.New("down") }))
+
+	rec := httptest.NewRecorder()
+	NewHealthHandler(h).Live(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestReadyReportsEachCheck(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(t.TempDir()+"/test.db"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := health.New(0, time.Second)
+	h.Register("database", database.HealthCheck(db))
+	handler := NewHealthHandler(h)
+
+	rec := httptest.NewRecorder()
+	handler.Ready(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var report health.Report
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+	assert.Equal(t, health.StatusUp, report.Status)
+	if assert.Len(t, report.Checks, 1) {
+		assert.Equal(t, "database", report.Checks[0].Name)
+		assert.Equal(t, health.StatusUp, report.Checks[0].Status)
+	}
+
+	assert.NoError(t, database.Close(db))
+	rec = httptest.NewRecorder()
+	handler.Ready(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+	assert.Equal(t, health.StatusDown, report.Status)
+	assert.NotEmpty(t, report.Checks[0].Error)
+}
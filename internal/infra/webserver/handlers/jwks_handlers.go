@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Mazzael/go-api/pkg/jwtkeys"
+)
+
+type JWKSHandler struct {
+	Keyring *jwtkeys.Keyring
+}
+
+func NewJWKSHandler(keyring *jwtkeys.Keyring) *JWKSHandler {
+	return &JWKSHandler{Keyring: keyring}
+}
+
+// GetJWKS godoc
+// @Summary      Get token signing keys
+// @Description  The public keys access tokens are verified with, as a JSON Web Key Set. Tokens name their key in
+// @Description  the kid header. During a key rotation both the new and the previous key are listed, so caches
+// @Description  should be refreshed when a token names an unknown key.
+// @Tags         auth
+// @Produce      json
+// @Success      200  {object}  dto.JSONWebKeySet
+// @Router       /.well-known/jwks.json [get]
+func (h *JWKSHandler) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(h.Keyring.PublicKeys())
+}
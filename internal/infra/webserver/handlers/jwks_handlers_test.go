@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Mazzael/go-api/pkg/jwtkeys"
+	"github.com/stretchr/testify/assert"
+)
+
+func newECKey(t *testing.T) *jwtkeys.Key {
+	raw, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwtkeys.NewKey(raw, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestGetJWKSListsThePublicKeysInUse(t *testing.T) {
+	current := newECKey(t)
+	keyring, _ := jwtkeys.NewKeyring(current)
+	next := newECKey(t)
+	assert.NoError(t, keyring.Rotate(next, time.Hour))
+
+	rec := httptest.NewRecorder()
+	NewJWKSHandler(keyring).GetJWKS(rec, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var set struct {
+		Keys []map[string]interface{} `json:"keys"`
+	}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&set))
+	assert.Len(t, set.Keys, 2)
+	assert.Equal(t, next.ID, set.Keys[0]["kid"])
+	assert.Equal(t, current.ID, set.Keys[1]["kid"])
+	for _, key := range set.Keys {
+		assert.Equal(t, "ES256", key["alg"])
+		assert.Equal(t, "sig", key["use"])
+		assert.Equal(t, "EC", key["kty"])
+		assert.NotContains(t, key, "d")
+	}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/Mazzael/go-api/internal/infra/database"
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+)
+
+// LoginThrottle slows down password guessing by tracking failed logins per
+// account and per client IP. Whichever of the two is blocked longer decides
+// when the next attempt is accepted.
+type LoginThrottle struct {
+	Attempts database.LoginAttemptRepository
+	Account  entity.LoginPolicy
+	IP       entity.LoginPolicy
+}
+
+func NewLoginThrottle(attempts database.LoginAttemptRepository, account, ip entity.LoginPolicy) *LoginThrottle {
+	return &LoginThrottle{Attempts: attempts, Account: account, IP: ip}
+}
+
+// retryAfter is how long the email in the tenant and the client IP of r
+// must wait before they may try to log in again.
+func (t *LoginThrottle) retryAfter(r *http.Request, tenantID entityPkg.ID, email string, now time.Time) (time.Duration, error) {
+	var wait time.Duration
+	for _, key := range []string{entity.AccountLoginKey(tenantID, email), entity.IPLoginKey(clientIP(r))} {
+		attempts, err := t.Attempts.Find(r.Context(), key)
+		if err != nil {
+			return 0, err
+		}
+		if retryAfter := attempts.RetryAfter(now); retryAfter > wait {
+			wait = retryAfter
+		}
+	}
+	return wait, nil
+}
+
+func (t *LoginThrottle) fail(r *http.Request, tenantID entityPkg.ID, email string, now time.Time) error {
+	if _, err := t.Attempts.RecordFailure(r.Context(), entity.AccountLoginKey(tenantID, email), now, t.Account); err != nil {
+		return err
+	}
+	_, err := t.Attempts.RecordFailure(r.Context(), entity.IPLoginKey(clientIP(r)), now, t.IP)
+	return err
+}
+
+// reset forgets the failures of the account. Those of the IP are kept, so
+// that logging into an account of one's own does not reset guessing at
+// others.
+func (t *LoginThrottle) reset(ctx context.Context, tenantID entityPkg.ID, email string) error {
+	return t.Attempts.Reset(ctx, entity.AccountLoginKey(tenantID, email))
+}
+
+// clientIP is the address the request came from. Behind a proxy it is only
+// the client's when middleware.RealIP rewrote RemoteAddr.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func writeThrottled(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	WriteProblem(w, r, &Problem{
+		Type:   ProblemTypeLoginThrottled,
+		Title:  "Too many login attempts",
+		Status: http.StatusTooManyRequests,
+		Detail: entity.ErrLoginThrottled.Error() + ", retry later",
+	})
+}
+
+// dummyUser has a password hash to compare against when no user has the
+// email, so that unknown accounts take as long to reject as wrong passwords.
+var dummyUser = sync.OnceValue(func() *entity.User {
+	u, _ := entity.NewUser("", "", "not a password anyone has")
+	return u
+})
@@ -0,0 +1,382 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/dto"
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/Mazzael/go-api/internal/infra/database"
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// MFA is what two-factor logins take besides the users: the challenges
+// handed out between checking the password and the code, and the recovery
+// codes. Issuer names the API in authenticator apps.
+type MFA struct {
+	Challenges    database.UserTokenRepository
+	RecoveryCodes database.RecoveryCodeRepository
+	Issuer        string
+	ChallengeTTL  time.Duration
+}
+
+func NewMFA(challenges database.UserTokenRepository, recoveryCodes database.RecoveryCodeRepository, issuer string, challengeTTL time.Duration) *MFA {
+	return &MFA{
+		Challenges:    challenges,
+		RecoveryCodes: recoveryCodes,
+		Issuer:        issuer,
+		ChallengeTTL:  challengeTTL,
+	}
+}
+
+// LoginMFA godoc
+// @Summary      Complete a two-factor login
+// @Description  Exchange the challenge token of a login that answered mfa_required, together with a code of the
+// @Description  authenticator app or an unused recovery code, for the access and refresh tokens. Wrong codes count
+// @Description  as failed logins and are throttled alike; the challenge stays valid until it expires or succeeds.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request  body      dto.MFALoginInput  true  "challenge token and code"
+// @Success      200      {object}  dto.UserLoginOutput
+// @Failure      400      {object}  Problem
+// @Failure      401      {object}  Problem
+// @Failure      403      {object}  Problem
+// @Failure      422      {object}  Problem
+// @Failure      429      {object}  Problem
+// @Header       429      {integer}  Retry-After  "seconds until the next attempt is accepted"
+// @Failure      500      {object}  Problem
+// @Router       /users/auth/mfa [post]
+func (h *UserHandler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var input dto.MFALoginInput
+	err := decodeJSON(r, &input)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	now := time.Now()
+	hash := entityPkg.HashToken(input.ChallengeToken)
+	challenge, err := h.MFA.Challenges.Find(r.Context(), hash, entity.TokenPurposeMFAChallenge, now)
+	var u *entity.User
+	if err == nil {
+		// the challenge identifies the user, whatever tenant r names.
+		u, err = h.GormUserRepository.FindByID(database.AllTenants(r.Context()), challenge.UserID.String())
+	}
+	if errors.Is(err, entity.ErrInvalidUserToken) || errors.Is(err, gorm.ErrRecordNotFound) {
+		WriteError(w, r, entity.ErrInvalidMFAChallenge)
+		return
+	}
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	ctx := database.WithTenant(r.Context(), u.TenantID)
+	if h.LoginThrottle != nil {
+		wait, err := h.LoginThrottle.retryAfter(r, u.TenantID, u.Email, now)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		if wait > 0 {
+			h.Events.Login(LoginThrottled)
+			writeThrottled(w, r, wait)
+			return
+		}
+	}
+
+	err = h.checkSecondFactor(ctx, u, input.Code, now)
+	if errors.Is(err, entity.ErrInvalidMFACode) {
+		h.Events.Login(LoginFailed)
+		if h.LoginThrottle != nil {
+			if failErr := h.LoginThrottle.fail(r, u.TenantID, u.Email, now); failErr != nil {
+				err = failErr
+			}
+		}
+	}
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	_, err = h.MFA.Challenges.Consume(r.Context(), hash, entity.TokenPurposeMFAChallenge, now)
+	if errors.Is(err, entity.ErrInvalidUserToken) {
+		err = entity.ErrInvalidMFAChallenge
+	}
+	if err == nil && u.Disabled {
+		h.Events.Login(LoginFailed)
+		err = entity.ErrUserDisabled
+	}
+	if err == nil && h.LoginThrottle != nil {
+		err = h.LoginThrottle.reset(r.Context(), u.TenantID, u.Email)
+	}
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	refreshToken, plainRefreshToken, err := entity.NewRefreshToken(u.ID, entityPkg.ID{}, refreshTokenTTL(r))
+	if err == nil {
+		err = h.GormRefreshTokenRepository.Create(r.Context(), refreshToken)
+	}
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	h.Events.Login(LoginSucceeded)
+	h.writeTokens(w, r, u, plainRefreshToken)
+}
+
+// EnrollTOTP godoc
+// @Summary      Enroll an authenticator app
+// @Description  Generate a TOTP secret for the current user to add to an authenticator app, by hand or by scanning
+// @Description  the otpauth URI as a QR code. Logins take no code until it is confirmed; enrolling again before
+// @Description  that replaces the secret.
+// @Tags         users
+// @Produce      json
+// @Success      200  {object}  dto.TOTPEnrollmentOutput
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /users/me/mfa/totp [post]
+// @Security ApiKeyAuth
+func (h *UserHandler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	key, err := u.EnrollTOTP()
+	if err == nil {
+		err = h.GormUserRepository.Update(r.Context(), u)
+	}
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.TOTPEnrollmentOutput{
+		Secret: key.Encoded(),
+		URI:    key.URI(h.MFA.Issuer, u.Email),
+	})
+}
+
+// ConfirmTOTP godoc
+// @Summary      Confirm the authenticator app
+// @Description  Turn two-factor authentication on with a code of the app just enrolled. The response holds the
+// @Description  recovery codes, which stand in for a code once each when the app is lost; they are not shown again.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request  body      dto.MFACodeInput  true  "code of the authenticator app"
+// @Success      200      {object}  dto.RecoveryCodesOutput
+// @Failure      400      {object}  Problem
+// @Failure      401      {object}  Problem
+// @Failure      403      {object}  Problem
+// @Failure      409      {object}  Problem
+// @Failure      422      {object}  Problem
+// @Failure      500      {object}  Problem
+// @Router       /users/me/mfa/totp/confirm [post]
+// @Security ApiKeyAuth
+func (h *UserHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	var input dto.MFACodeInput
+	err := decodeJSON(r, &input)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	u, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	err = u.ConfirmTOTP(input.Code, time.Now())
+	if err == nil {
+		err = h.GormUserRepository.Update(r.Context(), u)
+	}
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	h.writeRecoveryCodes(w, r, u)
+}
+
+// DisableTOTP godoc
+// @Summary      Turn two-factor authentication off
+// @Description  Stop asking the current user for a code when logging in, given a code of the authenticator app or
+// @Description  a recovery code. The secret and the recovery codes are discarded.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request  body  dto.MFACodeInput  true  "code of the authenticator app or recovery code"
+// @Success      204
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      422  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /users/me/mfa/totp/disable [post]
+// @Security ApiKeyAuth
+func (h *UserHandler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	var input dto.MFACodeInput
+	err := decodeJSON(r, &input)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	u, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	err = h.checkSecondFactor(r.Context(), u, input.Code, time.Now())
+	if err == nil {
+		err = h.disableMFA(r.Context(), u)
+	}
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary      Regenerate recovery codes
+// @Description  Replace the recovery codes of the current user, given a code of the authenticator app or a recovery
+// @Description  code. Those issued before stop working; the new ones are not shown again.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request  body      dto.MFACodeInput  true  "code of the authenticator app or recovery code"
+// @Success      200      {object}  dto.RecoveryCodesOutput
+// @Failure      400      {object}  Problem
+// @Failure      401      {object}  Problem
+// @Failure      403      {object}  Problem
+// @Failure      409      {object}  Problem
+// @Failure      422      {object}  Problem
+// @Failure      500      {object}  Problem
+// @Router       /users/me/mfa/recovery-codes [post]
+// @Security ApiKeyAuth
+func (h *UserHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	var input dto.MFACodeInput
+	err := decodeJSON(r, &input)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	u, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	err = h.checkSecondFactor(r.Context(), u, input.Code, time.Now())
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	h.writeRecoveryCodes(w, r, u)
+}
+
+// ResetMFA godoc
+// @Summary      Reset two-factor authentication
+// @Description  Turn two-factor authentication off for a user who lost both the authenticator app and the recovery
+// @Description  codes, after checking who they are some other way. They can enroll again once logged in.
+// @Tags         users
+// @Produce      json
+// @Param        id   path  string  true  "user ID" Format(uuid)
+// @Success      204
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /users/{id}/mfa/reset [post]
+// @Security ApiKeyAuth
+func (h *UserHandler) ResetMFA(w http.ResponseWriter, r *http.Request) {
+	u, err := h.GormUserRepository.FindByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeNotFound(w, r, "user", err)
+		return
+	}
+
+	err = h.disableMFA(r.Context(), u)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeMFAChallenge answers a login with the right password for a user with
+// two-factor authentication: tokens are issued once a code follows.
+func (h *UserHandler) writeMFAChallenge(w http.ResponseWriter, r *http.Request, u *entity.User) {
+	challenge, plain, err := entity.NewUserToken(u.ID, entity.TokenPurposeMFAChallenge, h.MFA.ChallengeTTL)
+	if err == nil {
+		err = h.MFA.Challenges.Create(r.Context(), challenge)
+	}
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(dto.MFAChallengeOutput{
+		MFARequired:    true,
+		ChallengeToken: plain,
+		ExpiresIn:      int(h.MFA.ChallengeTTL / time.Second),
+	})
+}
+
+// writeRecoveryCodes issues new recovery codes to u and writes them.
+func (h *UserHandler) writeRecoveryCodes(w http.ResponseWriter, r *http.Request, u *entity.User) {
+	codes, plain, err := entity.NewRecoveryCodes(u.ID, time.Now())
+	if err == nil {
+		err = h.MFA.RecoveryCodes.Replace(r.Context(), u.ID.String(), codes)
+	}
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.RecoveryCodesOutput{RecoveryCodes: plain})
+}
+
+// checkSecondFactor accepts a code of the authenticator app of u, or else
+// one of its recovery codes, using either up.
+func (h *UserHandler) checkSecondFactor(ctx context.Context, u *entity.User, code string, now time.Time) error {
+	if !u.HasMFA() {
+		return entity.ErrMFANotEnrolled
+	}
+	if entity.IsTOTPCode(code) {
+		if err := u.CheckTOTP(code, now); err != nil {
+			return err
+		}
+		return h.GormUserRepository.Update(ctx, u)
+	}
+	return h.MFA.RecoveryCodes.Consume(ctx, u.ID.String(), entity.HashRecoveryCode(code), now)
+}
+
+func (h *UserHandler) disableMFA(ctx context.Context, u *entity.User) error {
+	u.DisableTOTP()
+	if err := h.GormUserRepository.Update(ctx, u); err != nil {
+		return err
+	}
+	return h.MFA.RecoveryCodes.DeleteForUser(ctx, u.ID.String())
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/dto"
+	"github.com/Mazzael/go-api/pkg/totp"
+	"github.com/stretchr/testify/assert"
+)
+
+// enableTOTP enrolls and confirms an authenticator app for the user of
+// token, returning its key and the recovery codes.
+func (s *userTestServer) enableTOTP(t *testing.T, token string) (totp.Key, []string) {
+	res := s.do(t, http.MethodPost, "/users/me/mfa/totp", token, "")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var enrollment dto.TOTPEnrollmentOutput
+	json.NewDecoder(res.Body).Decode(&enrollment)
+	key, err := totp.Parse(enrollment.Secret)
+	assert.NoError(t, err)
+
+	res = s.do(t, http.MethodPost, "/users/me/mfa/totp/confirm", token, `{"code": "`+key.Code(time.Now())+`"}`)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var output dto.RecoveryCodesOutput
+	json.NewDecoder(res.Body).Decode(&output)
+	return key, output.RecoveryCodes
+}
+
+// challenge logs in with the password of a user with two-factor
+// authentication and returns the challenge token.
+func (s *userTestServer) challenge(t *testing.T, email, password string) string {
+	res := s.do(t, http.MethodPost, "/users/auth", "", `{"email": "`+email+`", "password": "`+password+`"}`)
+	assert.Equal(t, http.StatusAccepted, res.StatusCode)
+	var output dto.MFAChallengeOutput
+	json.NewDecoder(res.Body).Decode(&output)
+	assert.True(t, output.MFARequired)
+	assert.Equal(t, 300, output.ExpiresIn)
+	return output.ChallengeToken
+}
+
+func (s *userTestServer) loginMFA(t *testing.T, challenge, code string) (*http.Response, dto.UserLoginOutput) {
+	res := s.do(t, http.MethodPost, "/users/auth/mfa", "", `{"challenge_token": "`+challenge+`", "code": "`+code+`"}`)
+	var output dto.UserLoginOutput
+	if res.StatusCode == http.StatusOK {
+		json.NewDecoder(res.Body).Decode(&output)
+	}
+	return res, output
+}
+
+func TestTOTPEnrollment(t *testing.T) {
+	s := newUserTestServer(t)
+	token := s.signUp(t, "ana@example.com", "password1")
+
+	res := s.do(t, http.MethodPost, "/users/me/mfa/totp", token, "")
+	var enrollment dto.TOTPEnrollmentOutput
+	json.NewDecoder(res.Body).Decode(&enrollment)
+	uri, err := url.Parse(enrollment.URI)
+	assert.NoError(t, err)
+	assert.Equal(t, "/Go API:ana@example.com", uri.Path)
+	assert.Equal(t, enrollment.Secret, uri.Query().Get("secret"))
+
+	// logins take no code until the app is confirmed.
+	assert.NotEmpty(t, s.login(t, "ana@example.com", "password1"))
+
+	key, _ := totp.Parse(enrollment.Secret)
+	res = s.do(t, http.MethodPost, "/users/me/mfa/totp/confirm", token, `{"code": "`+key.Code(time.Now().Add(time.Hour))+`"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+	var p Problem
+	json.NewDecoder(res.Body).Decode(&p)
+	assert.Equal(t, "code", p.Errors[0].Field)
+
+	res = s.do(t, http.MethodPost, "/users/me/mfa/totp/confirm", token, `{"code": "`+key.Code(time.Now())+`"}`)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var codes dto.RecoveryCodesOutput
+	json.NewDecoder(res.Body).Decode(&codes)
+	assert.Len(t, codes.RecoveryCodes, 10)
+
+	res = s.do(t, http.MethodPost, "/users/me/mfa/totp", token, "")
+	assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+	ana, _ := s.users.FindByEmail(s.ctx, "ana@example.com")
+	assert.True(t, ana.HasMFA())
+	assert.Empty(t, s.login(t, "ana@example.com", "password1"))
+}
+
+func TestLoginWithTOTP(t *testing.T) {
+	s := newUserTestServer(t)
+	key, _ := s.enableTOTP(t, s.signUp(t, "ana@example.com", "password1"))
+
+	challenge := s.challenge(t, "ana@example.com", "password1")
+
+	// the code confirming the app was used up, so take the next one.
+	res, _ := s.loginMFA(t, challenge, key.Code(time.Now()))
+	assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+
+	// a wrong code leaves the challenge usable.
+	res, output := s.loginMFA(t, challenge, key.Code(time.Now().Add(30*time.Second)))
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.NotEmpty(t, output.AccessToken)
+	assert.NotEmpty(t, output.RefreshToken)
+
+	res, _ = s.loginMFA(t, challenge, key.Code(time.Now().Add(30*time.Second)))
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	var p Problem
+	json.NewDecoder(res.Body).Decode(&p)
+	assert.Equal(t, ProblemTypeInvalidToken, p.Type)
+
+	// the challenge is no access token.
+	res = s.do(t, http.MethodGet, "/users/me", challenge, "")
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+}
+
+func TestLoginWithRecoveryCodes(t *testing.T) {
+	s := newUserTestServer(t)
+	token := s.signUp(t, "ana@example.com", "password1")
+	key, codes := s.enableTOTP(t, token)
+
+	res, output := s.loginMFA(t, s.challenge(t, "ana@example.com", "password1"), codes[0])
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.NotEmpty(t, output.AccessToken)
+
+	challenge := s.challenge(t, "ana@example.com", "password1")
+	res, _ = s.loginMFA(t, challenge, codes[0])
+	assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+
+	res = s.do(t, http.MethodPost, "/users/me/mfa/recovery-codes", token, `{"code": "`+key.Code(time.Now().Add(30*time.Second))+`"}`)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var regenerated dto.RecoveryCodesOutput
+	json.NewDecoder(res.Body).Decode(&regenerated)
+
+	res, _ = s.loginMFA(t, challenge, codes[1])
+	assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+	res, _ = s.loginMFA(t, challenge, regenerated.RecoveryCodes[0])
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+}
+
+func TestWrongCodesAreThrottledLikeWrongPasswords(t *testing.T) {
+	s := newUserTestServer(t)
+	s.enableTOTP(t, s.signUp(t, "ana@example.com", "password1"))
+
+	for i := 0; i < 3; i++ {
+		// logging in again must not forget the wrong codes.
+		res, _ := s.loginMFA(t, s.challenge(t, "ana@example.com", "password1"), "000000")
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+	}
+
+	res := s.do(t, http.MethodPost, "/users/auth", "", `{"email": "ana@example.com", "password": "password1"}`)
+	assert.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+}
+
+func TestDisableTOTP(t *testing.T) {
+	s := newUserTestServer(t)
+	token := s.signUp(t, "ana@example.com", "password1")
+	_, codes := s.enableTOTP(t, token)
+
+	res := s.do(t, http.MethodPost, "/users/me/mfa/totp/disable", token, `{"code": "000000"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+	res = s.do(t, http.MethodPost, "/users/me/mfa/totp/disable", token, `{"code": "`+codes[0]+`"}`)
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+
+	assert.NotEmpty(t, s.login(t, "ana@example.com", "password1"))
+	res = s.do(t, http.MethodPost, "/users/me/mfa/totp/disable", token, `{"code": "`+codes[1]+`"}`)
+	assert.Equal(t, http.StatusConflict, res.StatusCode)
+}
+
+func TestAdminsResetTOTP(t *testing.T) {
+	s := newUserTestServer(t)
+	adminToken := s.signUp(t, "admin@example.com", "password1")
+	s.enableTOTP(t, s.signUp(t, "ana@example.com", "password1"))
+
+	ana, _ := s.users.FindByEmail(s.ctx, "ana@example.com")
+	res := s.do(t, http.MethodPost, "/users/"+ana.ID.String()+"/mfa/reset", adminToken, "")
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	assert.NotEmpty(t, s.login(t, "ana@example.com", "password1"))
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/go-chi/jwtauth"
+)
+
+// Grants checks the claims of an access token for permission: the role
+// claim has to grant it and, for API keys, the scopes claim has to include
+// it. It returns the 403 problem saying which is missing, or nil.
+func Grants(claims map[string]interface{}, permission entity.Permission) *Problem {
+	role, _ := claims["role"].(string)
+	if !entity.Role(role).Can(permission) {
+		return NewProblem(http.StatusForbidden, "missing permission "+string(permission))
+	}
+	if scopes, ok := claims["scopes"]; ok && !hasScope(scopes, permission) {
+		return NewProblem(http.StatusForbidden, "API key lacks scope "+string(permission))
+	}
+	return nil
+}
+
+// tokenGrants reports whether the access token of r grants permission.
+func tokenGrants(r *http.Request, permission entity.Permission) bool {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	return err == nil && Grants(claims, permission) == nil
+}
+
+func hasScope(scopes interface{}, permission entity.Permission) bool {
+	switch scopes := scopes.(type) {
+	case []string:
+		for _, scope := range scopes {
+			if scope == string(permission) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, scope := range scopes {
+			if scope == string(permission) {
+				return true
+			}
+		}
+	}
+	return false
+}
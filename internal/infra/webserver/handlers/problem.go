@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/Mazzael/go-api/internal/infra/database"
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"github.com/Mazzael/go-api/pkg/pagination"
+	"github.com/Mazzael/go-api/pkg/validation"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const ProblemContentType = "application/problem+json"
+
+// Problem types beyond the plain HTTP status, which uses about:blank. They
+// are relative URIs and documented in the swagger description.
+const (
+	ProblemTypeValidation        = "/problems/validation"
+	ProblemTypeInvalidParameter  = "/problems/invalid-parameter"
+	ProblemTypeMalformedBody     = "/problems/malformed-body"
+	ProblemTypeInsufficientStock = "/problems/insufficient-stock"
+	ProblemTypeInvalidToken      = "/problems/invalid-token"
+	ProblemTypeTokenReused       = "/problems/token-reused"
+	ProblemTypeEmailTaken        = "/problems/email-taken"
+	ProblemTypeLoginThrottled    = "/problems/login-throttled"
+	ProblemTypeEmailUnverified   = "/problems/email-unverified"
+)
+
+// Problem is an RFC 7807 problem details body, sent as
+// application/problem+json for every error response.
+type Problem struct {
+	Type     string       `json:"type" example:"/problems/validation"`
+	Title    string       `json:"title" example:"Validation failed"`
+	Status   int          `json:"status" example:"422"`
+	Detail   string       `json:"detail,omitempty" example:"name is required"`
+	Instance string       `json:"instance,omitempty" example:"/products"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError points a problem at one input field or query parameter.
+type FieldError struct {
+	Field   string `json:"field" example:"name"`
+	Message string `json:"message" example:"name is required"`
+}
+
+// NewProblem returns a problem that adds nothing to the status code but a
+// human readable detail.
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// WriteProblem writes p, filling in the request path as its instance.
+func WriteProblem(w http.ResponseWriter, r *http.Request, p *Problem) {
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// WriteError maps err to a problem and writes it. Errors it does not know
+// become a 500 whose detail hides the cause, which is logged instead.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	p := problemFor(err)
+	if p.Status == http.StatusInternalServerError {
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+	}
+	WriteProblem(w, r, p)
+}
+
+// NotFound answers requests no route matches.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	WriteProblem(w, r, NewProblem(http.StatusNotFound, "no route matches "+r.URL.Path))
+}
+
+// MethodNotAllowed answers requests whose route does not accept the method.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	WriteProblem(w, r, NewProblem(http.StatusMethodNotAllowed, r.Method+" is not allowed on "+r.URL.Path))
+}
+
+// writeNotFound answers a failed lookup of resource: 404 when the record
+// does not exist and 500 for anything else, without echoing driver errors.
+func writeNotFound(w http.ResponseWriter, r *http.Request, resource string, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		WriteProblem(w, r, NewProblem(http.StatusNotFound, resource+" not found"))
+		return
+	}
+	WriteError(w, r, err)
+}
+
+// problemMapping ties a domain error to the problem it is reported as. When
+// field is set the problem lists the error against that input field.
+type problemMapping struct {
+	err    error
+	status int
+	typ    string
+	title  string
+	field  string
+	detail string
+}
+
+var problemMappings = []problemMapping{
+	{err: gorm.ErrRecordNotFound, status: http.StatusNotFound, detail: "resource not found"},
+
+	// a statement ran out of time, see database.Timeouts, or the client
+	// went away while it ran.
+	{err: context.DeadlineExceeded, status: http.StatusGatewayTimeout, detail: "the database did not answer in time"},
+	{err: context.Canceled, status: http.StatusServiceUnavailable, detail: "the request was cancelled"},
+
+	{err: entity.ErrIDIsRequired, status: http.StatusUnprocessableEntity, field: "id"},
+	{err: entity.ErrInvalidID, status: http.StatusUnprocessableEntity, field: "id"},
+	{err: entity.ErrNameIsRequired, status: http.StatusUnprocessableEntity, field: "name"},
+	{err: entity.ErrPriceIsRequired, status: http.StatusUnprocessableEntity, field: "price"},
+	{err: entity.ErrInvalidPrice, status: http.StatusUnprocessableEntity, field: "price"},
+	{err: entity.ErrInvalidCurrency, status: http.StatusUnprocessableEntity, field: "price.currency"},
+	{err: entityPkg.ErrInvalidCurrency, status: http.StatusUnprocessableEntity, field: "price.currency"},
+	{err: entityPkg.ErrInvalidAmount, status: http.StatusUnprocessableEntity, field: "price.amount"},
+	{err: entityPkg.ErrTooManyDecimals, status: http.StatusUnprocessableEntity, field: "price.amount"},
+	{err: entityPkg.ErrMoneyOverflow, status: http.StatusUnprocessableEntity, field: "price.amount"},
+	{err: entity.ErrInvalidParent, status: http.StatusUnprocessableEntity, field: "parent_id"},
+	{err: entity.ErrCategoryCycle, status: http.StatusUnprocessableEntity, field: "parent_id"},
+	{err: entity.ErrCategoryNotFound, status: http.StatusUnprocessableEntity, field: "category_ids"},
+	{err: entity.ErrInvalidMovementType, status: http.StatusUnprocessableEntity, field: "type"},
+	{err: entity.ErrInvalidQuantity, status: http.StatusUnprocessableEntity, field: "quantity"},
+	{err: entity.ErrInvalidRole, status: http.StatusUnprocessableEntity, field: "role"},
+	{err: bcrypt.ErrPasswordTooLong, status: http.StatusUnprocessableEntity, field: "password"},
+	{err: entity.ErrIncorrectPassword, status: http.StatusUnprocessableEntity, field: "current_password"},
+	{err: entity.ErrInvalidUserToken, status: http.StatusUnprocessableEntity, field: "token"},
+	{err: entity.ErrInvalidScope, status: http.StatusUnprocessableEntity, field: "scopes"},
+	{err: entity.ErrExpiryInThePast, status: http.StatusUnprocessableEntity, field: "expires_at"},
+	{err: entity.ErrInvalidMFACode, status: http.StatusUnprocessableEntity, field: "code"},
+
+	{err: pagination.ErrInvalidCursor, status: http.StatusBadRequest, typ: ProblemTypeInvalidParameter, title: "Invalid query parameter", field: "cursor"},
+	{err: database.ErrInvalidSortField, status: http.StatusBadRequest, typ: ProblemTypeInvalidParameter, title: "Invalid query parameter", field: "sort"},
+	{err: entityPkg.ErrCurrencyMismatch, status: http.StatusBadRequest, typ: ProblemTypeInvalidParameter, title: "Invalid query parameter", field: "price[currency]"},
+	{err: entity.ErrUnknownTenant, status: http.StatusBadRequest, typ: ProblemTypeInvalidParameter, title: "Invalid header", field: "X-Tenant"},
+
+	{err: entity.ErrCategoryHasChildren, status: http.StatusConflict},
+	{err: entity.ErrInsufficientStock, status: http.StatusConflict, typ: ProblemTypeInsufficientStock, title: "Insufficient stock"},
+	{err: entity.ErrEmailTaken, status: http.StatusConflict, typ: ProblemTypeEmailTaken, title: "Email already registered", field: "email"},
+	{err: entity.ErrLastAdmin, status: http.StatusConflict},
+	{err: entity.ErrMFAEnabled, status: http.StatusConflict},
+	{err: entity.ErrMFANotEnrolled, status: http.StatusConflict},
+
+	{err: entity.ErrUserDisabled, status: http.StatusForbidden},
+	{err: entity.ErrNotProductOwner, status: http.StatusForbidden},
+	{err: entity.ErrEmailUnverified, status: http.StatusForbidden, typ: ProblemTypeEmailUnverified, title: "Email not verified"},
+
+	{err: entity.ErrInvalidRefreshToken, status: http.StatusUnauthorized, typ: ProblemTypeInvalidToken, title: "Invalid token"},
+	{err: entity.ErrRefreshTokenExpired, status: http.StatusUnauthorized, typ: ProblemTypeInvalidToken, title: "Invalid token"},
+	{err: entity.ErrTokenRevoked, status: http.StatusUnauthorized, typ: ProblemTypeInvalidToken, title: "Invalid token"},
+	{err: entity.ErrInvalidAPIKey, status: http.StatusUnauthorized, typ: ProblemTypeInvalidToken, title: "Invalid token"},
+	{err: entity.ErrInvalidMFAChallenge, status: http.StatusUnauthorized, typ: ProblemTypeInvalidToken, title: "Invalid token"},
+	{err: entity.ErrRefreshTokenReused, status: http.StatusUnauthorized, typ: ProblemTypeTokenReused, title: "Refresh token reused"},
+}
+
+func problemFor(err error) *Problem {
+	var invalid validation.Errors
+	if errors.As(err, &invalid) {
+		p := &Problem{
+			Type:   ProblemTypeValidation,
+			Title:  "Validation failed",
+			Status: http.StatusUnprocessableEntity,
+			Detail: fmt.Sprintf("%d field(s) failed validation", len(invalid)),
+			Errors: make([]FieldError, len(invalid)),
+		}
+		for i, fieldErr := range invalid {
+			p.Errors[i] = FieldError{Field: fieldErr.Field, Message: fieldErr.Message}
+		}
+		return p
+	}
+
+	var invalidParameter *invalidParameterError
+	if errors.As(err, &invalidParameter) {
+		return &Problem{
+			Type:   ProblemTypeInvalidParameter,
+			Title:  "Invalid query parameter",
+			Status: http.StatusBadRequest,
+			Detail: invalidParameter.Error(),
+			Errors: []FieldError{{Field: invalidParameter.Param, Message: invalidParameter.Reason}},
+		}
+	}
+
+	var malformed *malformedBodyError
+	if errors.As(err, &malformed) {
+		p := &Problem{
+			Type:   ProblemTypeMalformedBody,
+			Title:  "Malformed request body",
+			Status: http.StatusBadRequest,
+			Detail: malformed.Error(),
+		}
+		if malformed.Field != "" {
+			p.Errors = []FieldError{{Field: malformed.Field, Message: malformed.Reason}}
+		}
+		return p
+	}
+
+	for _, m := range problemMappings {
+		if !errors.Is(err, m.err) {
+			continue
+		}
+
+		p := NewProblem(m.status, m.detail)
+		if p.Detail == "" {
+			p.Detail = err.Error()
+		}
+		if m.typ != "" {
+			p.Type = m.typ
+			p.Title = m.title
+		}
+		if m.field != "" {
+			if m.typ == "" {
+				p.Type = ProblemTypeValidation
+				p.Title = "Validation failed"
+			}
+			p.Errors = []FieldError{{Field: m.field, Message: err.Error()}}
+		}
+		return p
+	}
+
+	return NewProblem(http.StatusInternalServerError, "an unexpected error occurred")
+}
+
+// malformedBodyError reports a request body that is not the JSON the
+// endpoint expects.
+type malformedBodyError struct {
+	Field  string
+	Reason string
+}
+
+func (e *malformedBodyError) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("invalid request body: %s %s", e.Field, e.Reason)
+	}
+	return "invalid request body: " + e.Reason
+}
+
+// decodeJSON reads the request body into v and checks it against the
+// validate tags of its fields, returning every violation as
+// validation.Errors. Decoding errors raised by the values themselves that
+// have a problem of their own, like an invalid amount, are returned as they
+// are; everything else comes back as *malformedBodyError.
+func decodeJSON(r *http.Request, v interface{}) error {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == nil {
+		return validation.Struct(v)
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, io.EOF):
+		return &malformedBodyError{Reason: "body is empty"}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return &malformedBodyError{Reason: "body ends unexpectedly"}
+	case errors.As(err, &syntaxErr):
+		return &malformedBodyError{Reason: fmt.Sprintf("syntax error at offset %d", syntaxErr.Offset)}
+	case errors.As(err, &typeErr):
+		return &malformedBodyError{Field: typeErr.Field, Reason: fmt.Sprintf("must be %s, not %s", jsonKind(typeErr.Type), typeErr.Value)}
+	case problemFor(err).Status != http.StatusInternalServerError:
+		return err
+	}
+	return &malformedBodyError{Reason: err.Error()}
+}
+
+// jsonKind names the JSON value a Go type is decoded from.
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Ptr:
+		return jsonKind(t.Elem())
+	}
+	return "a number"
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Mazzael/go-api/internal/dto"
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/Mazzael/go-api/pkg/pagination"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func writeErrorProblem(t *testing.T, err error) (*httptest.ResponseRecorder, Problem) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/products/1", nil)
+	rec := httptest.NewRecorder()
+	WriteError(rec, req, err)
+
+	var p Problem
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&p))
+	return rec, p
+}
+
+func TestWriteErrorMapsDomainErrors(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		typ    string
+		field  string
+	}{
+		{entity.ErrNameIsRequired, http.StatusUnprocessableEntity, ProblemTypeValidation, "name"},
+		{fmt.Errorf("creating product: %w", entity.ErrInvalidPrice), http.StatusUnprocessableEntity, ProblemTypeValidation, "price"},
+		{entity.ErrCategoryNotFound, http.StatusUnprocessableEntity, ProblemTypeValidation, "category_ids"},
+		{pagination.ErrInvalidCursor, http.StatusBadRequest, ProblemTypeInvalidParameter, "cursor"},
+		{entity.ErrInsufficientStock, http.StatusConflict, ProblemTypeInsufficientStock, ""},
+		{entity.ErrCategoryHasChildren, http.StatusConflict, "about:blank", ""},
+		{entity.ErrRefreshTokenReused, http.StatusUnauthorized, ProblemTypeTokenReused, ""},
+		{entity.ErrTokenRevoked, http.StatusUnauthorized, ProblemTypeInvalidToken, ""},
+		{&invalidParameterError{Param: "q", Reason: "is required"}, http.StatusBadRequest, ProblemTypeInvalidParameter, "q"},
+		{fmt.Errorf("finding product: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, "about:blank", ""},
+		{context.Canceled, http.StatusServiceUnavailable, "about:blank", ""},
+	}
+
+	for _, tt := range tests {
+		rec, p := writeErrorProblem(t, tt.err)
+		assert.Equal(t, tt.status, rec.Code, tt.err.Error())
+		assert.Equal(t, ProblemContentType, rec.Header().Get("Content-Type"))
+		assert.Equal(t, tt.status, p.Status)
+		assert.Equal(t, tt.typ, p.Type, tt.err.Error())
+		assert.Equal(t, "/products/1", p.Instance)
+		if tt.field == "" {
+			assert.Empty(t, p.Errors)
+		} else if assert.Len(t, p.Errors, 1) {
+			assert.Equal(t, tt.field, p.Errors[0].Field)
+		}
+	}
+}
+
+func TestWriteErrorHidesUnexpectedErrors(t *testing.T) {
+	rec, p := writeErrorProblem(t, errors.New("pq: connection refused to 10.0.0.5"))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "about:blank", p.Type)
+	assert.NotContains(t, p.Detail, "10.0.0.5")
+}
+
+func TestWriteNotFoundDoesNotLeakDriverErrors(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/products/1", nil)
+	rec := httptest.NewRecorder()
+	writeNotFound(rec, req, "product", gorm.ErrRecordNotFound)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "record not found")
+	assert.Contains(t, rec.Body.String(), "product not found")
+}
+
+func TestDecodeJSONReportsMalformedBodies(t *testing.T) {
+	tests := map[string]string{
+		"":                       "",
+		`{"name": "Shirt"`:       "",
+		`{"name": }`:             "",
+		`{"name": 12}`:           "name",
+		`{"category_ids": "id"}`: "category_ids",
+	}
+
+	for body, field := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/products", strings.NewReader(body))
+		var input struct {
+			Name        string   `json:"name"`
+			CategoryIDs []string `json:"category_ids"`
+		}
+		err := decodeJSON(req, &input)
+
+		var malformed *malformedBodyError
+		if assert.ErrorAs(t, err, &malformed, body) {
+			assert.Equal(t, field, malformed.Field, body)
+		}
+		assert.Equal(t, http.StatusBadRequest, problemFor(err).Status, body)
+		assert.Equal(t, ProblemTypeMalformedBody, problemFor(err).Type, body)
+	}
+}
+
+func TestRouterFallbacksAnswerWithProblems(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NotFound(rec, httptest.NewRequest(http.MethodGet, "/nope", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, ProblemContentType, rec.Header().Get("Content-Type"))
+
+	rec = httptest.NewRecorder()
+	MethodNotAllowed(rec, httptest.NewRequest(http.MethodPatch, "/products", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, ProblemContentType, rec.Header().Get("Content-Type"))
+}
+
+func TestDecodeJSONReportsEveryInvalidField(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name": "", "email": "nope", "password": "short"}`))
+	var input dto.CreateUserInput
+	err := decodeJSON(req, &input)
+
+	rec := httptest.NewRecorder()
+	WriteError(rec, req, err)
+
+	var p Problem
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&p))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, ProblemTypeValidation, p.Type)
+	assert.Equal(t, []FieldError{
+		{Field: "name", Message: "is required"},
+		{Field: "email", Message: "must be a valid email address"},
+		{Field: "password", Message: "must be at least 8 characters"},
+	}, p.Errors)
+}
+
+func TestDecodeJSONAcceptsEveryInputDTO(t *testing.T) {
+	inputs := map[string]interface{}{
+		`{"name": "Shirt", "price": {"amount": "10.50", "currency": "USD"}, "category_ids": ["7e3ceb01-9101-4725-aeb9-b8f24eacbeec"]}`: &dto.CreateProductInput{},
+		`{"name": "Clothing", "parent_id": null}`:                             &dto.CreateCategoryInput{},
+		`{"type": "adjustment", "quantity": -2, "reason": "recount"}`:         &dto.CreateStockMovementInput{},
+		`{"name": "Ana", "email": "ana@example.com", "password": "s3cret!!"}`: &dto.CreateUserInput{},
+		`{"email": "ana@example.com", "password": "s3cret!!"}`:                &dto.UserLoginInput{},
+		`{"refresh_token": "token"}`:                                          &dto.RefreshTokenInput{},
+		`{}`:                                                                  &dto.LogoutInput{},
+	}
+
+	for body, input := range inputs {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		assert.NoError(t, decodeJSON(req, input), body)
+	}
+}
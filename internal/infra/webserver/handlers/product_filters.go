@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/infra/database"
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+)
+
+// invalidParameterError names the query parameter a listing could not use.
+type invalidParameterError struct {
+	Param  string
+	Reason string
+}
+
+func (e *invalidParameterError) Error() string {
+	return fmt.Sprintf("invalid query parameter %q: %s", e.Param, e.Reason)
+}
+
+// productFilterParams are the filters GET /products understands, written as
+// field[operator]=value.
+var productFilterParams = map[string]bool{
+	"name[contains]":  true,
+	"name[prefix]":    true,
+	"price[gte]":      true,
+	"price[lte]":      true,
+	"price[currency]": true,
+	"created_at[gte]": true,
+	"created_at[lte]": true,
+	"id[in]":          true,
+}
+
+// parseProductFilter reads the filter and sort parameters of a product
+// listing. Parameters that are not filters, like page or limit, are left
+// alone, but anything shaped like a filter must be a known one.
+func parseProductFilter(query url.Values) (database.ProductFilter, error) {
+	var filter database.ProductFilter
+
+	for param := range query {
+		if strings.Contains(param, "[") && !productFilterParams[param] {
+			return filter, &invalidParameterError{Param: param, Reason: "unknown filter"}
+		}
+	}
+
+	for param := range productFilterParams {
+		if query.Has(param) && strings.TrimSpace(query.Get(param)) == "" {
+			return filter, &invalidParameterError{Param: param, Reason: "must not be empty"}
+		}
+	}
+
+	filter.NameContains = query.Get("name[contains]")
+	filter.NamePrefix = query.Get("name[prefix]")
+
+	currency := entityPkg.DefaultCurrency
+	if query.Has("price[currency]") {
+		currency = strings.ToUpper(query.Get("price[currency]"))
+		if _, err := entityPkg.NewMoney(0, currency); err != nil {
+			return filter, &invalidParameterError{Param: "price[currency]", Reason: "unknown currency"}
+		}
+		filter.PriceCurrency = currency
+	}
+
+	var err error
+	if filter.PriceGTE, err = parsePriceParam(query, "price[gte]", currency); err != nil {
+		return filter, err
+	}
+	if filter.PriceLTE, err = parsePriceParam(query, "price[lte]", currency); err != nil {
+		return filter, err
+	}
+	if filter.PriceGTE != nil && filter.PriceLTE != nil && filter.PriceGTE.Amount > filter.PriceLTE.Amount {
+		return filter, &invalidParameterError{Param: "price[gte]", Reason: "must not be greater than price[lte]"}
+	}
+
+	if filter.CreatedAtGTE, err = parseTimeParam(query, "created_at[gte]"); err != nil {
+		return filter, err
+	}
+	if filter.CreatedAtLTE, err = parseTimeParam(query, "created_at[lte]"); err != nil {
+		return filter, err
+	}
+	if filter.CreatedAtGTE != nil && filter.CreatedAtLTE != nil && filter.CreatedAtGTE.After(*filter.CreatedAtLTE) {
+		return filter, &invalidParameterError{Param: "created_at[gte]", Reason: "must not be after created_at[lte]"}
+	}
+
+	if query.Has("id[in]") {
+		for _, id := range strings.Split(query.Get("id[in]"), ",") {
+			id = strings.TrimSpace(id)
+			if _, err := entityPkg.ParseID(id); err != nil {
+				return filter, &invalidParameterError{Param: "id[in]", Reason: fmt.Sprintf("%q is not a valid id", id)}
+			}
+			filter.IDs = append(filter.IDs, id)
+		}
+	}
+
+	filter.Sort, err = parseProductSort(query.Get("sort"))
+	if err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+func parsePriceParam(query url.Values, param, currency string) (*entityPkg.Money, error) {
+	if !query.Has(param) {
+		return nil, nil
+	}
+	price, err := entityPkg.ParseMoney(query.Get(param), currency)
+	if err != nil {
+		return nil, &invalidParameterError{Param: param, Reason: err.Error()}
+	}
+	return &price, nil
+}
+
+func parseTimeParam(query url.Values, param string) (*time.Time, error) {
+	if !query.Has(param) {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, query.Get(param))
+	if err != nil {
+		return nil, &invalidParameterError{Param: param, Reason: "must be an RFC 3339 timestamp"}
+	}
+	return &t, nil
+}
+
+// parseProductSort reads a comma separated list of fields, each optionally
+// prefixed with - for descending order, e.g. sort=-price,name. The legacy
+// values asc and desc sort by created_at.
+func parseProductSort(value string) ([]database.SortField, error) {
+	switch value {
+	case "", "asc":
+		return []database.SortField{{Field: "created_at"}}, nil
+	case "desc":
+		return []database.SortField{{Field: "created_at", Desc: true}}, nil
+	}
+
+	var sort []database.SortField
+	seen := map[string]bool{}
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		desc := strings.HasPrefix(field, "-")
+		field = strings.TrimLeft(field, "+-")
+
+		if !database.IsProductSortField(field) {
+			return nil, &invalidParameterError{Param: "sort", Reason: fmt.Sprintf("cannot sort by %q", field)}
+		}
+		if seen[field] {
+			return nil, &invalidParameterError{Param: "sort", Reason: fmt.Sprintf("%q is listed twice", field)}
+		}
+		seen[field] = true
+		sort = append(sort, database.SortField{Field: field, Desc: desc})
+	}
+	return sort, nil
+}
+
+// keysetSort returns the asc or desc order keyset paging walks created_at in,
+// which is the only order it supports.
+func keysetSort(sort []database.SortField) (string, error) {
+	if len(sort) != 1 || sort[0].Field != "created_at" {
+		return "", &invalidParameterError{Param: "sort", Reason: "cursor pagination can only sort by created_at"}
+	}
+	if sort[0].Desc {
+		return "desc", nil
+	}
+	return "asc", nil
+}
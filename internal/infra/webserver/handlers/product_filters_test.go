@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/infra/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProductFilter(t *testing.T) {
+	query, _ := url.ParseQuery("name[contains]=shirt&price[gte]=10.50&price[lte]=20&created_at[gte]=2026-10-01T00:00:00Z" +
+		"&id[in]=7e3ceb01-9101-4725-aeb9-b8f24eacbeec,3029c033-1cee-496f-af5c-53beb1af729d&sort=-price,name&page=2")
+
+	filter, err := parseProductFilter(query)
+	assert.NoError(t, err)
+	assert.Equal(t, "shirt", filter.NameContains)
+	assert.Equal(t, int64(1050), filter.PriceGTE.Amount)
+	assert.Equal(t, "USD", filter.PriceGTE.Currency)
+	assert.Equal(t, int64(2000), filter.PriceLTE.Amount)
+	assert.True(t, filter.CreatedAtGTE.Equal(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Nil(t, filter.CreatedAtLTE)
+	assert.Len(t, filter.IDs, 2)
+	assert.Equal(t, []database.SortField{{Field: "price", Desc: true}, {Field: "name"}}, filter.Sort)
+}
+
+func TestParseProductFilterNamesTheBadParameter(t *testing.T) {
+	tests := map[string]string{
+		"price[gte]=abc":                  "price[gte]",
+		"price[lte]=1.001":                "price[lte]",
+		"price[gte]=5&price[lte]=1":       "price[gte]",
+		"price[currency]=XXX":             "price[currency]",
+		"created_at[lte]=yesterday":       "created_at[lte]",
+		"id[in]=1,2":                      "id[in]",
+		"name[contains]=":                 "name[contains]",
+		"name[regex]=.*":                  "name[regex]",
+		"price[neq]=1":                    "price[neq]",
+		"sort=password":                   "sort",
+		"sort=name%3BDROP+TABLE+products": "sort",
+		"sort=name,-name":                 "sort",
+	}
+
+	for raw, param := range tests {
+		query, _ := url.ParseQuery(raw)
+		_, err := parseProductFilter(query)
+
+		var invalid *invalidParameterError
+		if assert.ErrorAs(t, err, &invalid, raw) {
+			assert.Equal(t, param, invalid.Param, raw)
+			assert.Contains(t, err.Error(), `"`+param+`"`)
+		}
+	}
+}
+
+func TestParseProductSortKeepsLegacyValues(t *testing.T) {
+	for value, want := range map[string][]database.SortField{
+		"":     {{Field: "created_at"}},
+		"asc":  {{Field: "created_at"}},
+		"desc": {{Field: "created_at", Desc: true}},
+	} {
+		sort, err := parseProductSort(value)
+		assert.NoError(t, err)
+		assert.Equal(t, want, sort)
+	}
+}
+
+func TestKeysetSortOnlyAcceptsCreatedAt(t *testing.T) {
+	sort, err := keysetSort([]database.SortField{{Field: "created_at", Desc: true}})
+	assert.NoError(t, err)
+	assert.Equal(t, "desc", sort)
+
+	_, err = keysetSort([]database.SortField{{Field: "price"}})
+	assert.Error(t, err)
+}
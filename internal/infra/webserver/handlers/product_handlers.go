@@ -2,58 +2,102 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/Mazzael/go-api/internal/dto"
 	"github.com/Mazzael/go-api/internal/entity"
 	"github.com/Mazzael/go-api/internal/infra/database"
 	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"github.com/Mazzael/go-api/pkg/pagination"
 	"github.com/go-chi/chi/v5"
 )
 
 type ProductHandler struct {
-	GormProductRepository database.ProductRepository
+	GormProductRepository  database.ProductRepository
+	GormCategoryRepository database.CategoryRepository
+	Cursors                *pagination.Signer
+	// OwnerOnly lets users change and delete only the products they
+	// created, unless their role grants products:manage_any.
+	OwnerOnly bool
+	Events    Events
 }
 
-func NewProductHandler(repo database.ProductRepository) *ProductHandler {
+// ProductPage is the GET /products body in keyset mode.
+type ProductPage struct {
+	Data       []*entity.Product `json:"data"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+	PrevCursor string            `json:"prev_cursor,omitempty"`
+}
+
+// ProductSearchHit is one result of GET /products/search.
+type ProductSearchHit struct {
+	Product *entity.Product `json:"product"`
+	Rank    float64         `json:"rank" example:"1.52"`
+	Snippet string          `json:"snippet" example:"Blue <mark>Shirt</mark>"`
+}
+
+func NewProductHandler(repo database.ProductRepository, categoryRepo database.CategoryRepository, cursors *pagination.Signer, ownerOnly bool) *ProductHandler {
 	return &ProductHandler{
-		GormProductRepository: repo,
+		GormProductRepository:  repo,
+		GormCategoryRepository: categoryRepo,
+		Cursors:                cursors,
+		OwnerOnly:              ownerOnly,
+		Events:                 NoEvents{},
 	}
 }
 
 // Create Product godoc
 // @Summary      Create product
-// @Description  Create products
+// @Description  Create products. The user of the access token is recorded as created_by and updated_by.
 // @Tags         products
 // @Accept       json
 // @Produce      json
 // @Param        request     body      dto.CreateProductInput  true  "product request"
 // @Success      201
-// @Failure      500         {object}  Error
+// @Failure      400         {object}  Problem
+// @Failure      422         {object}  Problem
+// @Failure      403         {object}  Problem
+// @Failure      500         {object}  Problem
 // @Router       /products [post]
 // @Security ApiKeyAuth
+// @Security PersonalAPIKey
 func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 	var product dto.CreateProductInput
 
-	err := json.NewDecoder(r.Body).Decode(&product)
+	err := decodeJSON(r, &product)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		WriteError(w, r, err)
 		return
 	}
 
 	p, err := entity.NewProduct(product.Name, product.Price)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		WriteError(w, r, err)
 		return
 	}
+	if userID, ok := subjectOf(r); ok {
+		p.CreatedBy = &userID
+		p.UpdatedBy = &userID
+	}
 
-	err = h.GormProductRepository.Create(p)
+	if len(product.CategoryIDs) > 0 {
+		p.Categories, err = h.GormCategoryRepository.FindByIDs(r.Context(), product.CategoryIDs)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+	}
+
+	err = h.GormProductRepository.Create(r.Context(), p)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, r, err)
 		return
 	}
 
+	h.Events.ProductCreated()
 	w.WriteHeader(http.StatusCreated)
 }
 
@@ -65,25 +109,23 @@ func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 // @Produce      json
 // @Param        id   path      string  true  "product ID" Format(uuid)
 // @Success      200  {object}  entity.Product
-// @Failure      400  {object}  Error
-// @Failure      404  {object}  Error
-// @Failure      500  {object}  Error
+// @Failure      400  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      500  {object}  Problem
 // @Router       /products/{id} [get]
 // @Security ApiKeyAuth
+// @Security PersonalAPIKey
 func (h *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		err := Error{Message: "Product ID is required"}
-		json.NewEncoder(w).Encode(err)
+		WriteProblem(w, r, NewProblem(http.StatusBadRequest, "product id is required"))
 		return
 	}
 
-	product, err := h.GormProductRepository.FindByID(id)
+	product, err := h.GormProductRepository.FindByID(r.Context(), id)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		err := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(err)
+		writeNotFound(w, r, "product", err)
 		return
 	}
 
@@ -93,17 +135,39 @@ func (h *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
 
 // List Products godoc
 // @Summary      List products
-// @Description  get all products
+// @Description  get all products. By default products are paged by offset with page and limit and
+// @Description  returned as an array. Passing pagination=cursor, or a cursor from a previous page,
+// @Description  switches to keyset paging: the response becomes {"data": [...], "next_cursor": "...",
+// @Description  "prev_cursor": "..."} and the same cursors are sent as RFC 8288 Link headers.
+// @Description  Cursor paging only sorts by created_at. Unknown or malformed filters answer 400.
 // @Tags         products
 // @Accept       json
 // @Produce      json
 // @Param        page      query     string  false  "page number"
 // @Param        limit     query     string  false  "limit"
+// @Param        sort      query     string  false  "comma separated fields to sort by, prefixed with - for descending: id, name, price, created_at. asc and desc sort by created_at"
+// @Param        name[contains]       query  string  false  "name contains this text, ignoring case"
+// @Param        name[prefix]         query  string  false  "name starts with this text, ignoring case"
+// @Param        price[gte]           query  string  false  "minimum price"  example(10.00)
+// @Param        price[lte]           query  string  false  "maximum price"  example(99.99)
+// @Param        price[currency]      query  string  false  "currency of the price filters, defaults to USD"
+// @Param        created_at[gte]      query  string  false  "created at or after"   Format(date-time)
+// @Param        created_at[lte]      query  string  false  "created at or before"  Format(date-time)
+// @Param        id[in]               query  string  false  "comma separated product ids"
+// @Param        pagination           query  string  false  "set to cursor to start keyset paging"  Enums(offset, cursor)
+// @Param        cursor               query  string  false  "next_cursor or prev_cursor of a previous page"
+// @Param        category_id          query  string  false  "only products in this category" Format(uuid)
+// @Param        include_descendants  query  bool    false  "also match products in categories nested below category_id"
+// @Param        owner                query  string  false  "only products created by this user id, or by the current user with me"
 // @Success      200       {array}   entity.Product
-// @Failure      404       {object}  Error
-// @Failure      500       {object}  Error
+// @Header       200       {string}  Link  "next and prev page links when keyset paging"
+// @Failure      400       {object}  Problem
+// @Failure      404       {object}  Problem
+// @Failure      403       {object}  Problem
+// @Failure      500       {object}  Problem
 // @Router       /products [get]
 // @Security ApiKeyAuth
+// @Security PersonalAPIKey
 func (h *ProductHandler) GetProducts(w http.ResponseWriter, r *http.Request) {
 	page := r.URL.Query().Get("page")
 	if page == "" {
@@ -125,74 +189,252 @@ func (h *ProductHandler) GetProducts(w http.ResponseWriter, r *http.Request) {
 		limitInt = 10
 	}
 
-	sort := r.URL.Query().Get("sort")
-
-	products, err := h.GormProductRepository.FindAll(pageInt, limitInt, sort)
+	filter, err := parseProductFilter(r.URL.Query())
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, r, err)
 		return
 	}
 
-	println(pageInt, limitInt, sort)
+	if r.URL.Query().Has("owner") {
+		filter.CreatedBy, err = ownerParam(r)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+	}
+
+	categoryID := r.URL.Query().Get("category_id")
+	if categoryID != "" {
+		filter.CategoryIDs = []string{categoryID}
+		if r.URL.Query().Get("include_descendants") == "true" {
+			filter.CategoryIDs, err = h.GormCategoryRepository.FindDescendantIDs(r.Context(), categoryID)
+			if err != nil {
+				WriteError(w, r, err)
+				return
+			}
+		}
+	}
+
+	if r.URL.Query().Has("cursor") || r.URL.Query().Get("pagination") == "cursor" {
+		h.getProductsPage(w, r, filter, limitInt)
+		return
+	}
+
+	products, err := h.GormProductRepository.FindAllMatching(r.Context(), filter, pageInt, limitInt)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(products)
 }
 
+// getProductsPage answers GetProducts in keyset mode.
+func (h *ProductHandler) getProductsPage(w http.ResponseWriter, r *http.Request, filter database.ProductFilter, limit int) {
+	if limit < 1 {
+		limit = 10
+	}
+
+	var cursor *pagination.Cursor
+	var sort string
+	if token := r.URL.Query().Get("cursor"); token != "" {
+		decoded, err := h.Cursors.Decode(token)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		cursor = &decoded
+		sort = cursor.Sort
+	} else {
+		var err error
+		sort, err = keysetSort(filter.Sort)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+	}
+
+	page, err := h.GormProductRepository.FindPage(r.Context(), filter, cursor, limit, sort)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	output := ProductPage{Data: page.Products}
+	if output.Data == nil {
+		output.Data = []*entity.Product{}
+	}
+
+	if n := len(page.Products); n > 0 {
+		if page.HasNext {
+			output.NextCursor, err = h.pageCursor(page.Products[n-1], pagination.Next, sort)
+			if err != nil {
+				WriteError(w, r, err)
+				return
+			}
+			w.Header().Add("Link", pageLink(r, output.NextCursor, "next"))
+		}
+		if page.HasPrev {
+			output.PrevCursor, err = h.pageCursor(page.Products[0], pagination.Prev, sort)
+			if err != nil {
+				WriteError(w, r, err)
+				return
+			}
+			w.Header().Add("Link", pageLink(r, output.PrevCursor, "prev"))
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(output)
+}
+
+func (h *ProductHandler) pageCursor(product *entity.Product, direction pagination.Direction, sort string) (string, error) {
+	return h.Cursors.Encode(pagination.Cursor{
+		CreatedAt: product.CreatedAt,
+		ID:        product.ID.String(),
+		Direction: direction,
+		Sort:      sort,
+	})
+}
+
+// pageLink builds an RFC 8288 link to the same listing at cursor.
+func pageLink(r *http.Request, cursor, rel string) string {
+	link := *r.URL
+	query := link.Query()
+	query.Del("page")
+	query.Del("pagination")
+	query.Set("cursor", cursor)
+	link.RawQuery = query.Encode()
+	return fmt.Sprintf(`<%s>; rel="%s"`, link.RequestURI(), rel)
+}
+
+// SearchProducts godoc
+// @Summary      Search products
+// @Description  full-text search on product names, best matches first. Every word of q must
+// @Description  match the start of a word in the name. The snippet is the name as escaped HTML, with matches in <mark> tags.
+// @Tags         products
+// @Accept       json
+// @Produce      json
+// @Param        q         query     string  true   "search text"
+// @Param        page      query     string  false  "page number"
+// @Param        limit     query     string  false  "limit"
+// @Success      200       {array}   ProductSearchHit
+// @Failure      400       {object}  Problem
+// @Failure      403       {object}  Problem
+// @Failure      500       {object}  Problem
+// @Router       /products/search [get]
+// @Security ApiKeyAuth
+// @Security PersonalAPIKey
+func (h *ProductHandler) SearchProducts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	if strings.TrimSpace(q) == "" {
+		WriteError(w, r, &invalidParameterError{Param: "q", Reason: "must not be empty"})
+		return
+	}
+
+	pageInt, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || pageInt < 0 {
+		pageInt = 0
+	}
+
+	limitInt, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limitInt < 1 {
+		limitInt = 10
+	}
+
+	results, err := h.GormProductRepository.Search(r.Context(), q, pageInt, limitInt)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	hits := make([]ProductSearchHit, len(results))
+	for i, result := range results {
+		hits[i] = ProductSearchHit{Product: result.Product, Rank: result.Rank, Snippet: result.Snippet}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(hits)
+}
+
 // UpdateProduct godoc
 // @Summary      Update a product
-// @Description  Update a product
+// @Description  Update a product. The user of the access token is recorded as updated_by. When products are
+// @Description  owner-only, only the user who created it may, unless their role grants products:manage_any.
 // @Tags         products
 // @Accept       json
 // @Produce      json
 // @Param        id        	path      string                  true  "product ID" Format(uuid)
 // @Param        request     body      dto.CreateProductInput  true  "product request"
 // @Success      200
-// @Failure      400       {object}  Error
-// @Failure      404	   {object}  Error
-// @Failure      500       {object}  Error
+// @Failure      400       {object}  Problem
+// @Failure      422       {object}  Problem
+// @Failure      404	   {object}  Problem
+// @Failure      403       {object}  Problem
+// @Failure      500       {object}  Problem
 // @Router       /products/{id} [put]
 // @Security ApiKeyAuth
+// @Security PersonalAPIKey
 func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		err := Error{Message: "Product ID is required"}
-		json.NewEncoder(w).Encode(err)
+		WriteProblem(w, r, NewProblem(http.StatusBadRequest, "product id is required"))
 		return
 	}
 
-	var product entity.Product
+	// a malformed id names no product, as on GET and DELETE.
+	productID, err := entityPkg.ParseID(id)
+	if err != nil {
+		WriteProblem(w, r, NewProblem(http.StatusNotFound, "product not found"))
+		return
+	}
 
-	err := json.NewDecoder(r.Body).Decode(&product)
+	var input dto.CreateProductInput
+
+	err = decodeJSON(r, &input)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		err := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(err)
+		WriteError(w, r, err)
 		return
 	}
 
-	product.ID, err = entityPkg.ParseID(id)
+	product, err := entity.NewProduct(input.Name, input.Price)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		err := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(err)
+		WriteError(w, r, err)
 		return
 	}
+	product.ID = productID
 
-	_, err = h.GormProductRepository.FindByID(id)
+	existing, err := h.GormProductRepository.FindByID(r.Context(), id)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		err := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(err)
+		writeNotFound(w, r, "product", err)
+		return
+	}
+	if err := h.checkOwner(r, existing); err != nil {
+		WriteError(w, r, err)
 		return
 	}
 
-	err = h.GormProductRepository.Update(&product)
+	// who and when created it is kept, whatever the body says.
+	product.CreatedAt = existing.CreatedAt
+	product.CreatedBy = existing.CreatedBy
+	product.UpdatedBy = nil
+	if userID, ok := subjectOf(r); ok {
+		product.UpdatedBy = &userID
+	}
+
+	// the categories are left alone unless category_ids is given.
+	if input.CategoryIDs != nil {
+		product.Categories, err = h.GormCategoryRepository.FindByIDs(r.Context(), input.CategoryIDs)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+	}
+
+	err = h.GormProductRepository.Update(r.Context(), product)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		err := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(err)
+		WriteError(w, r, err)
 		return
 	}
 
@@ -201,41 +443,72 @@ func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 
 // DeleteProduct godoc
 // @Summary      Delete a product
-// @Description  Delete a product
+// @Description  Delete a product. When products are owner-only, only the user who created it may, unless their
+// @Description  role grants products:manage_any.
 // @Tags         products
 // @Accept       json
 // @Produce      json
 // @Param        id        path      string                  true  "product ID" Format(uuid)
 // @Success      200
-// @Failure      400	   {object}  Error
-// @Failure      404       {object}  Error
-// @Failure      500       {object}  Error
+// @Failure      400	   {object}  Problem
+// @Failure      404       {object}  Problem
+// @Failure      403       {object}  Problem
+// @Failure      500       {object}  Problem
 // @Router       /products/{id} [delete]
 // @Security ApiKeyAuth
+// @Security PersonalAPIKey
 func (h *ProductHandler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		err := Error{Message: "Product ID is required"}
-		json.NewEncoder(w).Encode(err)
+		WriteProblem(w, r, NewProblem(http.StatusBadRequest, "product id is required"))
 		return
 	}
 
-	_, err := h.GormProductRepository.FindByID(id)
+	product, err := h.GormProductRepository.FindByID(r.Context(), id)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		err := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(err)
+		writeNotFound(w, r, "product", err)
+		return
+	}
+	if err := h.checkOwner(r, product); err != nil {
+		WriteError(w, r, err)
 		return
 	}
 
-	err = h.GormProductRepository.Delete(id)
+	err = h.GormProductRepository.Delete(r.Context(), id)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		err := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(err)
+		WriteError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// checkOwner refuses changes to product by anyone but the user who created
+// it when products are owner-only. Roles granting products:manage_any may
+// change any product, including those created before owners were recorded.
+func (h *ProductHandler) checkOwner(r *http.Request, product *entity.Product) error {
+	if !h.OwnerOnly || tokenGrants(r, entity.PermissionProductsManageAny) {
+		return nil
+	}
+	if userID, ok := subjectOf(r); ok && product.IsOwnedBy(userID) {
+		return nil
+	}
+	return entity.ErrNotProductOwner
+}
+
+// ownerParam reads the owner filter of a product listing: a user id, or me
+// for the user of the access token.
+func ownerParam(r *http.Request) (string, error) {
+	owner := r.URL.Query().Get("owner")
+	if owner == "me" {
+		userID, ok := subjectOf(r)
+		if !ok {
+			return "", &invalidParameterError{Param: "owner", Reason: "me requires an access token"}
+		}
+		return userID.String(), nil
+	}
+	if _, err := entityPkg.ParseID(owner); err != nil {
+		return "", &invalidParameterError{Param: "owner", Reason: "must be me or a user id"}
+	}
+	return owner, nil
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/Mazzael/go-api/internal/infra/database"
+	"github.com/Mazzael/go-api/internal/infra/database/migrations"
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"github.com/Mazzael/go-api/pkg/pagination"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type productTestServer struct {
+	*httptest.Server
+	tokenAuth *jwtauth.JWTAuth
+	events    *recordingEvents
+}
+
+func newProductTestServer(t *testing.T, ownerOnly bool) *productTestServer {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrations.NewMigrator(db).Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewProductHandler(database.NewProduct(db), database.NewCategory(db), pagination.NewSigner([]byte("secret")), ownerOnly)
+	events := &recordingEvents{counts: map[string]int{}}
+	handler.Events = events
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+
+	r := chi.NewRouter()
+	r.Use(jwtauth.Verifier(tokenAuth))
+	r.Use(jwtauth.Authenticator)
+	r.Use(withTenant(entityPkg.NewID()))
+	r.Post("/products", handler.CreateProduct)
+	r.Get("/products", handler.GetProducts)
+	r.Get("/products/{id}", handler.GetProduct)
+	r.Put("/products/{id}", handler.UpdateProduct)
+	r.Delete("/products/{id}", handler.DeleteProduct)
+
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return &productTestServer{Server: server, tokenAuth: tokenAuth, events: events}
+}
+
+// token signs an access token for a new user with role and returns it with
+// the user id.
+func (s *productTestServer) token(role entity.Role) (string, entityPkg.ID) {
+	id := entityPkg.NewID()
+	_, token, _ := s.tokenAuth.Encode(map[string]interface{}{
+		"sub":  id.String(),
+		"role": string(role),
+		"exp":  time.Now().Add(time.Minute).Unix(),
+	})
+	return token, id
+}
+
+func (s *productTestServer) do(t *testing.T, method, path, token, body string) *http.Response {
+	req, _ := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+	return res
+}
+
+func (s *productTestServer) list(t *testing.T, token, query string) []*entity.Product {
+	res := s.do(t, http.MethodGet, "/products?"+query, token, "")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var products []*entity.Product
+	json.NewDecoder(res.Body).Decode(&products)
+	return products
+}
+
+// create adds a product as the user of token and returns it.
+func (s *productTestServer) create(t *testing.T, token, name string) *entity.Product {
+	res := s.do(t, http.MethodPost, "/products", token, `{"name": "`+name+`", "price": "10.00"}`)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	for _, product := range s.list(t, token, "name[prefix]="+name) {
+		if product.Name == name {
+			return product
+		}
+	}
+	t.Fatalf("product %s not found", name)
+	return nil
+}
+
+func TestProductsRecordWhoCreatedAndUpdatedThem(t *testing.T) {
+	s := newProductTestServer(t, false)
+	ana, anaID := s.token(entity.RoleEditor)
+	bia, biaID := s.token(entity.RoleEditor)
+
+	product := s.create(t, ana, "Shirt")
+	assert.Equal(t, 1, s.events.count("product created"))
+	assert.Equal(t, anaID, *product.CreatedBy)
+	assert.Equal(t, anaID, *product.UpdatedBy)
+
+	// created_by in the body is ignored.
+	res := s.do(t, http.MethodPut, "/products/"+product.ID.String(), bia,
+		`{"name": "Blue Shirt", "price": "12.00", "created_by": "`+biaID.String()+`"}`)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	res = s.do(t, http.MethodGet, "/products/"+product.ID.String(), ana, "")
+	var updated entity.Product
+	json.NewDecoder(res.Body).Decode(&updated)
+	assert.Equal(t, "Blue Shirt", updated.Name)
+	assert.Equal(t, anaID, *updated.CreatedBy)
+	assert.Equal(t, biaID, *updated.UpdatedBy)
+	assert.True(t, product.CreatedAt.Equal(updated.CreatedAt))
+}
+
+func TestUpdateProductValidatesTheBody(t *testing.T) {
+	s := newProductTestServer(t, false)
+	ana, _ := s.token(entity.RoleEditor)
+	product := s.create(t, ana, "Shirt")
+	path := "/products/" + product.ID.String()
+
+	for body, field := range map[string]string{
+		`{"name": ""}`:                   "name",
+		`{"name": "", "price": "12.00"}`: "name",
+		`{"name": "Blue Shirt"}`:         "price",
+		`{"name": "Blue Shirt", "price": "12.00", "category_ids": ["x"]}`: "category_ids[0]",
+	} {
+		res := s.do(t, http.MethodPut, path, ana, body)
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode, body)
+		var p Problem
+		json.NewDecoder(res.Body).Decode(&p)
+		if assert.NotEmpty(t, p.Errors, body) {
+			assert.Equal(t, field, p.Errors[0].Field, body)
+		}
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+		res := s.do(t, method, "/products/not-a-uuid", ana, `{"name": "Blue Shirt", "price": "12.00"}`)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode, method)
+	}
+
+	res := s.do(t, http.MethodGet, path, ana, "")
+	var unchanged entity.Product
+	json.NewDecoder(res.Body).Decode(&unchanged)
+	assert.Equal(t, "Shirt", unchanged.Name)
+	assert.Equal(t, "10.00 USD", unchanged.Price.String())
+}
+
+func TestListProductsOfAnOwner(t *testing.T) {
+	s := newProductTestServer(t, false)
+	ana, anaID := s.token(entity.RoleEditor)
+	bia, _ := s.token(entity.RoleEditor)
+	s.create(t, ana, "Shirt")
+	s.create(t, bia, "Hat")
+
+	mine := s.list(t, ana, "owner=me")
+	assert.Len(t, mine, 1)
+	assert.Equal(t, "Shirt", mine[0].Name)
+	assert.Len(t, s.list(t, bia, "owner="+anaID.String()), 1)
+	assert.Len(t, s.list(t, bia, ""), 2)
+
+	res := s.do(t, http.MethodGet, "/products?owner=someone", ana, "")
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	var p Problem
+	json.NewDecoder(res.Body).Decode(&p)
+	assert.Equal(t, "owner", p.Errors[0].Field)
+}
+
+func TestOwnerOnlyProducts(t *testing.T) {
+	s := newProductTestServer(t, true)
+	ana, _ := s.token(entity.RoleEditor)
+	bia, _ := s.token(entity.RoleEditor)
+	admin, _ := s.token(entity.RoleAdmin)
+
+	product := s.create(t, ana, "Shirt")
+	path := "/products/" + product.ID.String()
+
+	res := s.do(t, http.MethodPut, path, bia, `{"name": "Hat", "price": "12.00"}`)
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+	res = s.do(t, http.MethodDelete, path, bia, "")
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+	res = s.do(t, http.MethodPut, path, ana, `{"name": "Blue Shirt", "price": "12.00"}`)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	res = s.do(t, http.MethodPut, path, admin, `{"name": "Red Shirt", "price": "12.00"}`)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	res = s.do(t, http.MethodDelete, path, ana, "")
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+}
+
+func TestOwnerOnlyProductsLimitAPIKeysToTheirScopes(t *testing.T) {
+	s := newProductTestServer(t, true)
+	ana, _ := s.token(entity.RoleEditor)
+	product := s.create(t, ana, "Shirt")
+
+	// an API key of an admin created without products:manage_any.
+	_, key, _ := s.tokenAuth.Encode(map[string]interface{}{
+		"sub":    entityPkg.NewID().String(),
+		"role":   string(entity.RoleAdmin),
+		"scopes": []string{string(entity.PermissionProductsWrite)},
+	})
+	res := s.do(t, http.MethodPut, "/products/"+product.ID.String(), key, `{"name": "Hat", "price": "12.00"}`)
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+}
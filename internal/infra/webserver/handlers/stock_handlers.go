@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/Mazzael/go-api/internal/dto"
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/Mazzael/go-api/internal/infra/database"
+	"github.com/go-chi/chi/v5"
+)
+
+type StockHandler struct {
+	GormProductRepository database.ProductRepository
+	GormStockRepository   database.StockRepository
+}
+
+func NewStockHandler(productRepo database.ProductRepository, stockRepo database.StockRepository) *StockHandler {
+	return &StockHandler{
+		GormProductRepository: productRepo,
+		GormStockRepository:   stockRepo,
+	}
+}
+
+// GetStock godoc
+// @Summary      Get product stock
+// @Description  Get the on-hand, reserved and available quantity of a product
+// @Tags         stock
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "product ID" Format(uuid)
+// @Success      200  {object}  entity.StockLevel
+// @Failure      400  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /products/{id}/stock [get]
+// @Security ApiKeyAuth
+// @Security PersonalAPIKey
+func (h *StockHandler) GetStock(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		WriteProblem(w, r, NewProblem(http.StatusBadRequest, "product id is required"))
+		return
+	}
+
+	_, err := h.GormProductRepository.FindByID(r.Context(), id)
+	if err != nil {
+		writeNotFound(w, r, "product", err)
+		return
+	}
+
+	level, err := h.GormStockRepository.FindLevel(r.Context(), id)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(level)
+}
+
+// CreateStockMovement godoc
+// @Summary      Adjust product stock
+// @Description  Record a stock movement in the ledger and return the new stock level. Quantity is positive for every type except adjustment, which takes a signed correction.
+// @Tags         stock
+// @Accept       json
+// @Produce      json
+// @Param        id          path      string                        true  "product ID" Format(uuid)
+// @Param        request     body      dto.CreateStockMovementInput  true  "stock movement"
+// @Success      201         {object}  entity.StockLevel
+// @Failure      400         {object}  Problem
+// @Failure      422         {object}  Problem
+// @Failure      404         {object}  Problem
+// @Failure      409         {object}  Problem
+// @Failure      403         {object}  Problem
+// @Failure      500         {object}  Problem
+// @Router       /products/{id}/stock/movements [post]
+// @Security ApiKeyAuth
+// @Security PersonalAPIKey
+func (h *StockHandler) CreateStockMovement(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		WriteProblem(w, r, NewProblem(http.StatusBadRequest, "product id is required"))
+		return
+	}
+
+	var input dto.CreateStockMovementInput
+
+	err := decodeJSON(r, &input)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	product, err := h.GormProductRepository.FindByID(r.Context(), id)
+	if err != nil {
+		writeNotFound(w, r, "product", err)
+		return
+	}
+
+	movement, err := entity.NewStockMovement(product.ID, entity.MovementType(input.Type), input.Quantity, input.Reason)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	level, err := h.GormStockRepository.Apply(r.Context(), movement)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(level)
+}
+
+// GetStockMovements godoc
+// @Summary      List stock movements
+// @Description  List the stock ledger of a product, newest first
+// @Tags         stock
+// @Accept       json
+// @Produce      json
+// @Param        id        path      string  true   "product ID" Format(uuid)
+// @Param        page      query     string  false  "page number"
+// @Param        limit     query     string  false  "limit"
+// @Success      200       {array}   entity.StockMovement
+// @Failure      400       {object}  Problem
+// @Failure      404       {object}  Problem
+// @Failure      403       {object}  Problem
+// @Failure      500       {object}  Problem
+// @Router       /products/{id}/stock/movements [get]
+// @Security ApiKeyAuth
+// @Security PersonalAPIKey
+func (h *StockHandler) GetStockMovements(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		WriteProblem(w, r, NewProblem(http.StatusBadRequest, "product id is required"))
+		return
+	}
+
+	_, err := h.GormProductRepository.FindByID(r.Context(), id)
+	if err != nil {
+		writeNotFound(w, r, "product", err)
+		return
+	}
+
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil {
+		page = 0
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil {
+		limit = 10
+	}
+
+	movements, err := h.GormStockRepository.FindMovements(r.Context(), id, page, limit)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(movements)
+}
@@ -1,121 +1,897 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/Mazzael/go-api/internal/dto"
 	"github.com/Mazzael/go-api/internal/entity"
 	"github.com/Mazzael/go-api/internal/infra/database"
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/jwtauth"
+	"github.com/lestrrat-go/jwx/jwt"
+	"gorm.io/gorm"
 )
 
-type Error struct {
-	Message string `json:"message"`
+// TokenEncoder signs access tokens. The "token" context value holds one,
+// either a *jwtauth.JWTAuth or a *jwtkeys.Keyring.
+type TokenEncoder interface {
+	Encode(claims map[string]interface{}) (jwt.Token, string, error)
 }
 
 type UserHandler struct {
-	GormUserRepository database.UserRepository
+	GormUserRepository         database.UserRepository
+	GormRefreshTokenRepository database.RefreshTokenRepository
+	GormRevokedTokenRepository database.RevokedTokenRepository
+	LoginThrottle              *LoginThrottle
+	AccountMailer              *AccountMailer
+	MFA                        *MFA
+	Events                     Events
 }
 
-func NewUserHandler(repo database.UserRepository) *UserHandler {
+func NewUserHandler(repo database.UserRepository, refreshTokenRepo database.RefreshTokenRepository, revokedTokenRepo database.RevokedTokenRepository, loginThrottle *LoginThrottle, accountMailer *AccountMailer, mfa *MFA) *UserHandler {
 	return &UserHandler{
-		GormUserRepository: repo}
+		GormUserRepository:         repo,
+		GormRefreshTokenRepository: refreshTokenRepo,
+		GormRevokedTokenRepository: revokedTokenRepo,
+		LoginThrottle:              loginThrottle,
+		AccountMailer:              accountMailer,
+		MFA:                        mfa,
+		Events:                     NoEvents{},
+	}
 }
 
 // Login godoc
 // @Summary      User login
-// @Description  User login
+// @Description  User login. Unknown emails and wrong passwords get the same 401. Failed attempts are counted
+// @Description  per account and per client IP: after a few of them further attempts are refused with 429 and
+// @Description  a Retry-After header for a growing delay, and too many lock the account out for a while.
+// @Description  Users with two-factor authentication get a 202 with a challenge token instead, to send to
+// @Description  /users/auth/mfa together with a code.
 // @Tags         users
 // @Accept       json
 // @Produce      json
 // @Param        request   body     dto.UserLoginInput  true  "user credentials"
+// @Param        X-Tenant  header   string              false  "tenant slug, the default tenant when missing"
 // @Success      200  {object}  dto.UserLoginOutput
-// @Failure      401
-// @Failure      404  {object}  Error
-// @Failure      500  {object}  Error
+// @Success      202  {object}  dto.MFAChallengeOutput
+// @Failure      400  {object}  Problem
+// @Failure      422  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      429  {object}  Problem
+// @Header       429  {integer}  Retry-After  "seconds until the next attempt is accepted"
+// @Failure      500  {object}  Problem
 // @Router       /users/auth [post]
 func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
-	jwt := r.Context().Value("token").(*jwtauth.JWTAuth)
-	jwtExpiresIn := r.Context().Value("JwtExpiresIn").(int)
-
 	var user dto.UserLoginInput
 
-	err := json.NewDecoder(r.Body).Decode(&user)
+	err := decodeJSON(r, &user)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		WriteError(w, r, err)
+		return
+	}
+
+	now := time.Now()
+	tenantID, _ := database.TenantFromContext(r.Context())
+	if h.LoginThrottle != nil {
+		wait, err := h.LoginThrottle.retryAfter(r, tenantID, user.Email, now)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		if wait > 0 {
+			h.Events.Login(LoginThrottled)
+			writeThrottled(w, r, wait)
+			return
+		}
+	}
+
+	u, err := h.GormUserRepository.FindByEmail(r.Context(), user.Email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		WriteError(w, r, err)
 		return
 	}
 
-	u, err := h.GormUserRepository.FindByEmail(user.Email)
+	if u == nil {
+		dummyUser().ValidatePassword(user.Password)
+	}
+	if u == nil || !u.ValidatePassword(user.Password) {
+		if h.LoginThrottle != nil {
+			if err := h.LoginThrottle.fail(r, tenantID, user.Email, now); err != nil {
+				WriteError(w, r, err)
+				return
+			}
+		}
+		h.Events.Login(LoginFailed)
+		WriteProblem(w, r, NewProblem(http.StatusUnauthorized, "invalid email or password"))
+		return
+	}
+
+	if u.Disabled {
+		h.Events.Login(LoginFailed)
+		WriteError(w, r, entity.ErrUserDisabled)
+		return
+	}
+	// failures are only forgotten once the second factor passes too, or
+	// logging in again would reset the count of wrong codes.
+	if u.HasMFA() {
+		h.writeMFAChallenge(w, r, u)
+		return
+	}
+	if h.LoginThrottle != nil {
+		if err := h.LoginThrottle.reset(r.Context(), tenantID, user.Email); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+	}
+
+	refreshToken, plainRefreshToken, err := entity.NewRefreshToken(u.ID, entityPkg.ID{}, refreshTokenTTL(r))
+	if err == nil {
+		err = h.GormRefreshTokenRepository.Create(r.Context(), refreshToken)
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		err := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(err)
+		WriteError(w, r, err)
+		return
+	}
+
+	h.Events.Login(LoginSucceeded)
+	h.writeTokens(w, r, u, plainRefreshToken)
+}
+
+// Refresh godoc
+// @Summary      Refresh access token
+// @Description  Exchange a refresh token for a new access token and a new refresh token. Each refresh token can be used once; reusing one revokes every token issued from the same login.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request   body     dto.RefreshTokenInput  true  "refresh token"
+// @Success      200  {object}  dto.UserLoginOutput
+// @Failure      400  {object}  Problem
+// @Failure      422  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /users/auth/refresh [post]
+func (h *UserHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var input dto.RefreshTokenInput
+
+	err := decodeJSON(r, &input)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	current, err := h.GormRefreshTokenRepository.FindByHash(r.Context(), entityPkg.HashToken(input.RefreshToken))
+	if err != nil {
+		WriteError(w, r, entity.ErrInvalidRefreshToken)
+		return
+	}
+
+	if current.IsRotated() {
+		h.revokeFamily(w, r, current)
 		return
 	}
 
-	if !u.ValidatePassword(user.Password) {
-		w.WriteHeader(http.StatusUnauthorized)
+	if current.IsRevoked() || current.IsExpired(time.Now()) {
+		WriteError(w, r, entity.ErrInvalidRefreshToken)
 		return
 	}
 
-	_, tokenString, _ := jwt.Encode(map[string]interface{}{
-		"sub": u.ID.String(),
-		"exp": time.Now().Add(time.Second * time.Duration(jwtExpiresIn)).Unix(),
+	// the refresh token identifies the user, whatever tenant r names.
+	u, err := h.GormUserRepository.FindByID(database.AllTenants(r.Context()), current.UserID.String())
+	if err != nil {
+		WriteError(w, r, entity.ErrInvalidRefreshToken)
+		return
+	}
+	if u.Disabled {
+		WriteError(w, r, entity.ErrUserDisabled)
+		return
+	}
+
+	next, plainRefreshToken, err := entity.NewRefreshToken(current.UserID, current.FamilyID, refreshTokenTTL(r))
+	if err == nil {
+		err = h.GormRefreshTokenRepository.Rotate(r.Context(), current, next)
+	}
+	if errors.Is(err, entity.ErrRefreshTokenReused) {
+		h.revokeFamily(w, r, current)
+		return
+	}
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	h.writeTokens(w, r, u, plainRefreshToken)
+}
+
+// Logout godoc
+// @Summary      User logout
+// @Description  Revoke the access token used for this request and, when given, the refresh token of the same login
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request   body     dto.LogoutInput  false  "refresh token"
+// @Success      204
+// @Failure      401  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /users/logout [post]
+// @Security ApiKeyAuth
+func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err != nil || token == nil {
+		WriteProblem(w, r, NewProblem(http.StatusUnauthorized, "a valid access token is required"))
+		return
+	}
+
+	err = h.revokeAccessToken(r)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	var input dto.LogoutInput
+	decodeJSON(r, &input)
+
+	if input.RefreshToken != "" {
+		refreshToken, err := h.GormRefreshTokenRepository.FindByHash(r.Context(), entityPkg.HashToken(input.RefreshToken))
+		if err == nil && refreshToken.UserID.String() == token.Subject() {
+			err = h.GormRefreshTokenRepository.RevokeFamily(r.Context(), refreshToken.FamilyID.String())
+			if err != nil {
+				WriteError(w, r, err)
+				return
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeTokens signs a new access token for the user and writes it together
+// with the refresh token.
+func (h *UserHandler) writeTokens(w http.ResponseWriter, r *http.Request, u *entity.User, refreshToken string) {
+	jwt := r.Context().Value("token").(TokenEncoder)
+	jwtExpiresIn := r.Context().Value("JwtExpiresIn").(int)
+
+	_, tokenString, err := jwt.Encode(map[string]interface{}{
+		"sub":    u.ID.String(),
+		"tenant": u.TenantID.String(),
+		"role":   string(u.Role),
+		// email_verified lets middlewares.RequireVerifiedEmail decide
+		// without a lookup; users refresh after verifying to update it.
+		"email_verified": u.IsVerified(),
+		"jti":            entityPkg.NewID().String(),
+		"exp":            time.Now().Add(time.Second * time.Duration(jwtExpiresIn)).Unix(),
 	})
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
 
 	accessToken := dto.UserLoginOutput{
-		AccessToken: tokenString,
+		AccessToken:  tokenString,
+		RefreshToken: refreshToken,
+		ExpiresIn:    jwtExpiresIn,
 	}
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(accessToken)
 }
 
+// revokeFamily answers a reused refresh token by revoking every token of
+// its login, since either the client or an attacker holds a leaked copy.
+func (h *UserHandler) revokeFamily(w http.ResponseWriter, r *http.Request, token *entity.RefreshToken) {
+	err := h.GormRefreshTokenRepository.RevokeFamily(r.Context(), token.FamilyID.String())
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	WriteError(w, r, entity.ErrRefreshTokenReused)
+}
+
+func refreshTokenTTL(r *http.Request) time.Duration {
+	return time.Second * time.Duration(r.Context().Value("JwtRefreshExpiresIn").(int))
+}
+
 // Create user godoc
 // @Summary      Create user
-// @Description  Create user
+// @Description  Create user. A link to verify the email is mailed to it; until then the user cannot change products.
 // @Tags         users
 // @Accept       json
 // @Produce      json
 // @Param        request     body      dto.CreateUserInput  true  "user request"
+// @Param        X-Tenant    header    string               false  "tenant slug, the default tenant when missing"
 // @Success      201
-// @Failure      500         {object}  Error
+// @Failure      400         {object}  Problem
+// @Failure      409         {object}  Problem
+// @Failure      422         {object}  Problem
+// @Failure      500         {object}  Problem
 // @Router       /users [post]
 func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	var user dto.CreateUserInput
 
-	err := json.NewDecoder(r.Body).Decode(&user)
+	err := decodeJSON(r, &user)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
 
+	u, err := entity.NewUser(user.Name, user.Email, user.Password)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		WriteError(w, r, err)
+		return
+	}
 
-		error := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+	err = h.GormUserRepository.Create(r.Context(), u)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+	h.mailVerification(r.Context(), u)
+	w.WriteHeader(http.StatusCreated)
+}
 
+// GetMe godoc
+// @Summary      Get current user
+// @Description  Get the account the access token was issued to
+// @Tags         users
+// @Produce      json
+// @Success      200  {object}  entity.User
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /users/me [get]
+// @Security ApiKeyAuth
+func (h *UserHandler) GetMe(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.currentUser(w, r)
+	if !ok {
 		return
 	}
 
-	u, err := entity.NewUser(user.Name, user.Email, user.Password)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(u)
+}
+
+// UpdateMe godoc
+// @Summary      Update current user
+// @Description  Change the name or email of the account the access token was issued to. Omitted fields are kept.
+// @Description  A new email has to be verified again through the link mailed to it.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request  body      dto.UpdateUserInput  true  "fields to change"
+// @Success      200      {object}  entity.User
+// @Failure      400      {object}  Problem
+// @Failure      401      {object}  Problem
+// @Failure      403      {object}  Problem
+// @Failure      409      {object}  Problem
+// @Failure      422      {object}  Problem
+// @Failure      500      {object}  Problem
+// @Router       /users/me [patch]
+// @Security ApiKeyAuth
+func (h *UserHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var input dto.UpdateUserInput
+	err := decodeJSON(r, &input)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		WriteError(w, r, err)
+		return
+	}
 
-		error := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+	if input.Name != nil {
+		u.Name = *input.Name
+	}
+	if input.Email != nil {
+		u.ChangeEmail(*input.Email)
+	}
 
+	err = h.GormUserRepository.Update(r.Context(), u)
+	if err != nil {
+		WriteError(w, r, err)
 		return
 	}
+	if !u.IsVerified() && input.Email != nil {
+		h.mailVerification(r.Context(), u)
+	}
 
-	err = h.GormUserRepository.Create(u)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(u)
+}
+
+// ChangePassword godoc
+// @Summary      Change password
+// @Description  Replace the password of the current user. The current password must be given; every other login of the account is signed out.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request  body  dto.ChangePasswordInput  true  "current and new password"
+// @Success      204
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      422  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /users/me/password [put]
+// @Security ApiKeyAuth
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var input dto.ChangePasswordInput
+	err := decodeJSON(r, &input)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	err = u.ChangePassword(input.CurrentPassword, input.NewPassword)
+	if err == nil {
+		err = h.GormUserRepository.Update(r.Context(), u)
+	}
+	if err == nil {
+		err = h.GormRefreshTokenRepository.RevokeAllForUser(r.Context(), u.ID.String())
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
 
-		error := Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+// DeleteMe godoc
+// @Summary      Delete current user
+// @Description  Delete the account the access token was issued to and sign out all of its logins
+// @Tags         users
+// @Produce      json
+// @Success      204
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /users/me [delete]
+// @Security ApiKeyAuth
+func (h *UserHandler) DeleteMe(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
 
+	err := h.guardLastAdmin(r.Context(), u)
+	if err == nil {
+		err = h.GormUserRepository.Delete(r.Context(), u.ID.String())
+	}
+	if err == nil {
+		err = h.revokeAccessToken(r)
+	}
+	if err != nil {
+		WriteError(w, r, err)
 		return
 	}
-	w.WriteHeader(http.StatusCreated)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListUsers godoc
+// @Summary      List users
+// @Description  List every account ordered by email
+// @Tags         users
+// @Produce      json
+// @Param        page   query     string  false  "page number"
+// @Param        limit  query     string  false  "limit"
+// @Success      200    {array}   entity.User
+// @Failure      401    {object}  Problem
+// @Failure      403    {object}  Problem
+// @Failure      500    {object}  Problem
+// @Router       /users [get]
+// @Security ApiKeyAuth
+func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil {
+		page = 0
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil {
+		limit = 10
+	}
+
+	users, err := h.GormUserRepository.FindAll(r.Context(), page, limit)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(users)
+}
+
+// GetUser godoc
+// @Summary      Get user
+// @Description  Get an account by id
+// @Tags         users
+// @Produce      json
+// @Param        id   path      string  true  "user ID" Format(uuid)
+// @Success      200  {object}  entity.User
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /users/{id} [get]
+// @Security ApiKeyAuth
+func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	u, err := h.GormUserRepository.FindByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeNotFound(w, r, "user", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(u)
+}
+
+// DisableUser godoc
+// @Summary      Disable user
+// @Description  Stop an account from logging in and sign out all of its logins. Access tokens already issued stay valid until they expire.
+// @Tags         users
+// @Produce      json
+// @Param        id   path      string  true  "user ID" Format(uuid)
+// @Success      200  {object}  entity.User
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /users/{id}/disable [post]
+// @Security ApiKeyAuth
+func (h *UserHandler) DisableUser(w http.ResponseWriter, r *http.Request) {
+	h.setDisabled(w, r, true)
+}
+
+// EnableUser godoc
+// @Summary      Enable user
+// @Description  Let a disabled account log in again
+// @Tags         users
+// @Produce      json
+// @Param        id   path      string  true  "user ID" Format(uuid)
+// @Success      200  {object}  entity.User
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /users/{id}/enable [post]
+// @Security ApiKeyAuth
+func (h *UserHandler) EnableUser(w http.ResponseWriter, r *http.Request) {
+	h.setDisabled(w, r, false)
+}
+
+// DeleteUser godoc
+// @Summary      Delete user
+// @Description  Delete an account and sign out all of its logins
+// @Tags         users
+// @Produce      json
+// @Param        id   path  string  true  "user ID" Format(uuid)
+// @Success      204
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /users/{id} [delete]
+// @Security ApiKeyAuth
+func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	u, err := h.GormUserRepository.FindByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeNotFound(w, r, "user", err)
+		return
+	}
+
+	err = h.guardLastAdmin(r.Context(), u)
+	if err == nil {
+		err = h.GormUserRepository.Delete(r.Context(), u.ID.String())
+	}
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnlockUser godoc
+// @Summary      Unlock user
+// @Description  Forget the failed logins of an account, lifting its lockout. Failures counted against client IPs are kept.
+// @Tags         users
+// @Produce      json
+// @Param        id   path  string  true  "user ID" Format(uuid)
+// @Success      204
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /users/{id}/unlock [post]
+// @Security ApiKeyAuth
+func (h *UserHandler) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	u, err := h.GormUserRepository.FindByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeNotFound(w, r, "user", err)
+		return
+	}
+
+	if h.LoginThrottle != nil {
+		err = h.LoginThrottle.reset(r.Context(), u.TenantID, u.Email)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UserHandler) setDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	u, err := h.GormUserRepository.FindByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeNotFound(w, r, "user", err)
+		return
+	}
+
+	if disabled {
+		err = h.guardLastAdmin(r.Context(), u)
+	}
+	if err == nil {
+		u.Disabled = disabled
+		err = h.GormUserRepository.Update(r.Context(), u)
+	}
+	if err == nil && disabled {
+		err = h.GormRefreshTokenRepository.RevokeAllForUser(r.Context(), u.ID.String())
+	}
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(u)
+}
+
+// ForgotPassword godoc
+// @Summary      Request a password reset
+// @Description  Mail a single use link to reset the password to the account with the email, if there is one.
+// @Description  The answer is the same whether or not the email is registered. Requesting again invalidates
+// @Description  links mailed before.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request   body    dto.ForgotPasswordInput  true   "account email"
+// @Param        X-Tenant  header  string                   false  "tenant slug, the default tenant when missing"
+// @Success      202
+// @Failure      400  {object}  Problem
+// @Failure      422  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /users/password/forgot [post]
+func (h *UserHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var input dto.ForgotPasswordInput
+	err := decodeJSON(r, &input)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	u, err := h.GormUserRepository.FindByEmail(r.Context(), input.Email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		WriteError(w, r, err)
+		return
+	}
+	if u != nil && !u.Disabled {
+		err = h.AccountMailer.sendPasswordReset(r.Context(), u)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ResetPassword godoc
+// @Summary      Reset password
+// @Description  Set a new password with the token of a password reset link. The token works once; every login of
+// @Description  the account is signed out and its failed logins are forgotten. Since the link was mailed, the
+// @Description  email counts as verified too.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request  body  dto.ResetPasswordInput  true  "reset token and new password"
+// @Success      204
+// @Failure      400  {object}  Problem
+// @Failure      422  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /users/password/reset [post]
+func (h *UserHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var input dto.ResetPasswordInput
+	err := decodeJSON(r, &input)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	now := time.Now()
+	u, err := h.consumeToken(r, input.Token, entity.TokenPurposePasswordReset, now)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	err = u.SetPassword(input.NewPassword)
+	if err == nil {
+		u.VerifyEmail(now)
+		err = h.GormUserRepository.Update(database.WithTenant(r.Context(), u.TenantID), u)
+	}
+	if err == nil {
+		err = h.GormRefreshTokenRepository.RevokeAllForUser(r.Context(), u.ID.String())
+	}
+	if err == nil && h.LoginThrottle != nil {
+		err = h.LoginThrottle.reset(r.Context(), u.TenantID, u.Email)
+	}
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// VerifyEmail godoc
+// @Summary      Verify email
+// @Description  Confirm the email of an account with the token of the link mailed to it. The token works once.
+// @Description  Access tokens issued before still say the email is unverified; refresh to get a new one.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request  body  dto.VerifyEmailInput  true  "verification token"
+// @Success      204
+// @Failure      400  {object}  Problem
+// @Failure      422  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /users/verify [post]
+func (h *UserHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var input dto.VerifyEmailInput
+	err := decodeJSON(r, &input)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	now := time.Now()
+	u, err := h.consumeToken(r, input.Token, entity.TokenPurposeEmailVerification, now)
+	if err == nil {
+		u.VerifyEmail(now)
+		err = h.GormUserRepository.Update(database.WithTenant(r.Context(), u.TenantID), u)
+	}
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResendVerification godoc
+// @Summary      Resend verification email
+// @Description  Mail a new link to verify the email of the current user, invalidating those mailed before. Nothing is sent when the email is verified already.
+// @Tags         users
+// @Produce      json
+// @Success      202
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /users/verify/resend [post]
+// @Security ApiKeyAuth
+func (h *UserHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	if !u.IsVerified() {
+		err := h.AccountMailer.sendVerification(r.Context(), u)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// consumeToken redeems a mailed token and loads the user it was issued to,
+// whichever tenant it is in.
+func (h *UserHandler) consumeToken(r *http.Request, plain string, purpose entity.TokenPurpose, now time.Time) (*entity.User, error) {
+	token, err := h.AccountMailer.Tokens.Consume(r.Context(), entityPkg.HashToken(plain), purpose, now)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := h.GormUserRepository.FindByID(database.AllTenants(r.Context()), token.UserID.String())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, entity.ErrInvalidUserToken
+	}
+	return u, err
+}
+
+// mailVerification mails u a link to verify its email. The account change
+// that calls for it already happened, so a failure is only logged; the user
+// can ask for another link.
+func (h *UserHandler) mailVerification(ctx context.Context, u *entity.User) {
+	if h.AccountMailer == nil {
+		return
+	}
+	if err := h.AccountMailer.sendVerification(ctx, u); err != nil {
+		log.Printf("failed to issue an email verification for user %s: %v", u.ID, err)
+	}
+}
+
+// currentUser loads the user the access token of r was issued to. Tokens of
+// deleted users answer 401 and those of disabled users 403.
+func (h *UserHandler) currentUser(w http.ResponseWriter, r *http.Request) (*entity.User, bool) {
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err != nil || token == nil {
+		WriteProblem(w, r, NewProblem(http.StatusUnauthorized, "a valid access token is required"))
+		return nil, false
+	}
+
+	u, err := h.GormUserRepository.FindByID(r.Context(), token.Subject())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		WriteProblem(w, r, NewProblem(http.StatusUnauthorized, "a valid access token is required"))
+		return nil, false
+	}
+	if err != nil {
+		WriteError(w, r, err)
+		return nil, false
+	}
+	if u.Disabled {
+		WriteError(w, r, entity.ErrUserDisabled)
+		return nil, false
+	}
+	return u, true
+}
+
+// guardLastAdmin refuses to remove u when it is the only enabled admin of
+// its tenant, which would leave nobody able to manage its users.
+func (h *UserHandler) guardLastAdmin(ctx context.Context, u *entity.User) error {
+	if u.Role != entity.RoleAdmin || u.Disabled {
+		return nil
+	}
+
+	admins, err := h.GormUserRepository.CountByRole(ctx, entity.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if admins <= 1 {
+		return entity.ErrLastAdmin
+	}
+	return nil
+}
+
+// revokeAccessToken blacklists the access token r was made with.
+func (h *UserHandler) revokeAccessToken(r *http.Request) error {
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err != nil || token == nil || token.JwtID() == "" {
+		return err
+	}
+	return h.GormRevokedTokenRepository.Revoke(r.Context(), token.JwtID(), token.Expiration())
 }
@@ -0,0 +1,491 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/dto"
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/Mazzael/go-api/internal/infra/database"
+	"github.com/Mazzael/go-api/internal/infra/database/migrations"
+	"github.com/Mazzael/go-api/internal/infra/mail"
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type userTestServer struct {
+	*httptest.Server
+	// ctx is scoped to the default tenant every request acts in.
+	ctx       context.Context
+	users     *database.GormUserRepository
+	mail      recordingMailer
+	mailer    *AccountMailer
+	events    *recordingEvents
+	tokenAuth *jwtauth.JWTAuth
+}
+
+// recordingEvents counts the events the handlers report.
+type recordingEvents struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (e *recordingEvents) record(event string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.counts[event]++
+}
+
+func (e *recordingEvents) count(event string) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.counts[event]
+}
+
+func (e *recordingEvents) Login(outcome string) {
+	e.record("login " + outcome)
+}
+
+func (e *recordingEvents) ProductCreated() {
+	e.record("product created")
+}
+
+// recordingMailer hands the messages sent in the background to the test.
+type recordingMailer chan mail.Message
+
+func (m recordingMailer) Send(msg mail.Message) error {
+	m <- msg
+	return nil
+}
+
+// newUserTestServer serves the user routes over a migrated sqlite database,
+// wired like cmd/server minus the permission checks.
+func newUserTestServer(t *testing.T) *userTestServer {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrations.NewMigrator(db).Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	tenant, err := database.NewTenant(db).FindBySlug(context.Background(), entity.DefaultTenantSlug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := database.NewUser(db)
+	throttle := NewLoginThrottle(database.NewMemoryLoginAttempt(),
+		entity.LoginPolicy{FreeAttempts: 5, MaxFailures: 3, Lockout: time.Hour, Window: time.Hour},
+		entity.LoginPolicy{FreeAttempts: 10, MaxFailures: 8, Lockout: time.Hour, Window: time.Hour})
+	templates, err := mail.NewTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mailer := make(recordingMailer, 10)
+	accountMailer := NewAccountMailer(database.NewUserToken(db), mailer, templates, "https://app.example.com/", 48*time.Hour, time.Hour)
+	mfa := NewMFA(database.NewUserToken(db), database.NewRecoveryCode(db), "Go API", 5*time.Minute)
+	handler := NewUserHandler(users, database.NewRefreshToken(db), database.NewRevokedToken(db), throttle, accountMailer, mfa)
+	events := &recordingEvents{counts: map[string]int{}}
+	handler.Events = events
+	apiKeyHandler := NewAPIKeyHandler(database.NewAPIKey(db))
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+
+	r := chi.NewRouter()
+	r.Use(middleware.WithValue("token", tokenAuth))
+	r.Use(middleware.WithValue("JwtExpiresIn", 300))
+	r.Use(middleware.WithValue("JwtRefreshExpiresIn", 3600))
+	r.Use(withTenant(tenant.ID))
+	r.Post("/users", handler.CreateUser)
+	r.Post("/users/auth", handler.Login)
+	r.Post("/users/auth/mfa", handler.LoginMFA)
+	r.Post("/users/auth/refresh", handler.Refresh)
+	r.Post("/users/password/forgot", handler.ForgotPassword)
+	r.Post("/users/password/reset", handler.ResetPassword)
+	r.Post("/users/verify", handler.VerifyEmail)
+	r.Group(func(r chi.Router) {
+		r.Use(jwtauth.Verifier(tokenAuth))
+		r.Use(jwtauth.Authenticator)
+		r.Get("/users/me", handler.GetMe)
+		r.Patch("/users/me", handler.UpdateMe)
+		r.Delete("/users/me", handler.DeleteMe)
+		r.Put("/users/me/password", handler.ChangePassword)
+		r.Post("/users/verify/resend", handler.ResendVerification)
+		r.Post("/users/me/api-keys", apiKeyHandler.CreateAPIKey)
+		r.Get("/users/me/api-keys", apiKeyHandler.ListAPIKeys)
+		r.Delete("/users/me/api-keys/{id}", apiKeyHandler.RevokeAPIKey)
+		r.Post("/users/me/mfa/totp", handler.EnrollTOTP)
+		r.Post("/users/me/mfa/totp/confirm", handler.ConfirmTOTP)
+		r.Post("/users/me/mfa/totp/disable", handler.DisableTOTP)
+		r.Post("/users/me/mfa/recovery-codes", handler.RegenerateRecoveryCodes)
+		r.Post("/users/{id}/mfa/reset", handler.ResetMFA)
+		r.Post("/users/{id}/disable", handler.DisableUser)
+		r.Post("/users/{id}/unlock", handler.UnlockUser)
+		r.Delete("/users/{id}", handler.DeleteUser)
+	})
+
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return &userTestServer{Server: server, ctx: database.WithTenant(context.Background(), tenant.ID), users: users, mail: mailer, mailer: accountMailer, events: events, tokenAuth: tokenAuth}
+}
+
+// withTenant stands in for middlewares.ResolveTenant, scoping every request
+// to tenantID.
+func withTenant(tenantID entityPkg.ID) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(database.WithTenant(r.Context(), tenantID)))
+		})
+	}
+}
+
+var mailedToken = regexp.MustCompile(`token=([A-Za-z0-9_-]+)`)
+
+// nextMail waits for the next message mailed to to and returns it with the
+// token its link carries.
+func (s *userTestServer) nextMail(t *testing.T, to string) (mail.Message, string) {
+	select {
+	case msg := <-s.mail:
+		assert.Equal(t, to, msg.To)
+		match := mailedToken.FindStringSubmatch(msg.Text)
+		if match == nil {
+			t.Fatalf("no token in %q", msg.Text)
+		}
+		return msg, match[1]
+	case <-time.After(time.Second):
+		t.Fatal("no mail sent")
+		return mail.Message{}, ""
+	}
+}
+
+func (s *userTestServer) assertNoMail(t *testing.T) {
+	select {
+	case msg := <-s.mail:
+		t.Fatalf("unexpected mail to %s: %s", msg.To, msg.Subject)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// emailVerifiedClaim reads the email_verified claim of an access token.
+func (s *userTestServer) emailVerifiedClaim(t *testing.T, accessToken string) bool {
+	token, err := s.tokenAuth.Decode(accessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verified, _ := token.PrivateClaims()["email_verified"].(bool)
+	return verified
+}
+
+func (s *userTestServer) do(t *testing.T, method, path, token, body string) *http.Response {
+	req, _ := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+	return res
+}
+
+// loginProblem attempts a login that is expected to fail and returns the
+// status and the problem answered.
+func (s *userTestServer) loginProblem(t *testing.T, email, password string) (int, Problem) {
+	res := s.do(t, http.MethodPost, "/users/auth", "", `{"email": "`+email+`", "password": "`+password+`"}`)
+	var p Problem
+	json.NewDecoder(res.Body).Decode(&p)
+	return res.StatusCode, p
+}
+
+func (s *userTestServer) signUp(t *testing.T, email, password string) string {
+	res := s.do(t, http.MethodPost, "/users", "", `{"name": "User", "email": "`+email+`", "password": "`+password+`"}`)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	s.nextMail(t, email)
+	return s.login(t, email, password)
+}
+
+func (s *userTestServer) login(t *testing.T, email, password string) string {
+	res := s.do(t, http.MethodPost, "/users/auth", "", `{"email": "`+email+`", "password": "`+password+`"}`)
+	if res.StatusCode != http.StatusOK {
+		return ""
+	}
+	var output dto.UserLoginOutput
+	json.NewDecoder(res.Body).Decode(&output)
+	return output.AccessToken
+}
+
+func TestCreateUserWithTakenEmailConflicts(t *testing.T) {
+	s := newUserTestServer(t)
+	s.signUp(t, "ana@example.com", "password1")
+
+	res := s.do(t, http.MethodPost, "/users", "", `{"name": "Ana", "email": "ANA@example.com", "password": "password2"}`)
+	assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+	var p Problem
+	json.NewDecoder(res.Body).Decode(&p)
+	assert.Equal(t, ProblemTypeEmailTaken, p.Type)
+}
+
+func TestMeEndpoints(t *testing.T) {
+	s := newUserTestServer(t)
+	token := s.signUp(t, "ana@example.com", "password1")
+	s.signUp(t, "bia@example.com", "password1")
+
+	res := s.do(t, http.MethodGet, "/users/me", token, "")
+	var me entity.User
+	json.NewDecoder(res.Body).Decode(&me)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "ana@example.com", me.Email)
+
+	res = s.do(t, http.MethodPatch, "/users/me", token, `{"name": "Ana Maria"}`)
+	json.NewDecoder(res.Body).Decode(&me)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "Ana Maria", me.Name)
+	assert.Equal(t, "ana@example.com", me.Email)
+
+	res = s.do(t, http.MethodPatch, "/users/me", token, `{"email": "bia@example.com"}`)
+	assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+	res = s.do(t, http.MethodPatch, "/users/me", token, `{"name": ""}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+
+	res = s.do(t, http.MethodDelete, "/users/me", token, "")
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	res = s.do(t, http.MethodGet, "/users/me", token, "")
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+}
+
+func TestChangePasswordRequiresTheCurrentPassword(t *testing.T) {
+	s := newUserTestServer(t)
+	token := s.signUp(t, "ana@example.com", "password1")
+
+	res := s.do(t, http.MethodPut, "/users/me/password", token, `{"current_password": "wrong", "new_password": "password2"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+	var p Problem
+	json.NewDecoder(res.Body).Decode(&p)
+	assert.Equal(t, []FieldError{{Field: "current_password", Message: entity.ErrIncorrectPassword.Error()}}, p.Errors)
+
+	res = s.do(t, http.MethodPut, "/users/me/password", token, `{"current_password": "password1", "new_password": "password2"}`)
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+
+	assert.Empty(t, s.login(t, "ana@example.com", "password1"))
+	assert.NotEmpty(t, s.login(t, "ana@example.com", "password2"))
+}
+
+func TestDisabledUsersCannotLogIn(t *testing.T) {
+	s := newUserTestServer(t)
+	adminToken := s.signUp(t, "admin@example.com", "password1")
+	token := s.signUp(t, "ana@example.com", "password1")
+
+	ana, _ := s.users.FindByEmail(s.ctx, "ana@example.com")
+	res := s.do(t, http.MethodPost, "/users/"+ana.ID.String()+"/disable", adminToken, "")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	res = s.do(t, http.MethodPost, "/users/auth", "", `{"email": "ana@example.com", "password": "password1"}`)
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+	res = s.do(t, http.MethodGet, "/users/me", token, "")
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+}
+
+func TestTheLastAdminCannotBeRemoved(t *testing.T) {
+	s := newUserTestServer(t)
+	token := s.signUp(t, "admin@example.com", "password1")
+
+	admin, _ := s.users.FindByEmail(s.ctx, "admin@example.com")
+	admin.Role = entity.RoleAdmin
+	assert.NoError(t, s.users.Update(s.ctx, admin))
+
+	res := s.do(t, http.MethodDelete, "/users/me", token, "")
+	assert.Equal(t, http.StatusConflict, res.StatusCode)
+	res = s.do(t, http.MethodPost, "/users/"+admin.ID.String()+"/disable", token, "")
+	assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+	s.signUp(t, "second@example.com", "password1")
+	second, _ := s.users.FindByEmail(s.ctx, "second@example.com")
+	second.Role = entity.RoleAdmin
+	assert.NoError(t, s.users.Update(s.ctx, second))
+
+	res = s.do(t, http.MethodDelete, "/users/"+admin.ID.String(), token, "")
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+}
+
+func TestLoginFailsAlikeForUnknownEmailsAndWrongPasswords(t *testing.T) {
+	s := newUserTestServer(t)
+	s.signUp(t, "ana@example.com", "password1")
+
+	started := time.Now()
+	wrongStatus, wrongPassword := s.loginProblem(t, "ana@example.com", "wrong")
+	wrongTook := time.Since(started)
+
+	started = time.Now()
+	unknownStatus, unknownEmail := s.loginProblem(t, "nobody@example.com", "wrong")
+	unknownTook := time.Since(started)
+
+	assert.Equal(t, http.StatusUnauthorized, wrongStatus)
+	assert.Equal(t, wrongStatus, unknownStatus)
+	assert.Equal(t, wrongPassword, unknownEmail)
+	// both compare a bcrypt hash, which dominates the response time.
+	assert.Greater(t, unknownTook, wrongTook/3)
+}
+
+func TestLoginLocksTheAccountOutAndAdminsUnlockIt(t *testing.T) {
+	s := newUserTestServer(t)
+	adminToken := s.signUp(t, "admin@example.com", "password1")
+	s.signUp(t, "ana@example.com", "password1")
+
+	for i := 0; i < 3; i++ {
+		status, _ := s.loginProblem(t, "ana@example.com", "wrong")
+		assert.Equal(t, http.StatusUnauthorized, status)
+	}
+
+	res := s.do(t, http.MethodPost, "/users/auth", "", `{"email": "ana@example.com", "password": "password1"}`)
+	assert.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+	assert.Equal(t, "3600", res.Header.Get("Retry-After"))
+
+	for i := 0; i < 3; i++ {
+		s.loginProblem(t, "nobody@example.com", "wrong")
+	}
+	_, locked := s.loginProblem(t, "ana@example.com", "password1")
+	_, unknownLocked := s.loginProblem(t, "nobody@example.com", "password1")
+	assert.Equal(t, locked, unknownLocked)
+	assert.Equal(t, ProblemTypeLoginThrottled, locked.Type)
+
+	ana, _ := s.users.FindByEmail(s.ctx, "ana@example.com")
+	res = s.do(t, http.MethodPost, "/users/"+ana.ID.String()+"/unlock", adminToken, "")
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	assert.NotEmpty(t, s.login(t, "ana@example.com", "password1"))
+}
+
+func TestLoginReportsItsOutcome(t *testing.T) {
+	s := newUserTestServer(t)
+	s.signUp(t, "ana@example.com", "password1")
+	assert.Equal(t, 1, s.events.count("login "+LoginSucceeded))
+
+	for i := 0; i < 3; i++ {
+		s.loginProblem(t, "ana@example.com", "wrong")
+	}
+	s.loginProblem(t, "ana@example.com", "password1")
+
+	assert.Equal(t, 1, s.events.count("login "+LoginSucceeded))
+	assert.Equal(t, 3, s.events.count("login "+LoginFailed))
+	assert.Equal(t, 1, s.events.count("login "+LoginThrottled))
+}
+
+func TestLoginThrottlesTheClientIP(t *testing.T) {
+	s := newUserTestServer(t)
+	s.signUp(t, "ana@example.com", "password1")
+
+	for i := 0; i < 8; i++ {
+		status, _ := s.loginProblem(t, fmt.Sprintf("guess%d@example.com", i), "wrong")
+		assert.Equal(t, http.StatusUnauthorized, status)
+	}
+
+	status, _ := s.loginProblem(t, "ana@example.com", "password1")
+	assert.Equal(t, http.StatusTooManyRequests, status)
+}
+
+func TestVerifyEmail(t *testing.T) {
+	s := newUserTestServer(t)
+	res := s.do(t, http.MethodPost, "/users", "", `{"name": "Ana", "email": "ana@example.com", "password": "password1"}`)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+
+	msg, first := s.nextMail(t, "ana@example.com")
+	assert.Equal(t, "Confirm your email address", msg.Subject)
+	assert.Contains(t, msg.Text, "https://app.example.com/verify-email?token="+first)
+	assert.Contains(t, msg.Text, "2 days")
+	assert.Contains(t, msg.HTML, "https://app.example.com/verify-email?token="+first)
+
+	token := s.login(t, "ana@example.com", "password1")
+	assert.False(t, s.emailVerifiedClaim(t, token))
+
+	res = s.do(t, http.MethodPost, "/users/verify/resend", token, "")
+	assert.Equal(t, http.StatusAccepted, res.StatusCode)
+	_, second := s.nextMail(t, "ana@example.com")
+
+	res = s.do(t, http.MethodPost, "/users/verify", "", `{"token": "`+first+`"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+	res = s.do(t, http.MethodPost, "/users/verify", "", `{"token": "`+second+`"}`)
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	res = s.do(t, http.MethodPost, "/users/verify", "", `{"token": "`+second+`"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+
+	token = s.login(t, "ana@example.com", "password1")
+	assert.True(t, s.emailVerifiedClaim(t, token))
+
+	res = s.do(t, http.MethodPost, "/users/verify/resend", token, "")
+	assert.Equal(t, http.StatusAccepted, res.StatusCode)
+	s.assertNoMail(t)
+
+	res = s.do(t, http.MethodPatch, "/users/me", token, `{"email": "ana.maria@example.com"}`)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var me entity.User
+	json.NewDecoder(res.Body).Decode(&me)
+	assert.Nil(t, me.EmailVerifiedAt)
+	s.nextMail(t, "ana.maria@example.com")
+}
+
+func TestAccountMailDeliversThroughGo(t *testing.T) {
+	s := newUserTestServer(t)
+	deliveries := make(chan func(ctx context.Context), 1)
+	s.mailer.Go = func(deliver func(ctx context.Context)) {
+		deliveries <- deliver
+	}
+
+	res := s.do(t, http.MethodPost, "/users", "", `{"name": "Ana", "email": "ana@example.com", "password": "password1"}`)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	s.assertNoMail(t)
+
+	(<-deliveries)(context.Background())
+	s.nextMail(t, "ana@example.com")
+}
+
+func TestResetPassword(t *testing.T) {
+	s := newUserTestServer(t)
+	s.signUp(t, "ana@example.com", "password1")
+	for i := 0; i < 3; i++ {
+		s.loginProblem(t, "ana@example.com", "wrong")
+	}
+
+	res := s.do(t, http.MethodPost, "/users/password/forgot", "", `{"email": "nobody@example.com"}`)
+	assert.Equal(t, http.StatusAccepted, res.StatusCode)
+	s.assertNoMail(t)
+
+	res = s.do(t, http.MethodPost, "/users/password/forgot", "", `{"email": "ANA@example.com"}`)
+	assert.Equal(t, http.StatusAccepted, res.StatusCode)
+	msg, token := s.nextMail(t, "ana@example.com")
+	assert.Equal(t, "Reset your password", msg.Subject)
+	assert.Contains(t, msg.Text, "https://app.example.com/reset-password?token="+token)
+	assert.Contains(t, msg.Text, "1 hour")
+
+	res = s.do(t, http.MethodPost, "/users/password/reset", "", `{"token": "`+token+`", "new_password": "short"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+	res = s.do(t, http.MethodPost, "/users/verify", "", `{"token": "`+token+`"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+
+	res = s.do(t, http.MethodPost, "/users/password/reset", "", `{"token": "`+token+`", "new_password": "password2"}`)
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	res = s.do(t, http.MethodPost, "/users/password/reset", "", `{"token": "`+token+`", "new_password": "password3"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+	var p Problem
+	json.NewDecoder(res.Body).Decode(&p)
+	assert.Equal(t, []FieldError{{Field: "token", Message: entity.ErrInvalidUserToken.Error()}}, p.Errors)
+
+	assert.Empty(t, s.login(t, "ana@example.com", "password1"))
+	accessToken := s.login(t, "ana@example.com", "password2")
+	assert.NotEmpty(t, accessToken)
+	assert.True(t, s.emailVerifiedClaim(t, accessToken))
+}
@@ -0,0 +1,100 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/Mazzael/go-api/internal/infra/database"
+	"github.com/Mazzael/go-api/internal/infra/webserver/handlers"
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"github.com/go-chi/jwtauth"
+	"github.com/lestrrat-go/jwx/jwt"
+	"gorm.io/gorm"
+)
+
+// VerifyAPIKey authenticates requests presenting an API key, in the
+// X-API-Key header or as a Bearer token, in place of an access token. It
+// stands in for the claims of one, taken from the user of the key as it is
+// now, plus the scopes claim RequirePermission narrows permissions to. It
+// must run after the access token verifier, whose outcome it replaces, and
+// before Authenticator.
+func VerifyAPIKey(keys database.APIKeyRepository, users database.UserRepository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			plain := apiKeyFromRequest(r)
+			if plain == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token, err := apiKeyToken(r.Context(), keys, users, plain, time.Now())
+			if err != nil && !errors.Is(err, entity.ErrInvalidAPIKey) {
+				handlers.WriteError(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(jwtauth.NewContext(r.Context(), token, err)))
+		})
+	}
+}
+
+func apiKeyFromRequest(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if bearer := jwtauth.TokenFromHeader(r); entity.IsAPIKey(bearer) {
+		return bearer
+	}
+	return ""
+}
+
+func apiKeyToken(ctx context.Context, keys database.APIKeyRepository, users database.UserRepository, plain string, now time.Time) (jwt.Token, error) {
+	key, err := keys.FindByHash(ctx, entityPkg.HashToken(strings.TrimSpace(plain)))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, entity.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !key.IsUsable(now) {
+		return nil, entity.ErrInvalidAPIKey
+	}
+
+	// the key identifies the user, whatever tenant the request names.
+	user, err := users.FindByID(database.AllTenants(ctx), key.UserID.String())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, entity.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled {
+		return nil, entity.ErrInvalidAPIKey
+	}
+
+	if err := keys.Touch(ctx, key, now); err != nil {
+		return nil, err
+	}
+
+	scopes := make([]string, len(key.Scopes))
+	for i, scope := range key.Scopes {
+		scopes[i] = string(scope)
+	}
+	token := jwt.New()
+	for name, value := range map[string]interface{}{
+		"sub":            user.ID.String(),
+		"tenant":         user.TenantID.String(),
+		"role":           string(user.Role),
+		"email_verified": user.IsVerified(),
+		"scopes":         scopes,
+		"api_key_id":     key.ID.String(),
+	} {
+		if err := token.Set(name, value); err != nil {
+			return nil, err
+		}
+	}
+	return token, nil
+}
@@ -0,0 +1,139 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/Mazzael/go-api/internal/infra/database"
+	"github.com/Mazzael/go-api/internal/infra/webserver/handlers"
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"github.com/go-chi/jwtauth"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type apiKeyTest struct {
+	ctx     context.Context
+	handler http.Handler
+	users   *database.GormUserRepository
+	keys    *database.GormAPIKeyRepository
+	user    *entity.User
+}
+
+// newAPIKeyTest guards a products:write endpoint with the middleware chain
+// of cmd/server.
+func newAPIKeyTest(t *testing.T) *apiKeyTest {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.AutoMigrate(&entity.User{}, &entity.APIKey{})
+
+	ctx := database.WithTenant(context.Background(), entityPkg.NewID())
+	users := database.NewUser(db)
+	keys := database.NewAPIKey(db)
+	user, _ := entity.NewUser("CI", "ci@example.com", "password1")
+	user.Role = entity.RoleEditor
+	user.VerifyEmail(time.Now())
+	assert.NoError(t, users.Create(ctx, user))
+
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	handler := jwtauth.Verifier(tokenAuth)(VerifyAPIKey(keys, users)(Authenticator(
+		RequirePermission(entity.PermissionProductsWrite)(RequireVerifiedEmail(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				token, _, _ := jwtauth.FromContext(r.Context())
+				w.Write([]byte(token.Subject()))
+			}),
+		)),
+	)))
+	return &apiKeyTest{ctx: ctx, handler: handler, users: users, keys: keys, user: user}
+}
+
+func (a *apiKeyTest) newKey(t *testing.T, expiresAt *time.Time, scopes ...entity.Permission) (*entity.APIKey, string) {
+	key, plain, err := entity.NewAPIKey(a.user.ID, "ci", scopes, expiresAt, time.Now())
+	assert.NoError(t, err)
+	assert.NoError(t, a.keys.Create(a.ctx, key))
+	return key, plain
+}
+
+func (a *apiKeyTest) post(header, value string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/products", nil)
+	req.Header.Set(header, value)
+	rec := httptest.NewRecorder()
+	a.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestVerifyAPIKeyAuthenticatesWithTheKeyOfAUser(t *testing.T) {
+	a := newAPIKeyTest(t)
+	key, plain := a.newKey(t, nil, entity.PermissionProductsRead, entity.PermissionProductsWrite)
+
+	for _, header := range []struct{ name, value string }{
+		{"X-API-Key", plain},
+		{"Authorization", "Bearer " + plain},
+	} {
+		rec := a.post(header.name, header.value)
+		assert.Equal(t, http.StatusOK, rec.Code, header.name)
+		assert.Equal(t, a.user.ID.String(), rec.Body.String())
+	}
+
+	keys, _ := a.keys.FindByUser(a.ctx, a.user.ID.String())
+	assert.NotNil(t, keys[0].LastUsedAt)
+
+	assert.NoError(t, a.keys.Revoke(a.ctx, key.ID.String(), a.user.ID.String(), time.Now()))
+	rec := a.post("X-API-Key", plain)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, http.StatusUnauthorized, a.post("X-API-Key", entity.APIKeyPrefix+"unknown").Code)
+}
+
+func TestVerifyAPIKeyNarrowsPermissionsToTheScopes(t *testing.T) {
+	a := newAPIKeyTest(t)
+	_, readOnly := a.newKey(t, nil, entity.PermissionProductsRead)
+	_, write := a.newKey(t, nil, entity.PermissionProductsWrite)
+
+	rec := a.post("X-API-Key", readOnly)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	var p handlers.Problem
+	json.NewDecoder(rec.Body).Decode(&p)
+	assert.Equal(t, "API key lacks scope products:write", p.Detail)
+
+	// scopes never grant more than the role of the user.
+	a.user.Role = entity.RoleViewer
+	assert.NoError(t, a.users.Update(a.ctx, a.user))
+	assert.Equal(t, http.StatusForbidden, a.post("X-API-Key", write).Code)
+
+	a.user.Role = entity.RoleEditor
+	a.user.Disabled = true
+	assert.NoError(t, a.users.Update(a.ctx, a.user))
+	assert.Equal(t, http.StatusUnauthorized, a.post("X-API-Key", write).Code)
+}
+
+func TestVerifyAPIKeyRejectsExpiredKeys(t *testing.T) {
+	a := newAPIKeyTest(t)
+	expiresAt := time.Now().Add(50 * time.Millisecond)
+	_, plain := a.newKey(t, &expiresAt, entity.PermissionProductsWrite)
+
+	assert.Equal(t, http.StatusOK, a.post("X-API-Key", plain).Code)
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, http.StatusUnauthorized, a.post("X-API-Key", plain).Code)
+}
+
+func TestVerifyAPIKeyLeavesAccessTokensAlone(t *testing.T) {
+	a := newAPIKeyTest(t)
+	_, token, _ := jwtauth.New("HS256", []byte("secret"), nil).Encode(map[string]interface{}{
+		"sub":            a.user.ID.String(),
+		"role":           string(entity.RoleEditor),
+		"email_verified": true,
+		"exp":            time.Now().Add(time.Minute).Unix(),
+	})
+
+	rec := a.post("Authorization", "Bearer "+token)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,24 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/Mazzael/go-api/internal/infra/webserver/handlers"
+	"github.com/go-chi/jwtauth"
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+// Authenticator is jwtauth.Authenticator answering with a problem instead of
+// plain text: it lets the request through only when jwtauth.Verifier found a
+// valid token.
+func Authenticator(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, _, err := jwtauth.FromContext(r.Context())
+		if err != nil || token == nil || jwt.Validate(token) != nil {
+			handlers.WriteProblem(w, r, handlers.NewProblem(http.StatusUnauthorized, "a valid access token is required"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,31 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/Mazzael/go-api/internal/infra/webserver/handlers"
+	"github.com/go-chi/jwtauth"
+)
+
+// RequirePermission answers 403 unless the role claim of the access token
+// grants permission and, for API keys, the scopes claim includes it. It
+// must run after jwtauth.Verifier and Authenticator.
+func RequirePermission(permission entity.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, claims, err := jwtauth.FromContext(r.Context())
+			if err != nil {
+				handlers.WriteProblem(w, r, handlers.NewProblem(http.StatusUnauthorized, "a valid access token is required"))
+				return
+			}
+
+			if p := handlers.Grants(claims, permission); p != nil {
+				handlers.WriteProblem(w, r, p)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
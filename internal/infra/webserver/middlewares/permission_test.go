@@ -0,0 +1,112 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/Mazzael/go-api/internal/infra/webserver/handlers"
+	"github.com/go-chi/jwtauth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequirePermission(t *testing.T) {
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+
+	handler := jwtauth.Verifier(tokenAuth)(Authenticator(RequirePermission(entity.PermissionProductsDelete)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}),
+	)))
+
+	tests := map[string]int{
+		"admin":  http.StatusNoContent,
+		"editor": http.StatusForbidden,
+		"viewer": http.StatusForbidden,
+		"":       http.StatusForbidden,
+	}
+
+	for role, want := range tests {
+		claims := map[string]interface{}{"sub": "user", "exp": time.Now().Add(time.Minute).Unix()}
+		if role != "" {
+			claims["role"] = role
+		}
+		_, token, _ := tokenAuth.Encode(claims)
+
+		req := httptest.NewRequest(http.MethodDelete, "/products/1", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, want, rec.Code, role)
+		if want == http.StatusForbidden {
+			assert.Equal(t, handlers.ProblemContentType, rec.Header().Get("Content-Type"))
+			var body handlers.Problem
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+			assert.Equal(t, http.StatusForbidden, body.Status)
+			assert.Equal(t, "missing permission products:delete", body.Detail)
+			assert.Equal(t, "/products/1", body.Instance)
+		}
+	}
+}
+
+func TestAuthenticatorRejectsWithProblem(t *testing.T) {
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	handler := jwtauth.Verifier(tokenAuth)(Authenticator(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	_, expired, _ := tokenAuth.Encode(map[string]interface{}{"sub": "user", "exp": time.Now().Add(-time.Minute).Unix()})
+	for name, header := range map[string]string{"missing": "", "garbage": "Bearer nope", "expired": "Bearer " + expired} {
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, name)
+		assert.Equal(t, handlers.ProblemContentType, rec.Header().Get("Content-Type"), name)
+	}
+}
+
+func TestRequireVerifiedEmail(t *testing.T) {
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	handler := jwtauth.Verifier(tokenAuth)(Authenticator(RequireVerifiedEmail(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}),
+	)))
+
+	tests := map[string]struct {
+		claim interface{}
+		want  int
+	}{
+		"verified":   {true, http.StatusNoContent},
+		"unverified": {false, http.StatusForbidden},
+		"missing":    {nil, http.StatusForbidden},
+	}
+
+	for name, tt := range tests {
+		claims := map[string]interface{}{"sub": "user", "exp": time.Now().Add(time.Minute).Unix()}
+		if tt.claim != nil {
+			claims["email_verified"] = tt.claim
+		}
+		_, token, _ := tokenAuth.Encode(claims)
+
+		req := httptest.NewRequest(http.MethodPost, "/products", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, tt.want, rec.Code, name)
+		if tt.want == http.StatusForbidden {
+			var body handlers.Problem
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+			assert.Equal(t, handlers.ProblemTypeEmailUnverified, body.Type)
+		}
+	}
+}
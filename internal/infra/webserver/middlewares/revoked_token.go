@@ -0,0 +1,38 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/Mazzael/go-api/internal/infra/database"
+	"github.com/Mazzael/go-api/internal/infra/webserver/handlers"
+	"github.com/go-chi/jwtauth"
+)
+
+// RejectRevokedTokens answers 401 for access tokens whose jti was revoked
+// on logout. It must run after jwtauth.Verifier and Authenticator.
+func RejectRevokedTokens(repo database.RevokedTokenRepository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, _, err := jwtauth.FromContext(r.Context())
+			if err != nil || token == nil {
+				handlers.WriteProblem(w, r, handlers.NewProblem(http.StatusUnauthorized, "a valid access token is required"))
+				return
+			}
+
+			if jti := token.JwtID(); jti != "" {
+				revoked, err := repo.IsRevoked(r.Context(), jti)
+				if err != nil {
+					handlers.WriteError(w, r, err)
+					return
+				}
+				if revoked {
+					handlers.WriteError(w, r, entity.ErrTokenRevoked)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/jwtauth"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeRevokedTokens map[string]bool
+
+func (f fakeRevokedTokens) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	f[jti] = true
+	return nil
+}
+
+func (f fakeRevokedTokens) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	return f[jti], nil
+}
+
+func (f fakeRevokedTokens) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	return 0, nil
+}
+
+func TestRejectRevokedTokens(t *testing.T) {
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	revoked := fakeRevokedTokens{"revoked-jti": true}
+
+	handler := jwtauth.Verifier(tokenAuth)(Authenticator(RejectRevokedTokens(revoked)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	)))
+
+	tests := map[string]int{
+		"valid-jti":   http.StatusOK,
+		"revoked-jti": http.StatusUnauthorized,
+		"":            http.StatusOK,
+	}
+
+	for jti, want := range tests {
+		claims := map[string]interface{}{"sub": "user", "exp": time.Now().Add(time.Minute).Unix()}
+		if jti != "" {
+			claims["jti"] = jti
+		}
+		_, token, _ := tokenAuth.Encode(claims)
+
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, want, rec.Code, jti)
+	}
+}
@@ -0,0 +1,62 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/Mazzael/go-api/internal/infra/database"
+	"github.com/Mazzael/go-api/internal/infra/webserver/handlers"
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"github.com/go-chi/jwtauth"
+)
+
+// TenantHeader names the tenant, by slug, that requests without an access
+// token act in, e.g. to sign up or log in.
+const TenantHeader = "X-Tenant"
+
+// ResolveTenant scopes the context of the request to a tenant, see
+// database.WithTenant. Requests with an access token or API key act in the
+// tenant of its claim, whatever the X-Tenant header says, so that a token
+// never reaches into another tenant. Other requests act in the tenant the
+// header names, or else the default tenant. Behind authentication it must
+// run after Authenticator.
+func ResolveTenant(tenants database.TenantRepository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenantID, err := requestTenant(r, tenants)
+			if err != nil {
+				handlers.WriteError(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(database.WithTenant(r.Context(), tenantID)))
+		})
+	}
+}
+
+func requestTenant(r *http.Request, tenants database.TenantRepository) (entityPkg.ID, error) {
+	slug := strings.ToLower(strings.TrimSpace(r.Header.Get(TenantHeader)))
+	if token, _, err := jwtauth.FromContext(r.Context()); err == nil && token != nil {
+		claim, ok := token.Get("tenant")
+		if ok {
+			value, _ := claim.(string)
+			tenantID, err := entityPkg.ParseID(value)
+			if err != nil {
+				return entityPkg.ID{}, entity.ErrUnknownTenant
+			}
+			return tenantID, nil
+		}
+		// tokens issued before tenants existed have no claim; their users
+		// were moved into the default tenant.
+		slug = ""
+	}
+
+	if slug == "" {
+		slug = entity.DefaultTenantSlug
+	}
+	tenant, err := tenants.FindBySlug(r.Context(), slug)
+	if err != nil {
+		return entityPkg.ID{}, err
+	}
+	return tenant.ID, nil
+}
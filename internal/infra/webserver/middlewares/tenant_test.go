@@ -0,0 +1,253 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/dto"
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/Mazzael/go-api/internal/infra/database"
+	"github.com/Mazzael/go-api/internal/infra/database/migrations"
+	"github.com/Mazzael/go-api/internal/infra/webserver/handlers"
+	entityPkg "github.com/Mazzael/go-api/pkg/entity"
+	"github.com/Mazzael/go-api/pkg/pagination"
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type tenantTest struct {
+	*httptest.Server
+	tokenAuth *jwtauth.JWTAuth
+	acme      *entity.Tenant
+	keys      *database.GormAPIKeyRepository
+}
+
+// newTenantTest serves sign up, login, products and categories like
+// cmd/server, over a
+// database holding the default tenant and acme.
+func newTenantTest(t *testing.T) *tenantTest {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrations.NewMigrator(db).Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	tenants := database.NewTenant(db)
+	acme, _ := entity.NewTenant("acme", "Acme")
+	admin, _ := entity.NewUser("Admin", "admin@acme.example", "password1")
+	admin.Role = entity.RoleAdmin
+	assert.NoError(t, tenants.Create(context.Background(), acme, admin))
+
+	users := database.NewUser(db)
+	keys := database.NewAPIKey(db)
+	userHandler := handlers.NewUserHandler(users, database.NewRefreshToken(db), database.NewRevokedToken(db), nil, nil, nil)
+	categories := database.NewCategory(db)
+	productHandler := handlers.NewProductHandler(database.NewProduct(db), categories, pagination.NewSigner([]byte("secret")), false)
+	categoryHandler := handlers.NewCategoryHandler(categories)
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+
+	r := chi.NewRouter()
+	r.Use(middleware.WithValue("token", tokenAuth))
+	r.Use(middleware.WithValue("JwtExpiresIn", 300))
+	r.Use(middleware.WithValue("JwtRefreshExpiresIn", 3600))
+	r.Group(func(r chi.Router) {
+		r.Use(ResolveTenant(tenants))
+		r.Post("/users", userHandler.CreateUser)
+		r.Post("/users/auth", userHandler.Login)
+	})
+	r.Group(func(r chi.Router) {
+		r.Use(jwtauth.Verifier(tokenAuth))
+		r.Use(VerifyAPIKey(keys, users))
+		r.Use(Authenticator)
+		r.Use(ResolveTenant(tenants))
+		r.Post("/products", productHandler.CreateProduct)
+		r.Get("/products", productHandler.GetProducts)
+		r.Get("/products/{id}", productHandler.GetProduct)
+		r.Delete("/products/{id}", productHandler.DeleteProduct)
+		r.Post("/categories", categoryHandler.CreateCategory)
+		r.Get("/categories", categoryHandler.GetCategories)
+		r.Get("/categories/{id}", categoryHandler.GetCategory)
+		r.Put("/categories/{id}", categoryHandler.UpdateCategory)
+		r.Delete("/categories/{id}", categoryHandler.DeleteCategory)
+	})
+
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return &tenantTest{Server: server, tokenAuth: tokenAuth, acme: acme, keys: keys}
+}
+
+func (s *tenantTest) do(t *testing.T, method, path string, headers map[string]string, body string) *http.Response {
+	req, _ := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+	return res
+}
+
+// signUp creates a user in the tenant named by slug, the default one when
+// empty, and returns its access token.
+func (s *tenantTest) signUp(t *testing.T, slug, email string) string {
+	headers := map[string]string{}
+	if slug != "" {
+		headers[TenantHeader] = slug
+	}
+	credentials := `{"name": "User", "email": "` + email + `", "password": "password1"}`
+	res := s.do(t, http.MethodPost, "/users", headers, credentials)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+
+	res = s.do(t, http.MethodPost, "/users/auth", headers, credentials)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var output dto.UserLoginOutput
+	json.NewDecoder(res.Body).Decode(&output)
+	return output.AccessToken
+}
+
+func bearer(token string) map[string]string {
+	return map[string]string{"Authorization": "Bearer " + token}
+}
+
+func TestTenantsShareEmailsButNotAccounts(t *testing.T) {
+	s := newTenantTest(t)
+
+	defaultToken := s.signUp(t, "", "ana@example.com")
+	acmeToken := s.signUp(t, "acme", "ana@example.com")
+
+	decoded, err := s.tokenAuth.Decode(acmeToken)
+	assert.NoError(t, err)
+	assert.Equal(t, s.acme.ID.String(), decoded.PrivateClaims()["tenant"])
+	decoded, err = s.tokenAuth.Decode(defaultToken)
+	assert.NoError(t, err)
+	assert.NotEqual(t, s.acme.ID.String(), decoded.PrivateClaims()["tenant"])
+
+	// the password of one tenant's account does not log into the other.
+	res := s.do(t, http.MethodPost, "/users/auth", map[string]string{TenantHeader: "acme"},
+		`{"email": "admin@acme.example", "password": "password1"}`)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	res = s.do(t, http.MethodPost, "/users/auth", nil, `{"email": "admin@acme.example", "password": "password1"}`)
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	res = s.do(t, http.MethodPost, "/users/auth", map[string]string{TenantHeader: "nope"}, `{"email": "ana@example.com", "password": "password1"}`)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	var p handlers.Problem
+	json.NewDecoder(res.Body).Decode(&p)
+	assert.Equal(t, handlers.ProblemTypeInvalidParameter, p.Type)
+}
+
+func TestTenantsCannotReachEachOthersProducts(t *testing.T) {
+	s := newTenantTest(t)
+	acmeToken := s.signUp(t, "acme", "ana@example.com")
+	defaultToken := s.signUp(t, "", "bruno@example.com")
+
+	res := s.do(t, http.MethodPost, "/products", bearer(acmeToken), `{"name": "Anvil", "price": {"amount": "10.00", "currency": "USD"}}`)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	var products []*entity.Product
+	res = s.do(t, http.MethodGet, "/products", bearer(acmeToken), "")
+	json.NewDecoder(res.Body).Decode(&products)
+	if !assert.Len(t, products, 1) {
+		return
+	}
+	anvil := "/products/" + products[0].ID.String()
+
+	// naming the other tenant in the header does not get a token into it.
+	intruder := bearer(defaultToken)
+	intruder[TenantHeader] = "acme"
+	assert.Equal(t, http.StatusNotFound, s.do(t, http.MethodGet, anvil, intruder, "").StatusCode)
+	assert.Equal(t, http.StatusNotFound, s.do(t, http.MethodDelete, anvil, intruder, "").StatusCode)
+
+	res = s.do(t, http.MethodGet, "/products", intruder, "")
+	products = nil
+	json.NewDecoder(res.Body).Decode(&products)
+	assert.Empty(t, products)
+
+	assert.Equal(t, http.StatusOK, s.do(t, http.MethodGet, anvil, bearer(acmeToken), "").StatusCode)
+}
+
+func TestTenantsCannotReachEachOthersCategories(t *testing.T) {
+	s := newTenantTest(t)
+	acmeToken := s.signUp(t, "acme", "ana@example.com")
+	defaultToken := s.signUp(t, "", "bruno@example.com")
+
+	res := s.do(t, http.MethodPost, "/categories", bearer(acmeToken), `{"name": "Tools"}`)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	var tools entity.Category
+	json.NewDecoder(res.Body).Decode(&tools)
+	path := "/categories/" + tools.ID.String()
+
+	intruder := bearer(defaultToken)
+	assert.Equal(t, http.StatusNotFound, s.do(t, http.MethodGet, path, intruder, "").StatusCode)
+	assert.Equal(t, http.StatusNotFound, s.do(t, http.MethodPut, path, intruder, `{"name": "Stolen"}`).StatusCode)
+	assert.Equal(t, http.StatusNotFound, s.do(t, http.MethodDelete, path, intruder, "").StatusCode)
+
+	var categories []*entity.Category
+	res = s.do(t, http.MethodGet, "/categories", intruder, "")
+	json.NewDecoder(res.Body).Decode(&categories)
+	assert.Empty(t, categories)
+
+	// nor can their products or categories be put in it.
+	res = s.do(t, http.MethodPost, "/products", intruder,
+		`{"name": "Hammer", "price": {"amount": "10.00", "currency": "USD"}, "category_ids": ["`+tools.ID.String()+`"]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+	res = s.do(t, http.MethodPost, "/categories", intruder, `{"name": "Hammers", "parent_id": "`+tools.ID.String()+`"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+
+	res = s.do(t, http.MethodGet, path, bearer(acmeToken), "")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	json.NewDecoder(res.Body).Decode(&tools)
+	assert.Equal(t, "Tools", tools.Name)
+}
+
+func TestAPIKeysActInTheTenantOfTheirUser(t *testing.T) {
+	s := newTenantTest(t)
+	acmeToken := s.signUp(t, "acme", "ana@example.com")
+	res := s.do(t, http.MethodPost, "/products", bearer(acmeToken), `{"name": "Anvil", "price": {"amount": "10.00", "currency": "USD"}}`)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+
+	decoded, _ := s.tokenAuth.Decode(acmeToken)
+	userID, _ := entityPkg.ParseID(decoded.Subject())
+	key, plain, _ := entity.NewAPIKey(userID, "ci", []entity.Permission{entity.PermissionProductsRead}, nil, time.Now())
+	assert.NoError(t, s.keys.Create(context.Background(), key))
+
+	var products []*entity.Product
+	res = s.do(t, http.MethodGet, "/products", map[string]string{"X-API-Key": plain}, "")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	json.NewDecoder(res.Body).Decode(&products)
+	assert.Len(t, products, 1)
+}
+
+func TestTokensWithoutTenantActInTheDefaultTenant(t *testing.T) {
+	s := newTenantTest(t)
+	acmeToken := s.signUp(t, "acme", "ana@example.com")
+	res := s.do(t, http.MethodPost, "/products", bearer(acmeToken), `{"name": "Anvil", "price": {"amount": "10.00", "currency": "USD"}}`)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+
+	// as issued before tenants existed.
+	_, legacy, _ := s.tokenAuth.Encode(map[string]interface{}{
+		"sub": entityPkg.NewID().String(),
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+	headers := bearer(legacy)
+	headers[TenantHeader] = "acme"
+
+	var products []*entity.Product
+	res = s.do(t, http.MethodGet, "/products", headers, "")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	json.NewDecoder(res.Body).Decode(&products)
+	assert.Empty(t, products)
+}
@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/Mazzael/go-api/internal/entity"
+	"github.com/Mazzael/go-api/internal/infra/webserver/handlers"
+	"github.com/go-chi/jwtauth"
+)
+
+// RequireVerifiedEmail answers 403 unless the email_verified claim of the
+// access token is true. It must run after jwtauth.Verifier and
+// Authenticator.
+func RequireVerifiedEmail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, claims, err := jwtauth.FromContext(r.Context())
+		if err != nil {
+			handlers.WriteProblem(w, r, handlers.NewProblem(http.StatusUnauthorized, "a valid access token is required"))
+			return
+		}
+
+		if verified, _ := claims["email_verified"].(bool); !verified {
+			handlers.WriteError(w, r, entity.ErrEmailUnverified)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,127 @@
+package webserver
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrShuttingDown = errors.New("shutting down")
+
+// Config sets up the HTTP server and how it shuts down.
+type Config struct {
+	Port              string
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// ShutdownDelay is how long readiness fails before the listener stops,
+	// so that load balancers stop sending requests first. It should exceed
+	// how long readiness reports are cached.
+	ShutdownDelay time.Duration
+	// ShutdownTimeout bounds how long in-flight requests get to finish.
+	ShutdownTimeout time.Duration
+}
+
+// Server serves HTTP and runs the background workers of the API, and shuts
+// both down gracefully.
+type Server struct {
+	http     *http.Server
+	config   Config
+	draining atomic.Bool
+
+	workers   sync.WaitGroup
+	stop      context.CancelFunc
+	workerCtx context.Context
+}
+
+func NewServer(config Config, handler http.Handler) *Server {
+	workerCtx, stop := context.WithCancel(context.Background())
+	return &Server{
+		http: &http.Server{
+			Addr:              ":" + config.Port,
+			Handler:           handler,
+			ReadTimeout:       config.ReadTimeout,
+			ReadHeaderTimeout: config.ReadHeaderTimeout,
+			WriteTimeout:      config.WriteTimeout,
+			IdleTimeout:       config.IdleTimeout,
+		},
+		config:    config,
+		stop:      stop,
+		workerCtx: workerCtx,
+	}
+}
+
+// Go runs worker in the background until the server shuts down, when its
+// context is cancelled and the shutdown waits for it to return.
+func (s *Server) Go(worker func(ctx context.Context)) {
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+		worker(s.workerCtx)
+	}()
+}
+
+// Run listens on the configured port and serves until ctx is done, then
+// shuts down, see Serve.
+func (s *Server) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, listener)
+}
+
+// Serve serves on listener until ctx is done or serving fails. It then
+// fails readiness, waits ShutdownDelay, stops accepting connections, lets
+// in-flight requests finish within ShutdownTimeout and stops the workers.
+// It returns once all of that is done, with the error that ended serving or
+// the one draining ran into.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	served := make(chan error, 1)
+	go func() {
+		log.Println("listening on", listener.Addr())
+		served <- s.http.Serve(listener)
+	}()
+
+	var err error
+	select {
+	case err = <-served:
+	case <-ctx.Done():
+		log.Println("shutting down")
+		err = s.shutdown()
+	}
+	s.stop()
+	s.workers.Wait()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func (s *Server) shutdown() error {
+	s.draining.Store(true)
+	time.Sleep(s.config.ShutdownDelay)
+
+	ctx := context.Background()
+	if s.config.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.ShutdownTimeout)
+		defer cancel()
+	}
+	return s.http.Shutdown(ctx)
+}
+
+// Check fails as soon as shutting down starts, so that readiness probes
+// stop routing requests to the server before it stops accepting them.
+func (s *Server) Check(ctx context.Context) error {
+	if s.draining.Load() {
+		return ErrShuttingDown
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package webserver
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Mazzael/go-api/internal/infra/webserver/handlers"
+	"github.com/Mazzael/go-api/pkg/health"
+	"github.com/stretchr/testify/assert"
+)
+
+type testServer struct {
+	*Server
+	url string
+	// entered is closed once /slow is requested, which then blocks until
+	// release is closed.
+	entered, release chan struct{}
+	// stopped gets what Serve returns once cancel is called.
+	stopped chan error
+	cancel  context.CancelFunc
+}
+
+// startServer serves /readyz and a /slow endpoint on a free port.
+func startServer(t *testing.T, config Config) *testServer {
+	s := &testServer{entered: make(chan struct{}), release: make(chan struct{}), stopped: make(chan error, 1)}
+	mux := http.NewServeMux()
+	s.Server = NewServer(config, mux)
+	readiness := health.New(0, time.Second)
+	readiness.Register("server", s.Server)
+	mux.HandleFunc("/readyz", handlers.NewHealthHandler(readiness).Ready)
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(s.entered)
+		<-s.release
+		w.Write([]byte("done"))
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.url = "http://" + listener.Addr().String()
+	var ctx context.Context
+	ctx, s.cancel = context.WithCancel(context.Background())
+	go func() { s.stopped <- s.Serve(ctx, listener) }()
+	return s
+}
+
+func TestShutdownLetsInFlightRequestsFinish(t *testing.T) {
+	s := startServer(t, Config{ShutdownDelay: 200 * time.Millisecond, ShutdownTimeout: 5 * time.Second})
+
+	workerStopped := make(chan struct{})
+	s.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		close(workerStopped)
+	})
+
+	res, err := http.Get(s.url + "/readyz")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	res.Body.Close()
+
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	slow := make(chan result, 1)
+	go func() {
+		res, err := http.Get(s.url + "/slow")
+		if err != nil {
+			slow <- result{err: err}
+			return
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		slow <- result{status: res.StatusCode, body: string(body)}
+	}()
+	<-s.entered
+
+	s.cancel()
+
+	// readiness fails while the listener still accepts connections.
+	assert.Eventually(t, func() bool {
+		res, err := http.Get(s.url + "/readyz")
+		if err != nil {
+			return false
+		}
+		res.Body.Close()
+		return res.StatusCode == http.StatusServiceUnavailable
+	}, time.Second, 10*time.Millisecond)
+
+	select {
+	case <-s.stopped:
+		t.Fatal("stopped before the in-flight request finished")
+	case <-time.After(300 * time.Millisecond):
+	}
+	select {
+	case <-workerStopped:
+		t.Fatal("workers stopped before the in-flight request finished")
+	default:
+	}
+
+	close(s.release)
+	got := <-slow
+	assert.NoError(t, got.err)
+	assert.Equal(t, http.StatusOK, got.status)
+	assert.Equal(t, "done", got.body)
+
+	assert.NoError(t, <-s.stopped)
+	<-workerStopped
+
+	_, err = http.Get(s.url + "/readyz")
+	assert.Error(t, err)
+}
+
+func TestShutdownGivesUpAfterTheTimeout(t *testing.T) {
+	s := startServer(t, Config{ShutdownTimeout: 50 * time.Millisecond})
+	defer close(s.release)
+
+	go func() {
+		if res, err := http.Get(s.url + "/slow"); err == nil {
+			res.Body.Close()
+		}
+	}()
+	<-s.entered
+
+	s.cancel()
+	assert.ErrorIs(t, <-s.stopped, context.DeadlineExceeded)
+}
@@ -0,0 +1,443 @@
+package entity
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is used when an amount is given without a currency.
+const DefaultCurrency = "USD"
+
+var (
+	ErrInvalidCurrency  = errors.New("invalid currency")
+	ErrInvalidAmount    = errors.New("invalid amount")
+	ErrTooManyDecimals  = errors.New("amount has more decimals than the currency allows")
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	ErrMoneyOverflow    = errors.New("amount overflows")
+	ErrDivisionByZero   = errors.New("division by zero")
+)
+
+// currencies maps the supported ISO 4217 codes to their number of minor
+// unit digits.
+var currencies = map[string]int{
+	"ARS": 2, "AUD": 2, "BRL": 2, "CAD": 2, "CHF": 2, "CLP": 0, "CNY": 2,
+	"COP": 2, "CZK": 2, "DKK": 2, "EUR": 2, "GBP": 2, "HKD": 2, "HUF": 2,
+	"INR": 2, "ISK": 0, "JPY": 0, "KRW": 0, "KWD": 3, "MXN": 2, "NOK": 2,
+	"NZD": 2, "PLN": 2, "PYG": 0, "SEK": 2, "SGD": 2, "TND": 3, "TRY": 2,
+	"USD": 2, "UYU": 2, "ZAR": 2,
+}
+
+type RoundingMode int
+
+const (
+	// RoundHalfEven rounds to the nearest value and ties to the even one.
+	RoundHalfEven RoundingMode = iota
+	// RoundHalfUp rounds to the nearest value and ties away from zero.
+	RoundHalfUp
+	// RoundDown truncates towards zero.
+	RoundDown
+	// RoundUp rounds away from zero.
+	RoundUp
+)
+
+// Money is an amount of a currency stored as an integer number of minor
+// units (cents for USD), so arithmetic on it is exact.
+type Money struct {
+	Amount   int64  `json:"amount" swaggertype:"string" example:"10.50"`
+	Currency string `json:"currency" gorm:"type:varchar(3)" example:"USD"`
+}
+
+func NewMoney(amount int64, currency string) (Money, error) {
+	currency = strings.ToUpper(currency)
+	if _, ok := currencies[currency]; !ok {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidCurrency, currency)
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// ParseMoney parses a decimal string such as "10.50". It fails when the
+// string has more decimals than the currency's minor unit.
+func ParseMoney(s, currency string) (Money, error) {
+	return parseMoney(s, currency, nil)
+}
+
+// ParseMoneyWithRounding parses a decimal string such as "10.505", rounding
+// any digits beyond the currency's minor unit with mode.
+func ParseMoneyWithRounding(s, currency string, mode RoundingMode) (Money, error) {
+	return parseMoney(s, currency, &mode)
+}
+
+// MustParseMoney is like ParseMoney but panics on error.
+func MustParseMoney(s, currency string) Money {
+	m, err := ParseMoney(s, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// NewMoneyFromFloat converts a float64 amount in major units, rounding it to
+// the currency's minor unit with mode. It exists to migrate legacy float
+// prices and should not be used for new arithmetic.
+func NewMoneyFromFloat(f float64, currency string, mode RoundingMode) (Money, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Money{}, ErrInvalidAmount
+	}
+	return ParseMoneyWithRounding(strconv.FormatFloat(f, 'f', -1, 64), currency, mode)
+}
+
+func parseMoney(s, currency string, mode *RoundingMode) (Money, error) {
+	m, err := NewMoney(0, currency)
+	if err != nil {
+		return Money{}, err
+	}
+	exponent := currencies[m.Currency]
+
+	s = strings.TrimSpace(s)
+	negative := false
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	if (whole == "" && fraction == "") || !isDigits(whole) || !isDigits(fraction) {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+
+	var extra string
+	if len(fraction) > exponent {
+		fraction, extra = fraction[:exponent], fraction[exponent:]
+		if mode == nil && strings.Trim(extra, "0") != "" {
+			return Money{}, fmt.Errorf("%w: %q", ErrTooManyDecimals, s)
+		}
+	}
+	fraction += strings.Repeat("0", exponent-len(fraction))
+
+	digits := whole + fraction
+	if digits == "" {
+		digits = "0"
+	}
+	amount, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return Money{}, ErrMoneyOverflow
+	}
+
+	if mode != nil && strings.Trim(extra, "0") != "" {
+		amount, err = roundExtra(amount, extra, *mode)
+		if err != nil {
+			return Money{}, err
+		}
+	}
+
+	if negative {
+		amount = -amount
+	}
+	m.Amount = amount
+	return m, nil
+}
+
+// roundExtra rounds the magnitude amount using the discarded digits extra.
+func roundExtra(amount int64, extra string, mode RoundingMode) (int64, error) {
+	var increment bool
+	switch mode {
+	case RoundDown:
+		increment = false
+	case RoundUp:
+		increment = true
+	case RoundHalfUp:
+		increment = extra[0] >= '5'
+	case RoundHalfEven:
+		tail := strings.Trim(extra[1:], "0")
+		switch {
+		case extra[0] > '5', extra[0] == '5' && tail != "":
+			increment = true
+		case extra[0] == '5':
+			increment = amount%2 == 1
+		}
+	}
+
+	if increment {
+		if amount == math.MaxInt64 {
+			return 0, ErrMoneyOverflow
+		}
+		amount++
+	}
+	return amount, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Exponent returns the number of minor unit digits of the currency.
+func (m Money) Exponent() int {
+	return currencies[m.Currency]
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+func (m Money) IsValid() bool {
+	_, ok := currencies[m.Currency]
+	return ok
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	sum := m.Amount + other.Amount
+	if (other.Amount > 0 && sum < m.Amount) || (other.Amount < 0 && sum > m.Amount) {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: sum, Currency: m.Currency}, nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	if other.Amount == math.MinInt64 {
+		return Money{}, ErrMoneyOverflow
+	}
+	return m.Add(Money{Amount: -other.Amount, Currency: other.Currency})
+}
+
+func (m Money) Mul(n int64) (Money, error) {
+	if m.Amount == 0 || n == 0 {
+		return Money{Currency: m.Currency}, nil
+	}
+	product := m.Amount * n
+	if product/n != m.Amount || (m.Amount == -1 && n == math.MinInt64) || (n == -1 && m.Amount == math.MinInt64) {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: product, Currency: m.Currency}, nil
+}
+
+// MulRat multiplies m by num/den, rounding the result with mode. A 15%
+// discount is MulRat(85, 100, RoundHalfEven).
+func (m Money) MulRat(num, den int64, mode RoundingMode) (Money, error) {
+	if den == 0 {
+		return Money{}, ErrDivisionByZero
+	}
+	product, err := m.Mul(num)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: divRound(product.Amount, den, mode), Currency: m.Currency}, nil
+}
+
+// Split divides m into n parts that add up to m exactly, spreading the
+// remainder one minor unit at a time over the first parts.
+func (m Money) Split(n int) ([]Money, error) {
+	if n <= 0 {
+		return nil, ErrDivisionByZero
+	}
+	parts := make([]Money, n)
+	share, remainder := m.Amount/int64(n), m.Amount%int64(n)
+	for i := range parts {
+		parts[i] = Money{Amount: share, Currency: m.Currency}
+		if remainder > 0 {
+			parts[i].Amount++
+			remainder--
+		} else if remainder < 0 {
+			parts[i].Amount--
+			remainder++
+		}
+	}
+	return parts, nil
+}
+
+// Cmp compares two amounts of the same currency and returns -1, 0 or +1.
+func (m Money) Cmp(other Money) (int, error) {
+	if m.Currency != other.Currency {
+		return 0, ErrCurrencyMismatch
+	}
+	switch {
+	case m.Amount < other.Amount:
+		return -1, nil
+	case m.Amount > other.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Decimal formats the amount in major units, e.g. "10.50".
+func (m Money) Decimal() string {
+	exponent := m.Exponent()
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+	}
+
+	digits := strconv.FormatUint(absUint(amount), 10)
+	if exponent == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+type moneyJSON struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+// MarshalJSON encodes m as {"amount": "10.50", "currency": "USD"} so the
+// amount never goes through a float.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{m.Decimal(), m.Currency})
+}
+
+// UnmarshalJSON accepts {"amount": "10.50", "currency": "USD"}, and a bare
+// decimal string or number in the DefaultCurrency.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	raw, currency := json.RawMessage(data), DefaultCurrency
+	if len(data) > 0 && data[0] == '{' {
+		var v moneyJSON
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		raw = v.Amount
+		if v.Currency != "" {
+			currency = v.Currency
+		}
+	}
+
+	amount, err := decimalFromJSON(raw)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := ParseMoney(amount, currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+func decimalFromJSON(raw json.RawMessage) (string, error) {
+	if len(raw) > 0 && raw[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return "", err
+		}
+		return s, nil
+	}
+
+	var n json.Number
+	if err := json.Unmarshal(raw, &n); err != nil {
+		return "", ErrInvalidAmount
+	}
+	if strings.ContainsAny(n.String(), "eE") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidAmount, n.String())
+	}
+	return n.String(), nil
+}
+
+// GormDataType makes GORM store Money in a single text column when it is
+// used as a plain field. Tables that need to query or sort by amount embed it
+// instead, which maps Amount and Currency to columns of their own.
+func (Money) GormDataType() string {
+	return "string"
+}
+
+// Value stores m in a single column as "10.50 USD".
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+func (m *Money) Scan(value interface{}) error {
+	var s string
+	switch v := value.(type) {
+	case nil:
+		*m = Money{}
+		return nil
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Money", value)
+	}
+
+	amount, currency, ok := strings.Cut(strings.TrimSpace(s), " ")
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+
+	parsed, err := ParseMoney(amount, currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+func divRound(a, b int64, mode RoundingMode) int64 {
+	quotient, remainder := a/b, a%b
+	if remainder == 0 {
+		return quotient
+	}
+
+	// direction of the exact result, away from zero
+	direction := int64(1)
+	if (a < 0) != (b < 0) {
+		direction = -1
+	}
+
+	r, d := absUint(remainder), absUint(b)
+	var increment bool
+	switch mode {
+	case RoundDown:
+		increment = false
+	case RoundUp:
+		increment = true
+	case RoundHalfUp:
+		increment = 2*r >= d
+	case RoundHalfEven:
+		increment = 2*r > d || (2*r == d && quotient%2 != 0)
+	}
+
+	if increment {
+		quotient += direction
+	}
+	return quotient
+}
+
+func absUint(n int64) uint64 {
+	if n < 0 {
+		return uint64(-(n + 1)) + 1
+	}
+	return uint64(n)
+}
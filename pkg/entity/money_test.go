@@ -0,0 +1,209 @@
+package entity
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in       string
+		currency string
+		want     Money
+	}{
+		{"10.50", "USD", Money{1050, "USD"}},
+		{"10.5", "usd", Money{1050, "USD"}},
+		{"10", "USD", Money{1000, "USD"}},
+		{".05", "USD", Money{5, "USD"}},
+		{"-0.01", "EUR", Money{-1, "EUR"}},
+		{"1500", "JPY", Money{1500, "JPY"}},
+		{"1.234", "KWD", Money{1234, "KWD"}},
+		{"2.100", "USD", Money{210, "USD"}},
+	}
+
+	for _, tt := range tests {
+		m, err := ParseMoney(tt.in, tt.currency)
+		assert.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, m, tt.in)
+	}
+}
+
+func TestParseMoneyErrors(t *testing.T) {
+	_, err := ParseMoney("10.505", "USD")
+	assert.ErrorIs(t, err, ErrTooManyDecimals)
+
+	_, err = ParseMoney("1.5", "JPY")
+	assert.ErrorIs(t, err, ErrTooManyDecimals)
+
+	_, err = ParseMoney("10.50", "XXX")
+	assert.ErrorIs(t, err, ErrInvalidCurrency)
+
+	for _, in := range []string{"", "-", "abc", "1.2.3", "1e3", "1,50"} {
+		_, err = ParseMoney(in, "USD")
+		assert.ErrorIs(t, err, ErrInvalidAmount, in)
+	}
+
+	_, err = ParseMoney("99999999999999999999", "USD")
+	assert.ErrorIs(t, err, ErrMoneyOverflow)
+}
+
+func TestParseMoneyWithRounding(t *testing.T) {
+	tests := []struct {
+		in   string
+		mode RoundingMode
+		want int64
+	}{
+		{"1.005", RoundHalfEven, 100},
+		{"1.015", RoundHalfEven, 102},
+		{"1.0051", RoundHalfEven, 101},
+		{"1.005", RoundHalfUp, 101},
+		{"1.004", RoundHalfUp, 100},
+		{"1.009", RoundDown, 100},
+		{"1.001", RoundUp, 101},
+		{"-1.005", RoundHalfUp, -101},
+		{"-1.009", RoundDown, -100},
+		{"-1.001", RoundUp, -101},
+		{"1.000", RoundUp, 100},
+	}
+
+	for _, tt := range tests {
+		m, err := ParseMoneyWithRounding(tt.in, "USD", tt.mode)
+		assert.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, m.Amount, tt.in)
+	}
+}
+
+func TestNewMoneyFromFloat(t *testing.T) {
+	m, err := NewMoneyFromFloat(0.1+0.2, "USD", RoundHalfEven)
+	assert.NoError(t, err)
+	assert.Equal(t, Money{30, "USD"}, m)
+
+	m, err = NewMoneyFromFloat(15.5, "USD", RoundHalfEven)
+	assert.NoError(t, err)
+	assert.Equal(t, Money{1550, "USD"}, m)
+
+	_, err = NewMoneyFromFloat(math.NaN(), "USD", RoundHalfEven)
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	a := MustParseMoney("0.10", "USD")
+	b := MustParseMoney("0.20", "USD")
+
+	sum, err := a.Add(b)
+	assert.NoError(t, err)
+	assert.Equal(t, "0.30", sum.Decimal())
+
+	diff, err := a.Sub(b)
+	assert.NoError(t, err)
+	assert.Equal(t, "-0.10", diff.Decimal())
+
+	product, err := a.Mul(3)
+	assert.NoError(t, err)
+	assert.Equal(t, "0.30", product.Decimal())
+
+	_, err = a.Add(MustParseMoney("0.10", "EUR"))
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+
+	_, err = Money{math.MaxInt64, "USD"}.Add(Money{1, "USD"})
+	assert.ErrorIs(t, err, ErrMoneyOverflow)
+
+	_, err = Money{math.MaxInt64, "USD"}.Mul(2)
+	assert.ErrorIs(t, err, ErrMoneyOverflow)
+
+	cmp, err := a.Cmp(b)
+	assert.NoError(t, err)
+	assert.Equal(t, -1, cmp)
+}
+
+func TestMoneyMulRat(t *testing.T) {
+	price := MustParseMoney("10.05", "USD")
+
+	discounted, err := price.MulRat(85, 100, RoundHalfEven)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(854), discounted.Amount)
+
+	half, err := Money{5, "USD"}.MulRat(1, 2, RoundHalfEven)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), half.Amount)
+
+	half, err = Money{5, "USD"}.MulRat(1, 2, RoundHalfUp)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), half.Amount)
+
+	half, err = Money{-5, "USD"}.MulRat(1, 2, RoundHalfUp)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-3), half.Amount)
+
+	third, err := Money{100, "USD"}.MulRat(1, 3, RoundUp)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(34), third.Amount)
+
+	_, err = price.MulRat(1, 0, RoundDown)
+	assert.ErrorIs(t, err, ErrDivisionByZero)
+}
+
+func TestMoneySplit(t *testing.T) {
+	parts, err := MustParseMoney("10.00", "USD").Split(3)
+	assert.NoError(t, err)
+	assert.Equal(t, []Money{{334, "USD"}, {333, "USD"}, {333, "USD"}}, parts)
+
+	parts, err = MustParseMoney("-0.05", "USD").Split(2)
+	assert.NoError(t, err)
+	assert.Equal(t, []Money{{-3, "USD"}, {-2, "USD"}}, parts)
+}
+
+func TestMoneyDecimal(t *testing.T) {
+	assert.Equal(t, "0.05", Money{5, "USD"}.Decimal())
+	assert.Equal(t, "-12.34", Money{-1234, "USD"}.Decimal())
+	assert.Equal(t, "1500", Money{1500, "JPY"}.Decimal())
+	assert.Equal(t, "0.001", Money{1, "KWD"}.Decimal())
+	assert.Equal(t, "-92233720368547758.08", Money{math.MinInt64, "USD"}.Decimal())
+	assert.Equal(t, "10.50 USD", Money{1050, "USD"}.String())
+}
+
+func TestMoneyJSON(t *testing.T) {
+	data, err := json.Marshal(Money{1050, "USD"})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"amount":"10.50","currency":"USD"}`, string(data))
+
+	inputs := map[string]Money{
+		`{"amount":"10.50","currency":"EUR"}`: {1050, "EUR"},
+		`{"amount":10.5,"currency":"EUR"}`:    {1050, "EUR"},
+		`{"amount":"10.50"}`:                  {1050, DefaultCurrency},
+		`"0.30"`:                              {30, DefaultCurrency},
+		`15.50`:                               {1550, DefaultCurrency},
+	}
+	for in, want := range inputs {
+		var m Money
+		assert.NoError(t, json.Unmarshal([]byte(in), &m), in)
+		assert.Equal(t, want, m, in)
+	}
+
+	var m Money
+	assert.ErrorIs(t, json.Unmarshal([]byte(`10.505`), &m), ErrTooManyDecimals)
+	assert.ErrorIs(t, json.Unmarshal([]byte(`1e3`), &m), ErrInvalidAmount)
+	assert.ErrorIs(t, json.Unmarshal([]byte(`{"amount":"1","currency":"ABC"}`), &m), ErrInvalidCurrency)
+}
+
+func TestMoneyScanValue(t *testing.T) {
+	value, err := Money{1050, "USD"}.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "10.50 USD", value)
+
+	var m Money
+	assert.NoError(t, m.Scan("10.50 USD"))
+	assert.Equal(t, Money{1050, "USD"}, m)
+
+	assert.NoError(t, m.Scan([]byte("1500 JPY")))
+	assert.Equal(t, Money{1500, "JPY"}, m)
+
+	assert.NoError(t, m.Scan(nil))
+	assert.Equal(t, Money{}, m)
+
+	assert.Error(t, m.Scan(10.5))
+	assert.Error(t, m.Scan("10.50"))
+}
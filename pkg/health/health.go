@@ -0,0 +1,140 @@
+// Package health checks whether the subsystems an instance depends on, like
+// its database or mailer, are usable, for readiness probes.
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Checker checks one subsystem, returning why it is unusable.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc lets a plain function be a Checker.
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Result is the outcome of one checker.
+type Result struct {
+	Name      string  `json:"name" example:"database"`
+	Status    string  `json:"status" example:"up"`
+	LatencyMS float64 `json:"latency_ms" example:"0.42"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the outcome of every checker: up when all of them are.
+type Report struct {
+	Status    string    `json:"status" example:"up"`
+	CheckedAt time.Time `json:"checked_at"`
+	Checks    []Result  `json:"checks"`
+}
+
+func (r Report) Up() bool {
+	return r.Status == StatusUp
+}
+
+type check struct {
+	name    string
+	checker Checker
+}
+
+// Health runs the registered checkers and caches their report for a while,
+// so that frequent probes do not hit the subsystems each time.
+type Health struct {
+	ttl     time.Duration
+	timeout time.Duration
+	now     func() time.Time
+
+	mu     sync.Mutex
+	checks []check
+	report *Report
+}
+
+// New returns a Health whose reports are reused for ttl, and whose checkers
+// fail when they take longer than timeout.
+func New(ttl, timeout time.Duration) *Health {
+	return &Health{ttl: ttl, timeout: timeout, now: time.Now}
+}
+
+// Register adds checker to the report under name.
+func (h *Health) Register(name string, checker Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks = append(h.checks, check{name: name, checker: checker})
+	h.report = nil
+}
+
+// Check runs every checker at once, unless the last report is recent
+// enough. Concurrent calls wait for a single run rather than each checking.
+func (h *Health) Check(ctx context.Context) Report {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.report != nil && h.now().Sub(h.report.CheckedAt) < h.ttl {
+		return *h.report
+	}
+
+	// the report is shared, so it must not fail because one caller went away.
+	ctx = context.WithoutCancel(ctx)
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+
+	report := Report{Status: StatusUp, CheckedAt: h.now(), Checks: make([]Result, len(h.checks))}
+	var wg sync.WaitGroup
+	for i, c := range h.checks {
+		wg.Add(1)
+		go func(i int, c check) {
+			defer wg.Done()
+			report.Checks[i] = run(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	h.report = &report
+	return report
+}
+
+func run(ctx context.Context, c check) Result {
+	start := time.Now()
+	err := checkWithin(ctx, c.checker)
+	result := Result{
+		Name:      c.name,
+		Status:    StatusUp,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// checkWithin gives up on checker once ctx is done, even if it ignores ctx.
+func checkWithin(ctx context.Context, checker Checker) error {
+	done := make(chan error, 1)
+	go func() { done <- checker.Check(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,81 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckReportsEveryChecker(t *testing.T) {
+	h := New(0, time.Second)
+	h.Register("database", CheckerFunc(func(ctx context.Context) error { return nil }))
+	h.Register("mailer", CheckerFunc(func(ctx context.Context) error { return errors.New("connection refused") }))
+
+	report := h.Check(context.Background())
+	assert.False(t, report.Up())
+	assert.Equal(t, StatusDown, report.Status)
+	if assert.Len(t, report.Checks, 2) {
+		assert.Equal(t, Result{Name: "database", Status: StatusUp, LatencyMS: report.Checks[0].LatencyMS}, report.Checks[0])
+		assert.Equal(t, "mailer", report.Checks[1].Name)
+		assert.Equal(t, StatusDown, report.Checks[1].Status)
+		assert.Equal(t, "connection refused", report.Checks[1].Error)
+	}
+}
+
+func TestCheckWithoutCheckersIsUp(t *testing.T) {
+	assert.True(t, New(0, time.Second).Check(context.Background()).Up())
+}
+
+func TestCheckCachesTheReport(t *testing.T) {
+	now := time.Now()
+	h := New(time.Second, time.Second)
+	h.now = func() time.Time { return now }
+	var calls atomic.Int32
+	h.Register("database", CheckerFunc(func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.Check(context.Background())
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), calls.Load())
+
+	now = now.Add(time.Second)
+	h.Check(context.Background())
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestCheckGivesUpOnSlowCheckers(t *testing.T) {
+	h := New(0, 20*time.Millisecond)
+	release := make(chan struct{})
+	defer close(release)
+	h.Register("cache", CheckerFunc(func(ctx context.Context) error {
+		<-release
+		return nil
+	}))
+
+	report := h.Check(context.Background())
+	assert.False(t, report.Up())
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks[0].Error)
+}
+
+func TestCheckIgnoresTheCallerGoingAway(t *testing.T) {
+	h := New(time.Minute, time.Second)
+	h.Register("database", CheckerFunc(func(ctx context.Context) error { return ctx.Err() }))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.True(t, h.Check(ctx).Up())
+}
@@ -0,0 +1,157 @@
+// Package jwtkeys signs and verifies JWTs with a rotating set of keys. Every
+// token names the key it was signed with in its kid header, so tokens
+// signed before a rotation keep verifying while the old key is trusted.
+package jwtkeys
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+var (
+	ErrNoPEMBlock     = errors.New("no PEM block found")
+	ErrUnsupportedKey = errors.New("unsupported key type")
+	ErrNoPrivateKey   = errors.New("key cannot sign without its private part")
+)
+
+// Key is a signing or verification key together with the algorithm it is
+// used with. Keys loaded from a public key can only verify.
+type Key struct {
+	ID        string
+	Algorithm jwa.SignatureAlgorithm
+
+	private jwk.Key
+	public  jwk.Key
+	// verify is the raw key signatures are checked with.
+	verify interface{}
+}
+
+// NewKey wraps a raw key. RSA keys sign with RS256, P-256, P-384 and P-521
+// keys with ES256, ES384 and ES512, Ed25519 keys with EdDSA and []byte
+// secrets with HS256. The key id is the RFC 7638 thumbprint of the public
+// key, or of the secret, unless kid is given.
+func NewKey(raw interface{}, kid string) (*Key, error) {
+	alg, err := algorithmFor(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := jwk.New(raw)
+	if err != nil {
+		return nil, err
+	}
+	if kid != "" {
+		err = key.Set(jwk.KeyIDKey, kid)
+	} else {
+		err = jwk.AssignKeyID(key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := key.Set(jwk.AlgorithmKey, alg); err != nil {
+		return nil, err
+	}
+
+	k := &Key{ID: key.KeyID(), Algorithm: alg}
+	switch raw.(type) {
+	case []byte:
+		// secrets are never published, so they have no public part.
+		k.private = key
+		k.verify = raw
+		return k, nil
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		k.public = key
+	default:
+		k.private = key
+		if k.public, err = jwk.PublicKeyOf(key); err != nil {
+			return nil, err
+		}
+	}
+	if err := k.public.Set(jwk.KeyUsageKey, "sig"); err != nil {
+		return nil, err
+	}
+	if err := k.public.Raw(&k.verify); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// LoadPEM reads a key from a PEM file holding a PKCS #8, PKCS #1 or SEC 1
+// private key, or a PKIX public key.
+func LoadPEM(path, kid string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := ParsePEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return NewKey(raw, kid)
+}
+
+// ParsePEM decodes the first key in data.
+func ParsePEM(data []byte) (interface{}, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrNoPEMBlock
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%w: PEM block %q", ErrUnsupportedKey, block.Type)
+	}
+}
+
+// CanSign reports whether the private part of the key is known.
+func (k *Key) CanSign() bool {
+	return k.private != nil
+}
+
+func algorithmFor(raw interface{}) (jwa.SignatureAlgorithm, error) {
+	switch key := raw.(type) {
+	case []byte:
+		return jwa.HS256, nil
+	case *rsa.PrivateKey, *rsa.PublicKey:
+		return jwa.RS256, nil
+	case ed25519.PrivateKey, ed25519.PublicKey:
+		return jwa.EdDSA, nil
+	case *ecdsa.PrivateKey:
+		return ecdsaAlgorithm(key.Curve)
+	case *ecdsa.PublicKey:
+		return ecdsaAlgorithm(key.Curve)
+	default:
+		return "", fmt.Errorf("%w: %T", ErrUnsupportedKey, raw)
+	}
+}
+
+func ecdsaAlgorithm(curve elliptic.Curve) (jwa.SignatureAlgorithm, error) {
+	switch curve {
+	case elliptic.P256():
+		return jwa.ES256, nil
+	case elliptic.P384():
+		return jwa.ES384, nil
+	case elliptic.P521():
+		return jwa.ES512, nil
+	default:
+		return "", fmt.Errorf("%w: curve %s", ErrUnsupportedKey, curve.Params().Name)
+	}
+}
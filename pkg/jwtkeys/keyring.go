@@ -0,0 +1,212 @@
+package jwtkeys
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/jwtauth"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+var (
+	ErrUnknownKey = errors.New("token is signed with an unknown key")
+	ErrNoKeyID    = errors.New("token has no kid header")
+)
+
+// Keyring signs tokens with its current key and verifies them with any key
+// it trusts. Rotating to a new key keeps trusting the previous one for an
+// overlap window, which should be at least as long as tokens live, so that
+// tokens signed just before the rotation stay valid until they expire.
+type Keyring struct {
+	mu      sync.RWMutex
+	signing *Key
+	trusted []trustedKey
+	now     func() time.Time
+}
+
+type trustedKey struct {
+	key *Key
+	// until is when the key stops being trusted. Zero keeps trusting it,
+	// like the key signing.
+	until time.Time
+}
+
+func NewKeyring(signing *Key) (*Keyring, error) {
+	if !signing.CanSign() {
+		return nil, ErrNoPrivateKey
+	}
+	return &Keyring{
+		signing: signing,
+		trusted: []trustedKey{{key: signing}},
+		now:     time.Now,
+	}, nil
+}
+
+// Rotate signs with next from now on and keeps trusting the key it replaces
+// for overlap. Rotating to the key already in use does nothing.
+func (k *Keyring) Rotate(next *Key, overlap time.Duration) error {
+	if !next.CanSign() {
+		return ErrNoPrivateKey
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if next.ID == k.signing.ID {
+		return nil
+	}
+
+	now := k.now()
+	trusted := []trustedKey{{key: next}}
+	for _, t := range k.trusted {
+		if t.key.ID == next.ID {
+			continue
+		}
+		if t.key == k.signing {
+			t.until = now.Add(overlap)
+		}
+		if t.isTrusted(now) {
+			trusted = append(trusted, t)
+		}
+	}
+	k.signing = next
+	k.trusted = trusted
+	return nil
+}
+
+// Trust verifies tokens signed with key until the given time, or for good
+// when it is zero. Other instances may be signing with it already, or have
+// signed with it before the last deployment.
+func (k *Keyring) Trust(key *Key, until time.Time) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for i, t := range k.trusted {
+		if t.key.ID == key.ID {
+			if t.key != k.signing {
+				k.trusted[i].until = until
+			}
+			return
+		}
+	}
+	k.trusted = append(k.trusted, trustedKey{key: key, until: until})
+}
+
+// Encode signs claims with the current key, naming it in the kid header.
+// It has the signature of jwtauth.JWTAuth.Encode so both can issue tokens.
+func (k *Keyring) Encode(claims map[string]interface{}) (jwt.Token, string, error) {
+	k.mu.RLock()
+	signing := k.signing
+	k.mu.RUnlock()
+
+	token := jwt.New()
+	for name, value := range claims {
+		if err := token.Set(name, value); err != nil {
+			return nil, "", err
+		}
+	}
+	signed, err := jwt.Sign(token, signing.Algorithm, signing.private)
+	if err != nil {
+		return nil, "", err
+	}
+	return token, string(signed), nil
+}
+
+// Decode checks the signature of a token with the trusted key its kid
+// header names. The algorithm has to be the one the key is meant for, so a
+// token cannot pick a weaker one. Claims like exp are not validated.
+func (k *Keyring) Decode(tokenString string) (jwt.Token, error) {
+	msg, err := jws.ParseString(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if len(msg.Signatures()) != 1 {
+		return nil, jwtauth.ErrUnauthorized
+	}
+	headers := msg.Signatures()[0].ProtectedHeaders()
+	if headers.KeyID() == "" {
+		return nil, ErrNoKeyID
+	}
+
+	key := k.lookup(headers.KeyID())
+	if key == nil {
+		return nil, ErrUnknownKey
+	}
+	if headers.Algorithm() != key.Algorithm {
+		return nil, jwtauth.ErrAlgoInvalid
+	}
+	return jwt.ParseString(tokenString, jwt.WithVerify(key.Algorithm, key.verify))
+}
+
+// Verify decodes a token and validates its claims, reporting failures like
+// jwtauth.VerifyToken does.
+func (k *Keyring) Verify(tokenString string) (jwt.Token, error) {
+	token, err := k.Decode(tokenString)
+	if err != nil {
+		if errors.Is(err, ErrUnknownKey) || errors.Is(err, ErrNoKeyID) || errors.Is(err, jwtauth.ErrAlgoInvalid) {
+			return token, err
+		}
+		return token, jwtauth.ErrorReason(err)
+	}
+	if err := jwt.Validate(token, jwt.WithClock(jwt.ClockFunc(k.now))); err != nil {
+		return token, jwtauth.ErrorReason(err)
+	}
+	return token, nil
+}
+
+// Verifier is jwtauth.Verifier for the keyring: it verifies the token of
+// the Authorization header or jwt cookie and stores the outcome for
+// jwtauth.FromContext and the Authenticator middleware.
+func (k *Keyring) Verifier() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenString := jwtauth.TokenFromHeader(r)
+			if tokenString == "" {
+				tokenString = jwtauth.TokenFromCookie(r)
+			}
+
+			var token jwt.Token
+			err := jwtauth.ErrNoTokenFound
+			if tokenString != "" {
+				token, err = k.Verify(tokenString)
+			}
+			next.ServeHTTP(w, r.WithContext(jwtauth.NewContext(r.Context(), token, err)))
+		})
+	}
+}
+
+// PublicKeys is the JWK set of the public keys trusted now, the one
+// signing first. Secrets are left out.
+func (k *Keyring) PublicKeys() jwk.Set {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	set := jwk.NewSet()
+	now := k.now()
+	for _, t := range k.trusted {
+		if t.key.public == nil || !t.isTrusted(now) {
+			continue
+		}
+		set.Add(t.key.public)
+	}
+	return set
+}
+
+func (k *Keyring) lookup(kid string) *Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	now := k.now()
+	for _, t := range k.trusted {
+		if t.key.ID == kid && t.isTrusted(now) {
+			return t.key
+		}
+	}
+	return nil
+}
+
+func (t trustedKey) isTrusted(now time.Time) bool {
+	return t.until.IsZero() || now.Before(t.until)
+}
@@ -0,0 +1,240 @@
+package jwtkeys
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/jwtauth"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/stretchr/testify/assert"
+)
+
+// writePEM stores raw as a PKCS #8 private key, or a PKIX public key, and
+// returns the file.
+func writePEM(t *testing.T, raw interface{}) string {
+	var block *pem.Block
+	switch raw.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(raw)
+		assert.NoError(t, err)
+		block = &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+	default:
+		der, err := x509.MarshalPKCS8PrivateKey(raw)
+		assert.NoError(t, err)
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+
+	path := filepath.Join(t.TempDir(), "key.pem")
+	assert.NoError(t, os.WriteFile(path, pem.EncodeToMemory(block), 0o600))
+	return path
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	return key
+}
+
+func newECKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	return key
+}
+
+func newEdKey(t *testing.T) ed25519.PrivateKey {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	return key
+}
+
+// header decodes the JOSE header of a compact token.
+func header(t *testing.T, token string) map[string]string {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[0])
+	assert.NoError(t, err)
+	var h map[string]string
+	assert.NoError(t, json.Unmarshal(raw, &h))
+	return h
+}
+
+func claims() map[string]interface{} {
+	return map[string]interface{}{"sub": "user", "exp": time.Now().Add(time.Minute).Unix()}
+}
+
+func TestSignAndVerifyWithPEMKeys(t *testing.T) {
+	tests := map[jwa.SignatureAlgorithm]interface{}{
+		jwa.RS256: newRSAKey(t),
+		jwa.ES256: newECKey(t),
+		jwa.EdDSA: newEdKey(t),
+	}
+
+	for alg, raw := range tests {
+		key, err := LoadPEM(writePEM(t, raw), "")
+		assert.NoError(t, err, alg)
+		assert.Equal(t, alg, key.Algorithm)
+		assert.NotEmpty(t, key.ID)
+
+		keyring, err := NewKeyring(key)
+		assert.NoError(t, err, alg)
+		_, token, err := keyring.Encode(claims())
+		assert.NoError(t, err, alg)
+		assert.Equal(t, map[string]string{"alg": alg.String(), "kid": key.ID, "typ": "JWT"}, header(t, token))
+
+		decoded, err := keyring.Verify(token)
+		assert.NoError(t, err, alg)
+		assert.Equal(t, "user", decoded.Subject())
+	}
+}
+
+func TestKeyIDIsThePublicKeyThumbprint(t *testing.T) {
+	raw := newECKey(t)
+	private, err := LoadPEM(writePEM(t, raw), "")
+	assert.NoError(t, err)
+	public, err := LoadPEM(writePEM(t, raw.Public()), "")
+	assert.NoError(t, err)
+
+	assert.Equal(t, private.ID, public.ID)
+	assert.True(t, private.CanSign())
+	assert.False(t, public.CanSign())
+
+	_, err = NewKeyring(public)
+	assert.ErrorIs(t, err, ErrNoPrivateKey)
+
+	named, err := NewKey(raw, "2026-10")
+	assert.NoError(t, err)
+	assert.Equal(t, "2026-10", named.ID)
+}
+
+func TestRotationKeepsOldTokensValidDuringTheOverlap(t *testing.T) {
+	now := time.Now()
+	oldKey, _ := NewKey(newRSAKey(t), "")
+	newKey, _ := NewKey(newECKey(t), "")
+	keyring, _ := NewKeyring(oldKey)
+	keyring.now = func() time.Time { return now }
+
+	_, oldToken, err := keyring.Encode(claims())
+	assert.NoError(t, err)
+
+	assert.NoError(t, keyring.Rotate(newKey, time.Hour))
+	_, newToken, err := keyring.Encode(claims())
+	assert.NoError(t, err)
+	assert.Equal(t, newKey.ID, header(t, newToken)["kid"])
+
+	_, err = keyring.Verify(oldToken)
+	assert.NoError(t, err)
+	_, err = keyring.Verify(newToken)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, keyring.PublicKeys().Len())
+
+	now = now.Add(time.Hour)
+	_, err = keyring.Verify(oldToken)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	assert.Equal(t, 1, keyring.PublicKeys().Len())
+	published, _ := keyring.PublicKeys().Get(0)
+	assert.Equal(t, newKey.ID, published.KeyID())
+}
+
+func TestTrustVerifiesWithPublicKeysOnly(t *testing.T) {
+	retired := newEdKey(t)
+	retiredKeyring, _ := NewKeyring(mustKey(t, retired))
+	_, token, _ := retiredKeyring.Encode(claims())
+
+	keyring, _ := NewKeyring(mustKey(t, newEdKey(t)))
+	_, err := keyring.Verify(token)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	public, err := LoadPEM(writePEM(t, retired.Public()), "")
+	assert.NoError(t, err)
+	keyring.Trust(public, time.Now().Add(time.Hour))
+	_, err = keyring.Verify(token)
+	assert.NoError(t, err)
+}
+
+func TestVerifyRejectsForgedHeaders(t *testing.T) {
+	key := mustKey(t, newRSAKey(t))
+	keyring, _ := NewKeyring(key)
+
+	// an HMAC token keyed with the public key must not pass for RS256.
+	hmac := jwtauth.New("HS256", []byte("secret"), nil)
+	_, token, _ := hmac.Encode(claims())
+	_, err := keyring.Verify(token)
+	assert.ErrorIs(t, err, ErrNoKeyID)
+
+	forged, _ := NewKey([]byte("secret"), key.ID)
+	forgedKeyring, _ := NewKeyring(forged)
+	_, token, _ = forgedKeyring.Encode(claims())
+	_, err = keyring.Verify(token)
+	assert.ErrorIs(t, err, jwtauth.ErrAlgoInvalid)
+
+	_, token, _ = keyring.Encode(map[string]interface{}{"sub": "user", "exp": time.Now().Add(-time.Minute).Unix()})
+	_, err = keyring.Verify(token)
+	assert.ErrorIs(t, err, jwtauth.ErrExpired)
+}
+
+func TestSecretsAreNotPublished(t *testing.T) {
+	keyring, _ := NewKeyring(mustKey(t, []byte("secret")))
+	_, token, err := keyring.Encode(claims())
+	assert.NoError(t, err)
+	_, err = keyring.Verify(token)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, keyring.PublicKeys().Len())
+}
+
+func mustKey(t *testing.T, raw interface{}) *Key {
+	key, err := NewKey(raw, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestVerifierAcrossRotation(t *testing.T) {
+	now := time.Now()
+	keyring, _ := NewKeyring(mustKey(t, newRSAKey(t)))
+	keyring.now = func() time.Time { return now }
+
+	handler := keyring.Verifier()(jwtauth.Authenticator(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, _, _ := jwtauth.FromContext(r.Context())
+		w.Write([]byte(token.Subject()))
+	})))
+	get := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	hourLong := map[string]interface{}{"sub": "user", "exp": now.Add(time.Hour).Unix()}
+
+	// a client logs in before the rotation and keeps its token.
+	_, held, _ := keyring.Encode(hourLong)
+	assert.NoError(t, keyring.Rotate(mustKey(t, newEdKey(t)), 10*time.Minute))
+	_, fresh, _ := keyring.Encode(hourLong)
+
+	for _, token := range []string{held, fresh} {
+		rec := get(token)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "user", rec.Body.String())
+	}
+
+	now = now.Add(10 * time.Minute)
+	assert.Equal(t, http.StatusUnauthorized, get(held).Code)
+	assert.Equal(t, http.StatusOK, get(fresh).Code)
+	assert.Equal(t, http.StatusUnauthorized, get("").Code)
+}
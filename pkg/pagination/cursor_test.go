@@ -0,0 +1,53 @@
+package pagination
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	signer := NewSigner([]byte("secret"))
+	cursor := Cursor{
+		CreatedAt: time.Date(2026, 10, 18, 12, 30, 0, 123456789, time.UTC),
+		ID:        "7e3ceb01-9101-4725-aeb9-b8f24eacbeec",
+		Direction: Next,
+		Sort:      "desc",
+	}
+
+	token, err := signer.Encode(cursor)
+	assert.NoError(t, err)
+
+	decoded, err := signer.Decode(token)
+	assert.NoError(t, err)
+	assert.True(t, cursor.CreatedAt.Equal(decoded.CreatedAt))
+	assert.Equal(t, cursor.ID, decoded.ID)
+	assert.Equal(t, cursor.Direction, decoded.Direction)
+	assert.Equal(t, cursor.Sort, decoded.Sort)
+}
+
+func TestDecodeRejectsTamperedCursors(t *testing.T) {
+	signer := NewSigner([]byte("secret"))
+	token, err := signer.Encode(Cursor{ID: "a", Direction: Next})
+	assert.NoError(t, err)
+
+	other, err := NewSigner([]byte("other")).Encode(Cursor{ID: "b", Direction: Next})
+	assert.NoError(t, err)
+
+	payload, signature, _ := strings.Cut(token, ".")
+	otherPayload, _, _ := strings.Cut(other, ".")
+
+	for name, token := range map[string]string{
+		"empty":         "",
+		"no signature":  payload,
+		"bad encoding":  "!!!." + signature,
+		"other key":     other,
+		"swapped body":  otherPayload + "." + signature,
+		"truncated sig": payload + "." + signature[:10],
+	} {
+		_, err := signer.Decode(token)
+		assert.ErrorIs(t, err, ErrInvalidCursor, name)
+	}
+}
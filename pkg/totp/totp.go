@@ -0,0 +1,165 @@
+// Package totp implements the time-based one-time passwords of RFC 6238,
+// the codes authenticator apps show, on top of the HOTP algorithm of
+// RFC 4226.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSecret = errors.New("secret is not valid base32")
+
+// Algorithm is the HMAC hash codes are computed with.
+type Algorithm string
+
+const (
+	SHA1   Algorithm = "SHA1"
+	SHA256 Algorithm = "SHA256"
+	SHA512 Algorithm = "SHA512"
+)
+
+const (
+	// SecretSize is the length in bytes of generated secrets, the 160 bits
+	// RFC 4226 recommends.
+	SecretSize    = 20
+	DefaultDigits = 6
+	DefaultPeriod = 30 * time.Second
+)
+
+// secretEncoding is how secrets are shared with authenticator apps.
+var secretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Key is a shared secret together with the parameters codes are derived
+// with. Authenticator apps widely support only the defaults: SHA1, six
+// digits and a 30 second period.
+type Key struct {
+	Secret    []byte
+	Algorithm Algorithm
+	Digits    int
+	Period    time.Duration
+}
+
+// NewKey uses secret with the default parameters.
+func NewKey(secret []byte) Key {
+	return Key{Secret: secret, Algorithm: SHA1, Digits: DefaultDigits, Period: DefaultPeriod}
+}
+
+// Generate returns a key with a random secret and the default parameters.
+func Generate() (Key, error) {
+	secret := make([]byte, SecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return Key{}, err
+	}
+	return NewKey(secret), nil
+}
+
+// Parse reads a base32 secret, as returned by Encoded, into a key with the
+// default parameters. Case, spaces and padding are ignored.
+func Parse(secret string) (Key, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	raw, err := secretEncoding.DecodeString(strings.TrimRight(secret, "="))
+	if err != nil || len(raw) == 0 {
+		return Key{}, ErrInvalidSecret
+	}
+	return NewKey(raw), nil
+}
+
+// Encoded is the secret in unpadded base32, the form users type into
+// authenticator apps.
+func (k Key) Encoded() string {
+	return secretEncoding.EncodeToString(k.Secret)
+}
+
+// URI is the otpauth URI authenticator apps enroll the key from, usually
+// shown as a QR code. The account is what the app lists the key under.
+func (k Key) URI(issuer, account string) string {
+	params := url.Values{}
+	params.Set("secret", k.Encoded())
+	params.Set("issuer", issuer)
+	params.Set("algorithm", string(k.Algorithm))
+	params.Set("digits", strconv.Itoa(k.Digits))
+	params.Set("period", strconv.Itoa(int(k.Period/time.Second)))
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: params.Encode(),
+	}
+	return u.String()
+}
+
+// Step is the number of periods elapsed between the Unix epoch and t, the
+// counter the code for t is computed from.
+func (k Key) Step(t time.Time) int64 {
+	return t.Unix() / int64(k.Period/time.Second)
+}
+
+// Code is the code valid at t.
+func (k Key) Code(t time.Time) string {
+	return k.CodeAt(k.Step(t))
+}
+
+// CodeAt is the HOTP value of the key for counter step.
+func (k Key) CodeAt(step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(k.hash(), k.Secret)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// dynamic truncation, RFC 4226 section 5.3.
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < k.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", k.Digits, value%mod)
+}
+
+// Validate looks for code among the codes of the steps within skew periods
+// of t, tolerating clocks that drift apart, and returns the step it
+// matched. Callers should refuse steps at or before the last one accepted,
+// so that an observed code cannot be replayed.
+func (k Key) Validate(code string, t time.Time, skew int) (int64, bool) {
+	if len(code) != k.Digits {
+		return 0, false
+	}
+
+	now := k.Step(t)
+	for i := -int64(skew); i <= int64(skew); i++ {
+		step := now + i
+		if subtle.ConstantTimeCompare([]byte(k.CodeAt(step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+func (k Key) hash() func() hash.Hash {
+	switch k.Algorithm {
+	case SHA256:
+		return sha256.New
+	case SHA512:
+		return sha512.New
+	default:
+		return sha1.New
+	}
+}
@@ -0,0 +1,101 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHOTPVectors checks the values of appendix D of RFC 4226.
+func TestHOTPVectors(t *testing.T) {
+	key := NewKey([]byte("12345678901234567890"))
+	expected := []string{
+		"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489",
+	}
+
+	for step, code := range expected {
+		assert.Equal(t, code, key.CodeAt(int64(step)), "counter %d", step)
+	}
+}
+
+// TestTOTPVectors checks the values of appendix B of RFC 6238.
+func TestTOTPVectors(t *testing.T) {
+	seeds := map[Algorithm]string{
+		SHA1:   "12345678901234567890",
+		SHA256: "12345678901234567890123456789012",
+		SHA512: "1234567890123456789012345678901234567890123456789012345678901234",
+	}
+	tests := []struct {
+		unix     int64
+		expected map[Algorithm]string
+	}{
+		{59, map[Algorithm]string{SHA1: "94287082", SHA256: "46119246", SHA512: "90693936"}},
+		{1111111109, map[Algorithm]string{SHA1: "07081804", SHA256: "68084774", SHA512: "25091201"}},
+		{1111111111, map[Algorithm]string{SHA1: "14050471", SHA256: "67062674", SHA512: "99943326"}},
+		{1234567890, map[Algorithm]string{SHA1: "89005924", SHA256: "91819424", SHA512: "93441116"}},
+		{2000000000, map[Algorithm]string{SHA1: "69279037", SHA256: "90698825", SHA512: "38618901"}},
+		{20000000000, map[Algorithm]string{SHA1: "65353130", SHA256: "77737706", SHA512: "47863826"}},
+	}
+
+	for _, tt := range tests {
+		for alg, code := range tt.expected {
+			key := Key{Secret: []byte(seeds[alg]), Algorithm: alg, Digits: 8, Period: 30 * time.Second}
+			assert.Equal(t, code, key.Code(time.Unix(tt.unix, 0)), "%s at %d", alg, tt.unix)
+		}
+	}
+}
+
+func TestValidateToleratesSkew(t *testing.T) {
+	key, err := Generate()
+	assert.NoError(t, err)
+	now := time.Unix(1_800_000_000, 0)
+
+	step, ok := key.Validate(key.Code(now.Add(-30*time.Second)), now, 1)
+	assert.True(t, ok)
+	assert.Equal(t, key.Step(now)-1, step)
+
+	_, ok = key.Validate(key.Code(now.Add(-60*time.Second)), now, 1)
+	assert.False(t, ok)
+	_, ok = key.Validate("", now, 1)
+	assert.False(t, ok)
+	_, ok = key.Validate(key.Code(now)+"0", now, 1)
+	assert.False(t, ok)
+}
+
+func TestParseReadsTheEncodedSecret(t *testing.T) {
+	key, _ := Generate()
+	assert.Len(t, key.Secret, SecretSize)
+	assert.Len(t, key.Encoded(), 32)
+
+	parsed, err := Parse(key.Encoded())
+	assert.NoError(t, err)
+	assert.Equal(t, key, parsed)
+
+	// as users may type it from the screen.
+	parsed, err = Parse("gezd gnbv gy3t qojq gezd gnbv gy3t qojq")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("12345678901234567890"), parsed.Secret)
+
+	_, err = Parse("not base32!")
+	assert.ErrorIs(t, err, ErrInvalidSecret)
+}
+
+func TestURI(t *testing.T) {
+	key := NewKey([]byte("12345678901234567890"))
+	uri, err := url.Parse(key.URI("Go API", "ana@example.com"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/Go API:ana@example.com", uri.Path)
+	assert.Equal(t, url.Values{
+		"secret":    {"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"},
+		"issuer":    {"Go API"},
+		"algorithm": {"SHA1"},
+		"digits":    {"6"},
+		"period":    {"30"},
+	}, uri.Query())
+}
@@ -0,0 +1,264 @@
+// Package validation checks structs against the rules in their validate
+// tags. The tag syntax follows go-playground/validator, which swag reads to
+// put the same rules in the OpenAPI schema:
+//
+//	Name  string   `json:"name" validate:"required,max=100"`
+//	Email string   `json:"email" validate:"required,email" format:"email"`
+//	Type  string   `json:"type" validate:"required,oneof=in out"`
+//	IDs   []string `json:"ids" validate:"max=50,dive,uuid"`
+//
+// Supported rules are required, omitempty, min/gte, max/lte, len, oneof,
+// email, uuid and dive, which applies the rules after it to every element.
+// min, max and len count runes for strings, elements for slices and maps,
+// and compare the value itself for numbers.
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// FieldError is a rule a field broke. Field is the path of JSON names
+// leading to it, like "price.currency" or "category_ids[2]".
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// Errors lists every rule a value broke, in field order.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Field + ": " + fieldErr.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Struct checks v, a struct or a pointer to one, and returns Errors listing
+// all the violations, or nil when there are none. It panics on a malformed
+// validate tag, which is a programming error.
+func Struct(v interface{}) error {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validation: %T is not a struct", v))
+	}
+
+	var errs Errors
+	validateStruct(value, "", &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+type rule struct {
+	name  string
+	param string
+}
+
+type field struct {
+	index int
+	name  string
+	rules []rule
+}
+
+var fieldCache sync.Map // reflect.Type -> []field
+
+func validateStruct(value reflect.Value, prefix string, errs *Errors) {
+	for _, f := range fieldsOf(value.Type()) {
+		validateValue(value.Field(f.index), prefix+f.name, f.rules, errs)
+	}
+}
+
+func validateValue(value reflect.Value, path string, rules []rule, errs *Errors) {
+	for i, r := range rules {
+		switch r.name {
+		case "omitempty":
+			if isEmpty(value) {
+				return
+			}
+			continue
+		case "dive":
+			elem := indirect(value)
+			if elem.Kind() == reflect.Slice || elem.Kind() == reflect.Array {
+				for j := 0; j < elem.Len(); j++ {
+					validateValue(elem.Index(j), fmt.Sprintf("%s[%d]", path, j), rules[i+1:], errs)
+				}
+			}
+			return
+		}
+
+		if message := check(r, value); message != "" {
+			*errs = append(*errs, FieldError{Field: path, Message: message})
+			return
+		}
+	}
+
+	if nested := indirect(value); nested.Kind() == reflect.Struct {
+		validateStruct(nested, path+".", errs)
+	}
+}
+
+// check returns why value breaks r, or "" when it does not. Values other
+// than required's are only checked when present.
+func check(r rule, value reflect.Value) string {
+	if r.name == "required" {
+		if isEmpty(value) {
+			return "is required"
+		}
+		return ""
+	}
+
+	value = indirect(value)
+	if !value.IsValid() {
+		return ""
+	}
+
+	switch r.name {
+	case "min", "gte":
+		if size, limit, unit, ok := measure(value, r.param); ok && size < limit {
+			return "must be at least " + r.param + unit
+		}
+	case "max", "lte":
+		if size, limit, unit, ok := measure(value, r.param); ok && size > limit {
+			return "must be at most " + r.param + unit
+		}
+	case "len":
+		if size, limit, unit, ok := measure(value, r.param); ok && size != limit {
+			return "must be exactly " + r.param + unit
+		}
+	case "oneof":
+		s := fmt.Sprint(value.Interface())
+		for _, option := range strings.Fields(r.param) {
+			if s == option {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(strings.Fields(r.param), ", ")
+	case "email":
+		address, err := mail.ParseAddress(value.String())
+		if err != nil || address.Address != value.String() {
+			return "must be a valid email address"
+		}
+	case "uuid":
+		if _, err := uuid.Parse(value.String()); err != nil {
+			return "must be a valid UUID"
+		}
+	}
+	return ""
+}
+
+// measure returns the size min, max and len compare for value together with
+// the parsed limit and the unit to name it with in messages.
+func measure(value reflect.Value, param string) (size, limit float64, unit string, ok bool) {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return 0, 0, "", false
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(value.String())), limit, " characters", true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(value.Len()), limit, " items", true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), limit, "", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), limit, "", true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), limit, "", true
+	}
+	return 0, 0, "", false
+}
+
+// isEmpty reports whether value is its zero value. Strings of blanks count
+// as empty, so "required" cannot be met with whitespace.
+func isEmpty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(value.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return value.IsNil()
+	}
+	return value.IsZero()
+}
+
+func indirect(value reflect.Value) reflect.Value {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return reflect.Value{}
+		}
+		value = value.Elem()
+	}
+	return value
+}
+
+func fieldsOf(t reflect.Type) []field {
+	if cached, ok := fieldCache.Load(t); ok {
+		return cached.([]field)
+	}
+
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("validate")
+		if !sf.IsExported() || tag == "-" {
+			continue
+		}
+
+		name := sf.Name
+		if jsonName, _, _ := strings.Cut(sf.Tag.Get("json"), ","); jsonName == "-" {
+			continue
+		} else if jsonName != "" {
+			name = jsonName
+		}
+
+		fields = append(fields, field{index: i, name: name, rules: parseRules(t, sf.Name, tag)})
+	}
+
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+func parseRules(t reflect.Type, fieldName, tag string) []rule {
+	if tag == "" {
+		return nil
+	}
+
+	var rules []rule
+	for _, part := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(part, "=")
+		switch name {
+		case "required", "omitempty", "email", "uuid", "dive":
+		case "min", "gte", "max", "lte", "len":
+			if _, err := strconv.ParseFloat(param, 64); err != nil {
+				panic(fmt.Sprintf("validation: %s.%s: %s needs a number, got %q", t, fieldName, name, param))
+			}
+		case "oneof":
+			if strings.TrimSpace(param) == "" {
+				panic(fmt.Sprintf("validation: %s.%s: oneof needs options", t, fieldName))
+			}
+		default:
+			panic(fmt.Sprintf("validation: %s.%s: unknown rule %q", t, fieldName, name))
+		}
+		rules = append(rules, rule{name: name, param: param})
+	}
+	return rules
+}
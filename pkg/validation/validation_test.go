@@ -0,0 +1,87 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type signup struct {
+	Name     string         `json:"name" validate:"required,max=5"`
+	Email    string         `json:"email" validate:"required,email"`
+	Age      int            `json:"age" validate:"min=18,max=130"`
+	Plan     string         `json:"plan" validate:"omitempty,oneof=free pro"`
+	Tags     []string       `json:"tags" validate:"max=2,dive,min=2"`
+	ParentID *string        `json:"parent_id" validate:"omitempty,uuid"`
+	Address  address        `json:"address"`
+	Extras   map[string]int `json:"-" validate:"required"`
+	Ignored  string         `validate:"-"`
+	internal string
+}
+
+func TestStructCollectsEveryViolation(t *testing.T) {
+	parent := "not-a-uuid"
+	err := Struct(&signup{
+		Name:     "Gabriela",
+		Email:    "not an email",
+		Age:      12,
+		Plan:     "enterprise",
+		Tags:     []string{"ok", "x"},
+		ParentID: &parent,
+	})
+
+	assert.Equal(t, Errors{
+		{Field: "name", Message: "must be at most 5 characters"},
+		{Field: "email", Message: "must be a valid email address"},
+		{Field: "age", Message: "must be at least 18"},
+		{Field: "plan", Message: "must be one of free, pro"},
+		{Field: "tags[1]", Message: "must be at least 2 characters"},
+		{Field: "parent_id", Message: "must be a valid UUID"},
+		{Field: "address.city", Message: "is required"},
+	}, err)
+}
+
+func TestStructAcceptsValidValues(t *testing.T) {
+	parent := "7e3ceb01-9101-4725-aeb9-b8f24eacbeec"
+	err := Struct(signup{
+		Name:     "Ana",
+		Email:    "ana@example.com",
+		Age:      30,
+		Tags:     []string{"go"},
+		ParentID: &parent,
+		Address:  address{City: "Lisboa"},
+	})
+	assert.NoError(t, err)
+}
+
+func TestRequiredRejectsBlankStrings(t *testing.T) {
+	err := Struct(&signup{Name: "   ", Email: "a@b.co", Age: 20, Address: address{City: "x"}})
+	assert.Equal(t, Errors{{Field: "name", Message: "is required"}}, err)
+}
+
+func TestRulesStopAtTheFirstFailurePerField(t *testing.T) {
+	err := Struct(&signup{Email: "a@b.co", Age: 20, Address: address{City: "x"}})
+	assert.Equal(t, Errors{{Field: "name", Message: "is required"}}, err)
+}
+
+func TestSliceLengthIsCheckedBeforeElements(t *testing.T) {
+	err := Struct(&signup{Name: "Ana", Email: "a@b.co", Age: 20, Tags: []string{"aa", "bb", "cc"}, Address: address{City: "x"}})
+	assert.Equal(t, Errors{{Field: "tags", Message: "must be at most 2 items"}}, err)
+}
+
+func TestMalformedTagsPanic(t *testing.T) {
+	assert.Panics(t, func() {
+		Struct(&struct {
+			Name string `validate:"required,shiny"`
+		}{})
+	})
+	assert.Panics(t, func() {
+		Struct(&struct {
+			Name string `validate:"max=many"`
+		}{})
+	})
+}